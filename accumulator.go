@@ -0,0 +1,284 @@
+package deepseek
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// ChatCompletionAccumulator rebuilds a ChatCompletionResponse from the chunks of a chat completion stream.
+type ChatCompletionAccumulator struct {
+	id      string
+	object  string
+	created int64
+	model   string
+	usage   *StreamUsage
+	choices map[int]*accumulatedChoice
+}
+
+// accumulatedChoice holds the merged state of a single choice.
+type accumulatedChoice struct {
+	message      Message
+	finishReason string
+	logprobs     []any
+	hasLogprobs  bool
+	toolCalls    map[int]*ToolCall
+}
+
+// NewChatCompletionAccumulator creates an empty ChatCompletionAccumulator.
+func NewChatCompletionAccumulator() *ChatCompletionAccumulator {
+	return &ChatCompletionAccumulator{choices: make(map[int]*accumulatedChoice)}
+}
+
+// Add merges a stream chunk into the accumulator.
+// Content and reasoning are concatenated, and tool call fragments are merged by their index.
+func (a *ChatCompletionAccumulator) Add(chunk *StreamChatCompletionResponse) {
+	if chunk == nil {
+		return
+	}
+	if a.id == "" {
+		a.id = chunk.ID
+	}
+	if a.created == 0 {
+		a.created = chunk.Created
+	}
+	if a.model == "" {
+		a.model = chunk.Model
+	}
+	if chunk.Usage != nil && *chunk.Usage != (StreamUsage{}) {
+		usage := *chunk.Usage
+		a.usage = &usage
+	}
+
+	for _, sc := range chunk.Choices {
+		choice, ok := a.choices[sc.Index]
+		if !ok {
+			choice = &accumulatedChoice{toolCalls: make(map[int]*ToolCall)}
+			a.choices[sc.Index] = choice
+		}
+		if sc.Delta.Role != "" {
+			choice.message.Role = sc.Delta.Role
+		}
+		choice.message.Content += sc.Delta.Content
+		choice.message.ReasoningContent += sc.Delta.ReasoningContent
+		if sc.FinishReason != "" {
+			choice.finishReason = sc.FinishReason
+		}
+		if content, ok := logprobsContent(sc.Logprobs); ok {
+			choice.logprobs = append(choice.logprobs, content...)
+			choice.hasLogprobs = true
+		}
+
+		for _, tc := range sc.Delta.ToolCalls {
+			call, ok := choice.toolCalls[tc.Index]
+			if !ok {
+				call = &ToolCall{Index: tc.Index}
+				choice.toolCalls[tc.Index] = call
+			}
+			if tc.ID != "" {
+				call.ID = tc.ID
+			}
+			if tc.Type != "" {
+				call.Type = tc.Type
+			}
+			call.Function.Name += tc.Function.Name
+			call.Function.Arguments += tc.Function.Arguments
+		}
+	}
+}
+
+// logprobsContent returns the content array of a decoded logprobs object.
+func logprobsContent(logprobs any) ([]any, bool) {
+	m, ok := logprobs.(map[string]any)
+	if !ok {
+		return nil, false
+	}
+	content, ok := m["content"].([]any)
+	return content, ok
+}
+
+// Response returns the accumulated response in the same shape CreateChatCompletion returns.
+// It can be called at any point, for example to inspect a stream that ended early.
+func (a *ChatCompletionAccumulator) Response() *ChatCompletionResponse {
+	resp := &ChatCompletionResponse{
+		ID:      a.id,
+		Object:  "chat.completion",
+		Created: a.created,
+		Model:   a.model,
+		Choices: make([]Choice, 0, len(a.choices)),
+	}
+	if a.usage != nil {
+		resp.Usage = a.usage.toUsage()
+	}
+
+	indexes := make([]int, 0, len(a.choices))
+	for index := range a.choices {
+		indexes = append(indexes, index)
+	}
+	sort.Ints(indexes)
+
+	for _, index := range indexes {
+		choice := a.choices[index]
+		message := choice.message
+		if message.Role == "" {
+			message.Role = ChatMessageRoleAssistant
+		}
+		message.ToolCalls = sortedToolCalls(choice.toolCalls)
+
+		c := Choice{
+			Index:        index,
+			Message:      message,
+			FinishReason: choice.finishReason,
+		}
+		if choice.hasLogprobs {
+			c.Logprobs = map[string]any{"content": choice.logprobs}
+		}
+		resp.Choices = append(resp.Choices, c)
+	}
+	return resp
+}
+
+// sortedToolCalls returns the merged tool calls ordered by index.
+func sortedToolCalls(calls map[int]*ToolCall) []ToolCall {
+	if len(calls) == 0 {
+		return nil
+	}
+	result := make([]ToolCall, 0, len(calls))
+	for _, call := range calls {
+		result = append(result, *call)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Index < result[j].Index })
+	return result
+}
+
+// CollectStream reads the stream until it ends, closes it and returns the accumulated response.
+// If the stream fails, the response accumulated so far is returned together with the error.
+func CollectStream(stream ChatCompletionStream) (*ChatCompletionResponse, error) {
+	if stream == nil {
+		return nil, fmt.Errorf("stream cannot be nil")
+	}
+	defer stream.Close()
+
+	acc := NewChatCompletionAccumulator()
+	for {
+		chunk, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return acc.Response(), nil
+		}
+		if err != nil {
+			return acc.Response(), err
+		}
+		acc.Add(chunk)
+	}
+}
+
+// fimChoice and fimUsage alias the anonymous struct types used by FIMCompletionResponse.
+type fimChoice = struct {
+	Text         string   `json:"text"`
+	Index        int      `json:"index"`
+	Logprobs     Logprobs `json:"logprobs"`
+	FinishReason string   `json:"finish_reason"`
+}
+
+type fimUsage = struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+// FIMCompletionAccumulator rebuilds a FIMCompletionResponse from the chunks of a FIM completion stream.
+type FIMCompletionAccumulator struct {
+	id      string
+	created int64
+	model   string
+	usage   *StreamUsage
+	choices map[int]*fimChoice
+}
+
+// NewFIMCompletionAccumulator creates an empty FIMCompletionAccumulator.
+func NewFIMCompletionAccumulator() *FIMCompletionAccumulator {
+	return &FIMCompletionAccumulator{choices: make(map[int]*fimChoice)}
+}
+
+// Add merges a stream chunk into the accumulator.
+func (a *FIMCompletionAccumulator) Add(chunk *FIMStreamCompletionResponse) {
+	if chunk == nil {
+		return
+	}
+	if a.id == "" {
+		a.id = chunk.ID
+	}
+	if a.created == 0 {
+		a.created = chunk.Created
+	}
+	if a.model == "" {
+		a.model = chunk.Model
+	}
+	if chunk.Usage != nil && *chunk.Usage != (StreamUsage{}) {
+		usage := *chunk.Usage
+		a.usage = &usage
+	}
+
+	for _, sc := range chunk.Choices {
+		choice, ok := a.choices[sc.Index]
+		if !ok {
+			choice = &fimChoice{Index: sc.Index}
+			a.choices[sc.Index] = choice
+		}
+		choice.Text += sc.Text
+		choice.Logprobs.Content = append(choice.Logprobs.Content, sc.Logprobs.Content...)
+		if reason, ok := sc.FinishReason.(string); ok && reason != "" {
+			choice.FinishReason = reason
+		}
+	}
+}
+
+// Response returns the accumulated response in the same shape CreateFIMCompletion returns.
+func (a *FIMCompletionAccumulator) Response() *FIMCompletionResponse {
+	resp := &FIMCompletionResponse{
+		ID:      a.id,
+		Object:  "text_completion",
+		Created: int(a.created),
+		Model:   a.model,
+		Choices: make([]fimChoice, 0, len(a.choices)),
+	}
+	if a.usage != nil {
+		resp.Usage = fimUsage{
+			PromptTokens:     a.usage.PromptTokens,
+			CompletionTokens: a.usage.CompletionTokens,
+			TotalTokens:      a.usage.TotalTokens,
+		}
+	}
+
+	indexes := make([]int, 0, len(a.choices))
+	for index := range a.choices {
+		indexes = append(indexes, index)
+	}
+	sort.Ints(indexes)
+	for _, index := range indexes {
+		resp.Choices = append(resp.Choices, *a.choices[index])
+	}
+	return resp
+}
+
+// CollectFIMStream reads the stream until it ends, closes it and returns the accumulated response.
+// If the stream fails, the response accumulated so far is returned together with the error.
+func CollectFIMStream(stream FIMChatCompletionStream) (*FIMCompletionResponse, error) {
+	if stream == nil {
+		return nil, fmt.Errorf("stream cannot be nil")
+	}
+	defer stream.FIMClose()
+
+	acc := NewFIMCompletionAccumulator()
+	for {
+		chunk, err := stream.FIMRecv()
+		if errors.Is(err, io.EOF) {
+			return acc.Response(), nil
+		}
+		if err != nil {
+			return acc.Response(), err
+		}
+		acc.Add(chunk)
+	}
+}
@@ -0,0 +1,132 @@
+package deepseek_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cohesion-org/deepseek-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChatCompletionAccumulator(t *testing.T) {
+	acc := deepseek.NewChatCompletionAccumulator()
+	acc.Add(&deepseek.StreamChatCompletionResponse{
+		ID: "chat-1", Model: deepseek.DeepSeekReasoner, Created: 10,
+		Choices: []deepseek.StreamChoices{{Index: 0, Delta: deepseek.StreamDelta{Role: "assistant", ReasoningContent: "think "}}},
+		Usage:   &deepseek.StreamUsage{},
+	})
+	acc.Add(&deepseek.StreamChatCompletionResponse{
+		Choices: []deepseek.StreamChoices{{Index: 0, Delta: deepseek.StreamDelta{
+			ReasoningContent: "hard",
+			Content:          "Hello",
+			ToolCalls: []deepseek.ToolCall{
+				{Index: 1, ID: "call_b", Type: "function", Function: deepseek.ToolCallFunction{Name: "b", Arguments: "{"}},
+				{Index: 0, ID: "call_a", Type: "function", Function: deepseek.ToolCallFunction{Name: "a", Arguments: `{"x":`}},
+			},
+		}}},
+	})
+	acc.Add(&deepseek.StreamChatCompletionResponse{
+		Choices: []deepseek.StreamChoices{{Index: 0, FinishReason: "tool_calls", Delta: deepseek.StreamDelta{
+			Content: ", world",
+			ToolCalls: []deepseek.ToolCall{
+				{Index: 0, Function: deepseek.ToolCallFunction{Arguments: "1}"}},
+				{Index: 1, Function: deepseek.ToolCallFunction{Arguments: "}"}},
+			},
+		}}},
+		Usage: &deepseek.StreamUsage{PromptTokens: 3, CompletionTokens: 4, TotalTokens: 7, PromptCacheHitTokens: 1},
+	})
+
+	resp := acc.Response()
+	assert.Equal(t, "chat-1", resp.ID)
+	assert.Equal(t, "chat.completion", resp.Object)
+	assert.Equal(t, int64(10), resp.Created)
+	assert.Equal(t, deepseek.DeepSeekReasoner, resp.Model)
+	assert.Equal(t, deepseek.Usage{PromptTokens: 3, CompletionTokens: 4, TotalTokens: 7, PromptCacheHitTokens: 1}, resp.Usage)
+
+	require.Len(t, resp.Choices, 1)
+	choice := resp.Choices[0]
+	assert.Equal(t, "tool_calls", choice.FinishReason)
+	assert.Equal(t, "assistant", choice.Message.Role)
+	assert.Equal(t, "Hello, world", choice.Message.Content)
+	assert.Equal(t, "think hard", choice.Message.ReasoningContent)
+	assert.Equal(t, []deepseek.ToolCall{
+		{Index: 0, ID: "call_a", Type: "function", Function: deepseek.ToolCallFunction{Name: "a", Arguments: `{"x":1}`}},
+		{Index: 1, ID: "call_b", Type: "function", Function: deepseek.ToolCallFunction{Name: "b", Arguments: "{}"}},
+	}, choice.Message.ToolCalls)
+}
+
+func TestCollectStream(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(
+			"data: {\"id\":\"1\",\"model\":\"deepseek-chat\",\"choices\":[{\"index\":0,\"delta\":{\"role\":\"assistant\",\"content\":\"Hel\"}}]}\n\n" +
+				"data: {\"id\":\"1\",\"model\":\"deepseek-chat\",\"choices\":[{\"index\":0,\"delta\":{\"content\":\"lo\"},\"finish_reason\":\"stop\"}],\"usage\":{\"prompt_tokens\":1,\"completion_tokens\":2,\"total_tokens\":3}}\n\n" +
+				"data: [DONE]\n\n"))
+	}))
+	defer ts.Close()
+
+	client, err := deepseek.NewClientWithOptions("token", deepseek.WithBaseURL(ts.URL+"/"))
+	require.NoError(t, err)
+
+	stream, err := client.CreateChatCompletionStream(context.Background(), &deepseek.StreamChatCompletionRequest{})
+	require.NoError(t, err)
+
+	resp, err := deepseek.CollectStream(stream)
+	require.NoError(t, err)
+	assert.Equal(t, "Hello", resp.Choices[0].Message.Content)
+	assert.Equal(t, "stop", resp.Choices[0].FinishReason)
+	assert.Equal(t, 3, resp.Usage.TotalTokens)
+}
+
+type failingStream struct {
+	chunks []*deepseek.StreamChatCompletionResponse
+	closed bool
+}
+
+func (s *failingStream) Recv() (*deepseek.StreamChatCompletionResponse, error) {
+	if len(s.chunks) == 0 {
+		return nil, errors.New("connection reset")
+	}
+	chunk := s.chunks[0]
+	s.chunks = s.chunks[1:]
+	return chunk, nil
+}
+
+func (s *failingStream) Close() error {
+	s.closed = true
+	return nil
+}
+
+func TestCollectStreamPartial(t *testing.T) {
+	stream := &failingStream{chunks: []*deepseek.StreamChatCompletionResponse{
+		{ID: "1", Choices: []deepseek.StreamChoices{{Delta: deepseek.StreamDelta{Content: "partial"}}}},
+	}}
+	resp, err := deepseek.CollectStream(stream)
+	require.Error(t, err)
+	assert.Equal(t, "partial", resp.Choices[0].Message.Content)
+	assert.True(t, stream.closed)
+}
+
+func TestFIMCompletionAccumulator(t *testing.T) {
+	acc := deepseek.NewFIMCompletionAccumulator()
+	acc.Add(&deepseek.FIMStreamCompletionResponse{
+		ID: "fim-1", Model: deepseek.DeepSeekChat, Created: 5,
+		Choices: []deepseek.FIMStreamChoice{{Index: 0, Text: "func "}},
+	})
+	acc.Add(&deepseek.FIMStreamCompletionResponse{
+		Choices: []deepseek.FIMStreamChoice{{Index: 0, Text: "main()", FinishReason: "stop"}},
+		Usage:   &deepseek.StreamUsage{PromptTokens: 2, CompletionTokens: 3, TotalTokens: 5},
+	})
+
+	resp := acc.Response()
+	assert.Equal(t, "fim-1", resp.ID)
+	assert.Equal(t, "text_completion", resp.Object)
+	assert.Equal(t, 5, resp.Created)
+	require.Len(t, resp.Choices, 1)
+	assert.Equal(t, "func main()", resp.Choices[0].Text)
+	assert.Equal(t, "stop", resp.Choices[0].FinishReason)
+	assert.Equal(t, 5, resp.Usage.TotalTokens)
+}
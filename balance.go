@@ -24,7 +24,30 @@ type BalanceResponse struct {
 }
 
 // GetBalance sends a request to the API to get the user's balance.
+// It is only available on the DeepSeek API and returns ErrUnsupportedByProvider for other providers.
 func GetBalance(c *Client, ctx context.Context) (*BalanceResponse, error) {
+	result, err := c.runMiddleware(ctx, &Call{Operation: OperationGetBalance},
+		func(ctx context.Context, call *Call) (*Result, error) {
+			balance, err := getBalance(c, ctx)
+			if err != nil {
+				return nil, err
+			}
+			return &Result{Balance: balance}, nil
+		})
+	if err != nil {
+		return nil, err
+	}
+	if result.Balance == nil {
+		return nil, ErrUnexpectedResponseFormat
+	}
+	return result.Balance, nil
+}
+
+// getBalance sends the balance request without running the client's middleware.
+func getBalance(c *Client, ctx context.Context) (*BalanceResponse, error) {
+	if err := c.requireDeepSeek("the balance"); err != nil {
+		return nil, err
+	}
 
 	req, err := utils.NewRequestBuilder(c.AuthToken).
 		SetBaseURL("https://api.deepseek.com/").
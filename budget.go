@@ -0,0 +1,422 @@
+package deepseek
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Amount is an exact amount of money in millionths of a currency unit.
+type Amount int64
+
+// ParseAmount parses a decimal string such as "110.00" as returned in BalanceInfo.
+// At most six fractional digits are kept; further digits are truncated.
+func ParseAmount(s string) (Amount, error) {
+	s = strings.TrimSpace(s)
+	negative := strings.HasPrefix(s, "-")
+	digits := strings.TrimPrefix(strings.TrimPrefix(s, "-"), "+")
+	whole, frac, _ := strings.Cut(digits, ".")
+	if whole == "" && frac == "" {
+		return 0, fmt.Errorf("invalid amount %q", s)
+	}
+	if len(frac) > 6 {
+		frac = frac[:6]
+	}
+	frac += strings.Repeat("0", 6-len(frac))
+	if whole == "" {
+		whole = "0"
+	}
+	w, err := strconv.ParseInt(whole, 10, 64)
+	if err != nil || strings.ContainsAny(whole, "+-") {
+		return 0, fmt.Errorf("invalid amount %q", s)
+	}
+	f, err := strconv.ParseInt(frac, 10, 64)
+	if err != nil || strings.ContainsAny(frac, "+-") {
+		return 0, fmt.Errorf("invalid amount %q", s)
+	}
+	if w > (math.MaxInt64-f)/1e6 {
+		return 0, fmt.Errorf("amount %q out of range", s)
+	}
+	a := Amount(w*1e6 + f)
+	if negative {
+		a = -a
+	}
+	return a, nil
+}
+
+// AmountFromFloat converts a float such as Cost.Total to an Amount, rounding to the nearest millionth.
+func AmountFromFloat(f float64) Amount {
+	return Amount(math.Round(f * 1e6))
+}
+
+// Float64 returns the amount as a float.
+func (a Amount) Float64() float64 {
+	return float64(a) / 1e6
+}
+
+// String formats the amount with two decimals, or more if needed.
+func (a Amount) String() string {
+	sign := ""
+	if a < 0 {
+		sign = "-"
+		a = -a
+	}
+	frac := strings.TrimRight(fmt.Sprintf("%06d", int64(a)%1e6), "0")
+	for len(frac) < 2 {
+		frac += "0"
+	}
+	return fmt.Sprintf("%s%d.%s", sign, int64(a)/1e6, frac)
+}
+
+// Reasons a BudgetGuard refuses a call.
+const (
+	BudgetReasonFloor       = "floor"         // The balance would fall below the floor.
+	BudgetReasonPeriod      = "period_budget" // The spend of the current period would exceed the budget.
+	BudgetReasonUnavailable = "unavailable"   // The API reports that the balance is insufficient.
+)
+
+// ErrBudgetExceeded is matched by every BudgetExceededError.
+var ErrBudgetExceeded = errors.New("budget exceeded")
+
+// BudgetExceededError is returned when a BudgetGuard refuses a call.
+type BudgetExceededError struct {
+	Reason    string // One of the BudgetReason constants.
+	Currency  string // Currency of the amounts.
+	Balance   Amount // Estimated balance before the call.
+	Spent     Amount // Spend of the current period before the call.
+	Estimated Amount // Estimated price of the refused call.
+	Limit     Amount // The floor or period budget that would be crossed.
+}
+
+// Error returns a description of the refusal.
+func (e *BudgetExceededError) Error() string {
+	switch e.Reason {
+	case BudgetReasonFloor:
+		return fmt.Sprintf("budget exceeded: balance %s %s minus estimated %s would fall below floor %s",
+			e.Balance, e.Currency, e.Estimated, e.Limit)
+	case BudgetReasonPeriod:
+		return fmt.Sprintf("budget exceeded: period spend %s %s plus estimated %s would exceed budget %s",
+			e.Spent, e.Currency, e.Estimated, e.Limit)
+	default:
+		return "budget exceeded: balance is not available for API calls"
+	}
+}
+
+// Is reports whether target is ErrBudgetExceeded.
+func (e *BudgetExceededError) Is(target error) bool {
+	return target == ErrBudgetExceeded
+}
+
+// BudgetGuard refuses calls before the account balance falls below a floor or the spend of a period exceeds
+// a budget. The balance is polled with GetBalance at most once per PollInterval, when a call is made; between
+// polls the price of every call is subtracted locally. A failed poll is also only retried after PollInterval.
+// Only prices in Currency are counted. A guard may be shared by several clients.
+type BudgetGuard struct {
+	Currency     string        // Currency of the balance and the limits. Defaults to "USD".
+	Floor        Amount        // Calls that would bring the balance below this amount are refused.
+	PeriodBudget Amount        // Maximum spend per Period. Zero means no period budget.
+	Period       UsageWindow   // Period of PeriodBudget. Defaults to UsageWindowMonth.
+	PollInterval time.Duration // Minimum time between two balance polls. Defaults to 5 minutes.
+	WarnBelow    Amount        // OnLowBalance is called when the balance falls below this amount.
+
+	OnLowBalance  func(currency string, balance Amount) // Called once each time the balance falls below WarnBelow.
+	OnUnavailable func(balance *BalanceResponse)        // Called when a poll reports IsAvailable == false.
+
+	// FetchBalance polls the balance. If nil, calls of a guarded client poll with GetBalance and that client,
+	// and Allow and Refresh fail.
+	FetchBalance func(ctx context.Context) (*BalanceResponse, error)
+
+	mu          sync.Mutex
+	polled      time.Time
+	available   bool
+	balances    map[string]Amount // Balances of the last poll.
+	sincePoll   map[string]Amount // Spend since the last poll.
+	period      string            // Key of the current period.
+	periodSpent Amount
+	warned      bool
+	poll        *balancePoll // The poll started by Allow that is in progress, if any.
+	pollErr     error        // Error of the last poll started by Allow, if it failed.
+}
+
+// balancePoll is a balance poll shared by concurrent calls to Allow.
+type balancePoll struct {
+	done chan struct{} // Closed when the poll finished.
+	err  error         // Error of the poll, set before done is closed.
+}
+
+// NewBudgetGuard creates a guard that keeps the balance in currency above floor.
+func NewBudgetGuard(currency string, floor Amount) *BudgetGuard {
+	return &BudgetGuard{Currency: currency, Floor: floor}
+}
+
+// WithBudgetGuard refuses calls that would exceed the guard's limits. GetBalance is only available on the
+// DeepSeek API, so set FetchBalance when the client uses another provider.
+func WithBudgetGuard(guard *BudgetGuard) Option {
+	return func(c *Client) error {
+		if guard == nil {
+			return fmt.Errorf("budget guard cannot be nil")
+		}
+		c.Budget = guard
+		return nil
+	}
+}
+
+// Refresh polls the balance now with FetchBalance.
+func (g *BudgetGuard) Refresh(ctx context.Context) error {
+	return g.refresh(ctx, g.FetchBalance)
+}
+
+// refresh polls the balance now with fetch.
+func (g *BudgetGuard) refresh(ctx context.Context, fetch func(ctx context.Context) (*BalanceResponse, error)) error {
+	if fetch == nil {
+		return fmt.Errorf("budget guard has no balance source")
+	}
+	resp, err := fetch(ctx)
+	if err != nil {
+		return fmt.Errorf("polling balance: %w", err)
+	}
+	balances := make(map[string]Amount, len(resp.BalanceInfos))
+	for _, info := range resp.BalanceInfos {
+		amount, err := ParseAmount(info.TotalBalance)
+		if err != nil {
+			return fmt.Errorf("polling balance: %w", err)
+		}
+		balances[info.Currency] = amount
+	}
+
+	g.mu.Lock()
+	g.polled = time.Now()
+	g.available = resp.IsAvailable
+	g.balances = balances
+	g.sincePoll = nil
+	g.pollErr = nil
+	warn := g.checkLowBalanceLocked()
+	g.mu.Unlock()
+
+	if !resp.IsAvailable && g.OnUnavailable != nil {
+		g.OnUnavailable(resp)
+	}
+	warn()
+	return nil
+}
+
+// Balance returns the estimated balance in the guard's currency: the last polled balance minus the local
+// spend since. It reports false if no balance has been polled yet.
+func (g *BudgetGuard) Balance() (Amount, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.balances == nil {
+		return 0, false
+	}
+	return g.balanceLocked(), true
+}
+
+// PeriodSpent returns the local spend in the current period.
+func (g *BudgetGuard) PeriodSpent() Amount {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.rollPeriodLocked(time.Now())
+	return g.periodSpent
+}
+
+// Allow polls the balance with FetchBalance if it is due and checks whether a call with the estimated price
+// may be made. It returns a *BudgetExceededError if not.
+func (g *BudgetGuard) Allow(ctx context.Context, estimated Amount) error {
+	return g.allow(ctx, g.FetchBalance, estimated)
+}
+
+// allow is Allow polling the balance with fetch.
+func (g *BudgetGuard) allow(ctx context.Context, fetch func(ctx context.Context) (*BalanceResponse, error), estimated Amount) error {
+	if err := g.refreshIfDue(ctx, fetch); err != nil {
+		return err
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.rollPeriodLocked(time.Now())
+	currency := g.currency()
+	balance := g.balanceLocked()
+	if !g.available {
+		return &BudgetExceededError{Reason: BudgetReasonUnavailable, Currency: currency, Balance: balance, Spent: g.periodSpent, Estimated: estimated}
+	}
+	if balance-estimated < g.Floor {
+		return &BudgetExceededError{Reason: BudgetReasonFloor, Currency: currency, Balance: balance, Spent: g.periodSpent, Estimated: estimated, Limit: g.Floor}
+	}
+	if g.PeriodBudget > 0 && g.periodSpent+estimated > g.PeriodBudget {
+		return &BudgetExceededError{Reason: BudgetReasonPeriod, Currency: currency, Balance: balance, Spent: g.periodSpent, Estimated: estimated, Limit: g.PeriodBudget}
+	}
+	return nil
+}
+
+// refreshIfDue polls the balance if no poll was made within PollInterval. Concurrent callers share one poll:
+// while it runs, callers that know an earlier balance go on with it and the others wait for the result.
+// A failed poll is only an error if no balance is known, since the guard then cannot vouch for the call;
+// until the next poll is due, such callers get the error of the failed one.
+func (g *BudgetGuard) refreshIfDue(ctx context.Context, fetch func(ctx context.Context) (*BalanceResponse, error)) error {
+	g.mu.Lock()
+	due := g.polled.IsZero() || time.Since(g.polled) >= g.pollInterval()
+	hasBalance := g.balances != nil
+	poll := g.poll
+	if !due && !hasBalance {
+		err := g.pollErr
+		g.mu.Unlock()
+		return err
+	}
+	if !due || (poll != nil && hasBalance) {
+		g.mu.Unlock()
+		return nil
+	}
+	if poll != nil {
+		g.mu.Unlock()
+		select {
+		case <-poll.done:
+			return poll.err
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	poll = &balancePoll{done: make(chan struct{})}
+	g.poll = poll
+	g.mu.Unlock()
+
+	err := g.refresh(ctx, fetch)
+	g.mu.Lock()
+	g.poll = nil
+	if err != nil && ctx.Err() == nil {
+		g.polled = time.Now()
+		g.pollErr = err
+	}
+	g.mu.Unlock()
+	if hasBalance {
+		err = nil
+	}
+	poll.err = err
+	close(poll.done)
+	return err
+}
+
+// Spend records the price of a finished call.
+func (g *BudgetGuard) Spend(cost Cost) {
+	g.mu.Lock()
+	amount := AmountFromFloat(cost.Total)
+	if g.sincePoll == nil {
+		g.sincePoll = make(map[string]Amount)
+	}
+	g.sincePoll[cost.Currency] += amount
+	if cost.Currency == g.currency() {
+		g.rollPeriodLocked(time.Now())
+		g.periodSpent += amount
+	}
+	warn := g.checkLowBalanceLocked()
+	g.mu.Unlock()
+	warn()
+}
+
+// balanceLocked returns the estimated balance. The caller must hold mu.
+func (g *BudgetGuard) balanceLocked() Amount {
+	currency := g.currency()
+	return g.balances[currency] - g.sincePoll[currency]
+}
+
+// rollPeriodLocked starts a new period if now is past the current one. The caller must hold mu.
+func (g *BudgetGuard) rollPeriodLocked(now time.Time) {
+	period := g.Period
+	if period == 0 {
+		period = UsageWindowMonth
+	}
+	key := GroupByWindow(period)(UsageRecord{Time: now})
+	if key != g.period {
+		g.period = key
+		g.periodSpent = 0
+	}
+}
+
+// checkLowBalanceLocked returns a function that calls OnLowBalance if the balance just fell below WarnBelow.
+// The function must be called after mu is released.
+func (g *BudgetGuard) checkLowBalanceLocked() func() {
+	if g.OnLowBalance == nil || g.balances == nil {
+		return func() {}
+	}
+	balance := g.balanceLocked()
+	if balance >= g.WarnBelow {
+		g.warned = false
+		return func() {}
+	}
+	if g.warned {
+		return func() {}
+	}
+	g.warned = true
+	currency := g.currency()
+	return func() { g.OnLowBalance(currency, balance) }
+}
+
+// currency returns the guard's currency.
+func (g *BudgetGuard) currency() string {
+	if g.Currency == "" {
+		return "USD"
+	}
+	return g.Currency
+}
+
+// pollInterval returns the minimum time between two polls.
+func (g *BudgetGuard) pollInterval() time.Duration {
+	if g.PollInterval <= 0 {
+		return 5 * time.Minute
+	}
+	return g.PollInterval
+}
+
+// checkBudget asks the client's budget guard whether the call may be made.
+func (c *Client) checkBudget(ctx context.Context, call *Call) error {
+	if c.Budget == nil {
+		return nil
+	}
+	var model string
+	var usage Usage
+	switch {
+	case call.ChatRequest != nil:
+		model = call.ChatRequest.Model
+		usage.PromptTokens = EstimateRequestTokens(call.ChatRequest)
+		usage.CompletionTokens = call.ChatRequest.MaxTokens
+	case call.StreamRequest != nil:
+		model = call.StreamRequest.Model
+		usage.PromptTokens = EstimateRequestTokens(&ChatCompletionRequest{Messages: call.StreamRequest.Messages, Tools: call.StreamRequest.Tools})
+		usage.CompletionTokens = call.StreamRequest.MaxTokens
+	case call.FIMRequest != nil:
+		model = call.FIMRequest.Model
+		usage.PromptTokens = EstimateTokenCount(call.FIMRequest.Prompt + call.FIMRequest.Suffix).EstimatedTokens
+		usage.CompletionTokens = call.FIMRequest.MaxTokens
+	case call.FIMStreamRequest != nil:
+		model = call.FIMStreamRequest.Model
+		usage.PromptTokens = EstimateTokenCount(call.FIMStreamRequest.Prompt + call.FIMStreamRequest.Suffix).EstimatedTokens
+		usage.CompletionTokens = call.FIMStreamRequest.MaxTokens
+	case call.ImageRequest != nil:
+		model = call.ImageRequest.Model
+		usage.PromptTokens = estimateImageRequestTokens(call.ImageRequest.Messages, call.ImageRequest.Tools)
+		usage.CompletionTokens = call.ImageRequest.MaxTokens
+	case call.ImageStreamRequest != nil:
+		model = call.ImageStreamRequest.Model
+		usage.PromptTokens = estimateImageRequestTokens(call.ImageStreamRequest.Messages, call.ImageStreamRequest.Tools)
+		usage.CompletionTokens = call.ImageStreamRequest.MaxTokens
+	}
+	if caps, ok := c.modelRegistry().Lookup(model); ok && usage.CompletionTokens <= 0 {
+		usage.CompletionTokens = caps.DefaultOutputTokens
+	}
+
+	var estimated Amount
+	if cost, err := c.EstimateCost(model, usage); err == nil && cost.Currency == c.Budget.currency() {
+		estimated = AmountFromFloat(cost.Total)
+	}
+	fetch := c.Budget.FetchBalance
+	if fetch == nil {
+		fetch = func(ctx context.Context) (*BalanceResponse, error) {
+			return GetBalance(c, ctx)
+		}
+	}
+	return c.Budget.allow(ctx, fetch, estimated)
+}
@@ -0,0 +1,262 @@
+package deepseek_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cohesion-org/deepseek-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseAmount(t *testing.T) {
+	tests := []struct {
+		in   string
+		want deepseek.Amount
+	}{
+		{"110.00", 110_000_000},
+		{"0.5", 500_000},
+		{"-1.25", -1_250_000},
+		{"3", 3_000_000},
+		{".75", 750_000},
+		{"0.1234567", 123_456},
+	}
+	for _, tt := range tests {
+		got, err := deepseek.ParseAmount(tt.in)
+		require.NoError(t, err, tt.in)
+		assert.Equal(t, tt.want, got, tt.in)
+	}
+	for _, in := range []string{"", "abc", "1.2.3", "1e5", "--1", "1.-5"} {
+		_, err := deepseek.ParseAmount(in)
+		assert.Error(t, err, in)
+	}
+	assert.Equal(t, "110.00", deepseek.Amount(110_000_000).String())
+	assert.Equal(t, "-0.125", deepseek.Amount(-125_000).String())
+	assert.Equal(t, deepseek.Amount(1_500_000), deepseek.AmountFromFloat(1.5))
+}
+
+// fakeBalance returns a FetchBalance function reporting the given total balance and counting its calls.
+func fakeBalance(total string, available bool, polls *int32) func(ctx context.Context) (*deepseek.BalanceResponse, error) {
+	return func(ctx context.Context) (*deepseek.BalanceResponse, error) {
+		atomic.AddInt32(polls, 1)
+		return &deepseek.BalanceResponse{
+			IsAvailable: available,
+			BalanceInfos: []deepseek.BalanceInfo{
+				{Currency: "CNY", TotalBalance: "500.00"},
+				{Currency: "USD", TotalBalance: total},
+			},
+		}, nil
+	}
+}
+
+func TestBudgetGuard(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("floor", func(t *testing.T) {
+		var polls int32
+		var warnings []deepseek.Amount
+		guard := deepseek.NewBudgetGuard("USD", 5_000_000)
+		guard.WarnBelow = 8_000_000
+		guard.OnLowBalance = func(currency string, balance deepseek.Amount) { warnings = append(warnings, balance) }
+		guard.FetchBalance = fakeBalance("10.00", true, &polls)
+
+		require.NoError(t, guard.Allow(ctx, 1_000_000))
+		guard.Spend(deepseek.Cost{Total: 3, Currency: "USD"})
+		guard.Spend(deepseek.Cost{Total: 100, Currency: "CNY"})
+		balance, ok := guard.Balance()
+		require.True(t, ok)
+		assert.Equal(t, deepseek.Amount(7_000_000), balance)
+		assert.Equal(t, []deepseek.Amount{7_000_000}, warnings)
+
+		err := guard.Allow(ctx, 2_500_000)
+		var budgetErr *deepseek.BudgetExceededError
+		require.ErrorAs(t, err, &budgetErr)
+		require.ErrorIs(t, err, deepseek.ErrBudgetExceeded)
+		assert.Equal(t, deepseek.BudgetReasonFloor, budgetErr.Reason)
+		assert.Equal(t, deepseek.Amount(7_000_000), budgetErr.Balance)
+		assert.Equal(t, int32(1), atomic.LoadInt32(&polls), "the balance is cached for the poll interval")
+
+		guard.Spend(deepseek.Cost{Total: 0.5, Currency: "USD"})
+		assert.Len(t, warnings, 1, "the warning is only sent once")
+
+		require.NoError(t, guard.Refresh(ctx))
+		balance, _ = guard.Balance()
+		assert.Equal(t, deepseek.Amount(10_000_000), balance, "a poll replaces the local estimate")
+	})
+
+	t.Run("period budget", func(t *testing.T) {
+		var polls int32
+		guard := deepseek.NewBudgetGuard("USD", 0)
+		guard.PeriodBudget = 2_000_000
+		guard.Period = deepseek.UsageWindowDay
+		guard.FetchBalance = fakeBalance("100.00", true, &polls)
+
+		require.NoError(t, guard.Allow(ctx, 1_000_000))
+		guard.Spend(deepseek.Cost{Total: 1.5, Currency: "USD"})
+		assert.Equal(t, deepseek.Amount(1_500_000), guard.PeriodSpent())
+
+		err := guard.Allow(ctx, 1_000_000)
+		var budgetErr *deepseek.BudgetExceededError
+		require.ErrorAs(t, err, &budgetErr)
+		assert.Equal(t, deepseek.BudgetReasonPeriod, budgetErr.Reason)
+		assert.Equal(t, deepseek.Amount(2_000_000), budgetErr.Limit)
+	})
+
+	t.Run("unavailable", func(t *testing.T) {
+		var polls int32
+		var notified bool
+		guard := deepseek.NewBudgetGuard("USD", 0)
+		guard.OnUnavailable = func(*deepseek.BalanceResponse) { notified = true }
+		guard.FetchBalance = fakeBalance("0.00", false, &polls)
+
+		err := guard.Allow(ctx, 0)
+		var budgetErr *deepseek.BudgetExceededError
+		require.ErrorAs(t, err, &budgetErr)
+		assert.Equal(t, deepseek.BudgetReasonUnavailable, budgetErr.Reason)
+		assert.True(t, notified)
+	})
+
+	t.Run("no balance known", func(t *testing.T) {
+		var polls int32
+		guard := deepseek.NewBudgetGuard("USD", 0)
+		guard.FetchBalance = func(ctx context.Context) (*deepseek.BalanceResponse, error) {
+			atomic.AddInt32(&polls, 1)
+			return nil, errors.New("network down")
+		}
+		require.ErrorContains(t, guard.Allow(ctx, 0), "network down")
+		require.ErrorContains(t, guard.Allow(ctx, 0), "network down")
+		assert.Equal(t, int32(1), atomic.LoadInt32(&polls), "a failed poll is not repeated before it is due")
+	})
+
+	t.Run("failed poll keeps the known balance", func(t *testing.T) {
+		var polls int32
+		guard := deepseek.NewBudgetGuard("USD", 0)
+		guard.PollInterval = 20 * time.Millisecond
+		fetch := fakeBalance("10.00", true, &polls)
+		guard.FetchBalance = func(ctx context.Context) (*deepseek.BalanceResponse, error) {
+			if atomic.LoadInt32(&polls) > 0 {
+				atomic.AddInt32(&polls, 1)
+				return nil, errors.New("network down")
+			}
+			return fetch(ctx)
+		}
+
+		require.NoError(t, guard.Allow(ctx, 0))
+		time.Sleep(30 * time.Millisecond)
+		require.NoError(t, guard.Allow(ctx, 0))
+		require.NoError(t, guard.Allow(ctx, 0))
+		assert.Equal(t, int32(2), atomic.LoadInt32(&polls), "a failed poll is not repeated before it is due")
+	})
+
+	t.Run("concurrent calls share one poll", func(t *testing.T) {
+		var polls int32
+		release := make(chan struct{})
+		guard := deepseek.NewBudgetGuard("USD", 0)
+		fetch := fakeBalance("10.00", true, &polls)
+		guard.FetchBalance = func(ctx context.Context) (*deepseek.BalanceResponse, error) {
+			<-release
+			return fetch(ctx)
+		}
+
+		var wg sync.WaitGroup
+		errs := make(chan error, 10)
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				errs <- guard.Allow(ctx, 0)
+			}()
+		}
+		time.Sleep(20 * time.Millisecond)
+		close(release)
+		wg.Wait()
+		close(errs)
+		for err := range errs {
+			require.NoError(t, err)
+		}
+		assert.Equal(t, int32(1), atomic.LoadInt32(&polls))
+	})
+}
+
+func TestClientBudgetGuard(t *testing.T) {
+	var requests int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.Write([]byte(`{"id":"1","object":"chat.completion","model":"deepseek-chat",` +
+			`"choices":[{"index":0,"message":{"role":"assistant","content":"hi"},"finish_reason":"stop"}],` +
+			`"usage":{"prompt_tokens":10000,"completion_tokens":10000,"total_tokens":20000}}`))
+	}))
+	defer ts.Close()
+
+	var polls int32
+	guard := deepseek.NewBudgetGuard("USD", 0)
+	guard.FetchBalance = fakeBalance("0.01", true, &polls)
+	client, err := deepseek.NewClientWithOptions("token", deepseek.WithBaseURL(ts.URL+"/"), deepseek.WithBudgetGuard(guard))
+	require.NoError(t, err)
+
+	request := &deepseek.ChatCompletionRequest{
+		Model:    deepseek.DeepSeekChat,
+		Messages: []deepseek.ChatCompletionMessage{{Role: deepseek.ChatMessageRoleUser, Content: "hi"}},
+	}
+	_, err = client.CreateChatCompletion(context.Background(), request)
+	require.NoError(t, err)
+	balance, _ := guard.Balance()
+	assert.Equal(t, deepseek.Amount(3_000), balance, "the price of the call is subtracted")
+
+	// The default output of deepseek-reasoner would cost more than the remaining balance.
+	request.Model = deepseek.DeepSeekReasoner
+	_, err = client.CreateChatCompletion(context.Background(), request)
+	require.ErrorIs(t, err, deepseek.ErrBudgetExceeded)
+	_, err = client.CreateChatCompletionWithImage(context.Background(), &deepseek.ChatCompletionRequestWithImage{
+		Model:    deepseek.DeepSeekReasoner,
+		Messages: []deepseek.ChatCompletionMessageWithImage{{Role: deepseek.ChatMessageRoleUser, Content: "hi"}},
+	})
+	require.ErrorIs(t, err, deepseek.ErrBudgetExceeded, "image calls are guarded too")
+	assert.Equal(t, int32(1), atomic.LoadInt32(&requests), "refused calls are not sent")
+
+	_, err = deepseek.NewClientWithOptions("token", deepseek.WithBudgetGuard(nil))
+	require.Error(t, err)
+}
+
+// budgetDoer answers balance requests with an available balance and other requests with a chat completion.
+// It records the authorization header of the balance requests.
+type budgetDoer struct {
+	mu             sync.Mutex
+	authorizations []string
+}
+
+func (d *budgetDoer) Do(req *http.Request) (*http.Response, error) {
+	body := retryChatResponse
+	if req.URL.Path == "/user/balance" {
+		d.mu.Lock()
+		d.authorizations = append(d.authorizations, req.Header.Get("Authorization"))
+		d.mu.Unlock()
+		body = `{"is_available":true,"balance_infos":[{"currency":"USD","total_balance":"5.00"}]}`
+	}
+	return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(body)), Header: http.Header{}}, nil
+}
+
+func TestSharedBudgetGuard(t *testing.T) {
+	guard := deepseek.NewBudgetGuard("USD", 0)
+	guard.PollInterval = time.Nanosecond
+	doer := &budgetDoer{}
+	first, err := deepseek.NewClientWithOptions("key-a", deepseek.WithHTTPClient(doer), deepseek.WithBudgetGuard(guard))
+	require.NoError(t, err)
+	second, err := deepseek.NewClientWithOptions("key-b", deepseek.WithHTTPClient(doer), deepseek.WithBudgetGuard(guard))
+	require.NoError(t, err)
+
+	for _, client := range []*deepseek.Client{first, second} {
+		_, err := client.CreateChatCompletion(context.Background(), chatRequest(deepseek.DeepSeekChat))
+		require.NoError(t, err)
+	}
+	assert.Equal(t, []string{"Bearer key-a", "Bearer key-b"}, doer.authorizations, "each client polls with its own key")
+	assert.Nil(t, guard.FetchBalance, "the shared guard is not modified")
+}
@@ -1,13 +1,10 @@
 package deepseek
 
 import (
-	"bufio"
 	"context"
 	"encoding/json"
 	"fmt"
-	"io"
 	"net/http"
-	"strings"
 )
 
 // StreamChatCompletionMessage represents a single message in a chat completion stream.
@@ -24,10 +21,12 @@ type ChatCompletionStream interface {
 
 // chatCompletionStream implements the ChatCompletionStream interface.
 type chatCompletionStream struct {
-	ctx    context.Context    // Context for cancellation.
-	cancel context.CancelFunc // Cancel function for the context.
-	resp   *http.Response     // HTTP response from the API call.
-	reader *bufio.Reader      // Reader for the response body.
+	ctx      context.Context    // Context for cancellation.
+	cancel   context.CancelFunc // Cancel function for the context.
+	resp     *http.Response     // HTTP response from the API call.
+	decoder  *sseDecoder        // Server-sent event decoder for the response body.
+	release  func()             // Releases the limiter slot held by the stream.
+	watchdog *streamWatchdog    // Enforces the first-token and idle timeouts, nil if disabled.
 }
 
 // StreamOptions provides options for streaming chat completion responses.
@@ -49,6 +48,18 @@ type CompletionTokensDetails struct {
 	ReasoningTokens int `json:"reasoning_tokens"` //Number of tokens generated by inference model
 }
 
+// toUsage converts the stream usage to the Usage of a non-streaming response.
+func (u StreamUsage) toUsage() Usage {
+	return Usage{
+		PromptTokens:            u.PromptTokens,
+		CompletionTokens:        u.CompletionTokens,
+		TotalTokens:             u.TotalTokens,
+		PromptCacheHitTokens:    u.PromptCacheHitTokens,
+		PromptCacheMissTokens:   u.PromptCacheMissTokens,
+		CompletionTokensDetails: u.CompletionTokensDetails,
+	}
+}
+
 // StreamDelta represents a delta in the chat completion stream.
 type StreamDelta struct {
 	Role             string     `json:"role,omitempty"`              // Role of the message.
@@ -94,6 +105,13 @@ type StreamChatCompletionResponse struct {
 	Choices []StreamChoices `json:"choices"`         // Choices generated.
 	Usage   *StreamUsage    `json:"usage,omitempty"` // Usage statistics (optional).
 	Title   string          `json:"title,omitempty"` // Title of the response (optional).
+	Event   *StreamEvent    `json:"-"`               // Server-sent event metadata of the chunk (not part of the JSON payload).
+	Error   *StreamError    `json:"error,omitempty"` // In-band error sent by some providers. Recv returns it as *APIError.
+}
+
+// streamError returns the in-band error of the chunk, if any.
+func (r *StreamChatCompletionResponse) streamError() *StreamError {
+	return r.Error
 }
 
 // StreamChatCompletionRequest represents the request body for a streaming chat completion API call.
@@ -110,47 +128,47 @@ type StreamChatCompletionRequest struct {
 	ResponseFormat   *ResponseFormat         `json:"response_format,omitempty"`   // Optional: Custom response format: just don't try, it breaks rn ;)
 	Stop             []string                `json:"stop,omitempty"`              // Optional: Stop signals
 	Tools            []Tool                  `json:"tools,omitempty"`             // Optional: List of tools
+	ToolChoice       interface{}             `json:"tool_choice,omitempty"`       // Optional: Controls which (if any) tool is called by the model
 	LogProbs         bool                    `json:"logprobs,omitempty"`          // Optional: Enable log probabilities
 	TopLogProbs      int                     `json:"top_logprobs,omitempty"`      // Optional: Number of top tokens with log probabilities, <= 20
+	JSONMode         bool                    `json:"json,omitempty"`              // [deepseek-go feature] Optional: Enable JSON mode
 	EnableThinking   bool                    `json:"enable_thinking,omitempty"`   // Optional: Enable thinking (for qwen3 api)
+	ExtraBody        map[string]any          `json:"-"`                           // Optional: Provider-specific fields merged into the request body
 }
 
 // Recv receives the next response from the stream.
+// In-band error events are returned as *APIError.
 func (s *chatCompletionStream) Recv() (*StreamChatCompletionResponse, error) {
-	reader := s.reader
-	for {
-		line, err := reader.ReadString('\n') // Read until newline
-		if err != nil {
-			if err == io.EOF {
-				return nil, io.EOF
-			}
-			return nil, fmt.Errorf("error reading stream: %w", err)
-		}
-
-		line = strings.TrimSpace(line)
-		if line == "data: [DONE]" {
-			return nil, io.EOF // End of stream
-		}
-		if len(line) > 6 && line[:6] == "data: " {
-			trimmed := line[6:] // Trim the "data: " prefix
-			var response StreamChatCompletionResponse
-			if err := json.Unmarshal([]byte(trimmed), &response); err != nil {
-				return nil, fmt.Errorf("unmarshal error: %w, raw data: %s", err, trimmed)
-			}
-			if response.Usage == nil {
-				response.Usage = &StreamUsage{}
-			}
-			return &response, nil
-		}
+	var response StreamChatCompletionResponse
+	s.watchdog.beforeRead()
+	event, err := s.decoder.decode(s.resp.StatusCode, &response)
+	s.watchdog.afterRead(err == nil)
+	if err != nil {
+		return nil, s.watchdog.wrap(err)
 	}
+	response.Event = event
+	if response.Usage == nil {
+		response.Usage = &StreamUsage{}
+	}
+	return &response, nil
 }
 
 // Close terminates the stream.
 func (s *chatCompletionStream) Close() error {
+	s.watchdog.stop()
 	s.cancel()
+	if s.release != nil {
+		s.release()
+	}
 	err := s.resp.Body.Close()
 	if err != nil {
 		return fmt.Errorf("failed to close response body: %w", err)
 	}
 	return nil
 }
+
+// cancelStream cancels the request of the stream.
+func (s *chatCompletionStream) cancelStream() bool {
+	s.cancel()
+	return true
+}
@@ -1,7 +1,6 @@
 package deepseek
 
 import (
-	"bufio"
 	"context"
 	"fmt"
 
@@ -17,16 +16,173 @@ func (c *Client) CreateChatCompletion(
 		return nil, fmt.Errorf("request cannot be nil")
 	}
 
+	result, err := c.runMiddleware(ctx, &Call{Operation: OperationChatCompletion, ChatRequest: request},
+		func(ctx context.Context, call *Call) (*Result, error) {
+			if err := c.validateCall(call); err != nil {
+				return nil, err
+			}
+			if err := c.checkBudget(ctx, call); err != nil {
+				return nil, err
+			}
+			resp, err := c.createChatCompletion(ctx, call.ChatRequest)
+			if err != nil {
+				return nil, err
+			}
+			c.recordUsage(ctx, call.Operation, call.ChatRequest.Model, resp.Usage)
+			return &Result{ChatResponse: resp}, nil
+		})
+	if err != nil {
+		return nil, err
+	}
+	if result.ChatResponse == nil {
+		return nil, ErrUnexpectedResponseFormat
+	}
+	return result.ChatResponse, nil
+}
+
+// CreateChatCompletionStream sends a chat completion request with stream = true and returns the delta
+func (c *Client) CreateChatCompletionStream(
+	ctx context.Context,
+	request *StreamChatCompletionRequest,
+) (ChatCompletionStream, error) {
+	if request == nil {
+		return nil, fmt.Errorf("request cannot be nil")
+	}
+
+	result, err := c.runMiddleware(ctx, &Call{Operation: OperationChatCompletionStream, StreamRequest: request},
+		func(ctx context.Context, call *Call) (*Result, error) {
+			if err := c.validateCall(call); err != nil {
+				return nil, err
+			}
+			if err := c.checkBudget(ctx, call); err != nil {
+				return nil, err
+			}
+			stream, err := c.createChatCompletionStream(ctx, call.StreamRequest)
+			if err != nil {
+				return nil, err
+			}
+			if c.tracksUsage() {
+				model := call.StreamRequest.Model
+				stream = &usageRecordingChatStream{ChatCompletionStream: stream, record: func(usage Usage) {
+					c.recordUsage(ctx, call.Operation, model, usage)
+				}}
+			}
+			return &Result{Stream: stream}, nil
+		})
+	if err != nil {
+		return nil, err
+	}
+	if result.Stream == nil {
+		return nil, ErrUnexpectedResponseFormat
+	}
+	return result.Stream, nil
+}
+
+// CreateFIMCompletion is a beta feature. It sends a FIM completion request and returns the generated response.
+// the base URL is set to "https://api.deepseek.com/beta/"
+func (c *Client) CreateFIMCompletion(
+	ctx context.Context,
+	request *FIMCompletionRequest,
+) (*FIMCompletionResponse, error) {
+	if request == nil {
+		return nil, fmt.Errorf("request cannot be nil")
+	}
+
+	result, err := c.runMiddleware(ctx, &Call{Operation: OperationFIMCompletion, FIMRequest: request},
+		func(ctx context.Context, call *Call) (*Result, error) {
+			if err := c.validateCall(call); err != nil {
+				return nil, err
+			}
+			if err := c.checkBudget(ctx, call); err != nil {
+				return nil, err
+			}
+			resp, err := c.createFIMCompletion(ctx, call.FIMRequest)
+			if err != nil {
+				return nil, err
+			}
+			c.recordUsage(ctx, call.Operation, call.FIMRequest.Model, Usage{
+				PromptTokens:     resp.Usage.PromptTokens,
+				CompletionTokens: resp.Usage.CompletionTokens,
+				TotalTokens:      resp.Usage.TotalTokens,
+			})
+			return &Result{FIMResponse: resp}, nil
+		})
+	if err != nil {
+		return nil, err
+	}
+	if result.FIMResponse == nil {
+		return nil, ErrUnexpectedResponseFormat
+	}
+	return result.FIMResponse, nil
+}
+
+// CreateFIMStreamCompletion sends a FIM completion request with stream = true and returns the delta
+func (c *Client) CreateFIMStreamCompletion(
+	ctx context.Context,
+	request *FIMStreamCompletionRequest,
+) (FIMChatCompletionStream, error) {
+	if request == nil {
+		return nil, fmt.Errorf("request cannot be nil")
+	}
+
+	result, err := c.runMiddleware(ctx, &Call{Operation: OperationFIMCompletionStream, FIMStreamRequest: request},
+		func(ctx context.Context, call *Call) (*Result, error) {
+			if err := c.validateCall(call); err != nil {
+				return nil, err
+			}
+			if err := c.checkBudget(ctx, call); err != nil {
+				return nil, err
+			}
+			stream, err := c.createFIMStreamCompletion(ctx, call.FIMStreamRequest)
+			if err != nil {
+				return nil, err
+			}
+			if c.tracksUsage() {
+				model := call.FIMStreamRequest.Model
+				stream = &usageRecordingFIMStream{FIMChatCompletionStream: stream, record: func(usage Usage) {
+					c.recordUsage(ctx, call.Operation, model, usage)
+				}}
+			}
+			return &Result{FIMStream: stream}, nil
+		})
+	if err != nil {
+		return nil, err
+	}
+	if result.FIMStream == nil {
+		return nil, ErrUnexpectedResponseFormat
+	}
+	return result.FIMStream, nil
+}
+
+// createChatCompletion sends a chat completion request without running the middleware chain.
+func (c *Client) createChatCompletion(
+	ctx context.Context,
+	request *ChatCompletionRequest,
+) (*ChatCompletionResponse, error) {
+	if request == nil {
+		return nil, fmt.Errorf("request cannot be nil")
+	}
+
 	ctx, tcancel, err := getTimeoutContext(ctx, c.Timeout)
 	if err != nil {
 		return nil, fmt.Errorf("error getting timeout context: %w", err)
 	}
 	defer tcancel()
 
+	ctx, release, err := c.waitLimiter(ctx, EstimateTokensFromMessages(request).EstimatedTokens)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	body, err := c.requestBody(request.ToChatRequest(), request.ExtraBody)
+	if err != nil {
+		return nil, fmt.Errorf("error building request: %w", err)
+	}
 	req, err := utils.NewRequestBuilder(c.AuthToken).
 		SetBaseURL(c.BaseURL).
 		SetPath(c.Path).
-		SetBodyFromStruct(request).
+		SetBodyFromStruct(body).
 		Build(ctx)
 
 	if err != nil {
@@ -52,8 +208,8 @@ func (c *Client) CreateChatCompletion(
 	return updatedResp, nil
 }
 
-// CreateChatCompletionStream sends a chat completion request with stream = true and returns the delta
-func (c *Client) CreateChatCompletionStream(
+// createChatCompletionStream opens a chat completion stream without running the middleware chain.
+func (c *Client) createChatCompletionStream(
 	ctx context.Context,
 	request *StreamChatCompletionRequest,
 ) (ChatCompletionStream, error) {
@@ -61,59 +217,104 @@ func (c *Client) CreateChatCompletionStream(
 		return nil, fmt.Errorf("request cannot be nil")
 	}
 
-	ctx, _, err := getTimeoutContext(ctx, c.Timeout)
+	ctx, tcancel, err := getTimeoutContext(ctx, c.Timeout)
 	if err != nil {
 		return nil, fmt.Errorf("error getting timeout context: %w", err)
 	}
+	ctx, rcancel := context.WithCancel(ctx)
+	cancel := func() { rcancel(); tcancel() }
+
+	ctx, release, err := c.waitLimiter(ctx, EstimateTokensFromMessages(&ChatCompletionRequest{
+		Messages: request.Messages,
+		Tools:    request.Tools,
+	}).EstimatedTokens)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
 
 	request.Stream = true
+	chat := request.ToChatRequest()
+	if c.tracksUsage() {
+		chat.StreamOptions = &StreamOptions{IncludeUsage: true}
+	}
+	body, err := c.requestBody(chat, request.ExtraBody)
+	if err != nil {
+		release()
+		cancel()
+		return nil, fmt.Errorf("error building request: %w", err)
+	}
 	req, err := utils.NewRequestBuilder(c.AuthToken).
 		SetBaseURL(c.BaseURL).
 		SetPath(c.Path).
-		SetBodyFromStruct(request).
+		SetBodyFromStruct(body).
 		BuildStream(ctx)
 
 	if err != nil {
+		release()
+		cancel()
 		return nil, fmt.Errorf("error building request: %w", err)
 	}
 
 	resp, err := HandleSendChatCompletionRequest(*c, req)
 	if err != nil {
+		release()
+		cancel()
 		return nil, fmt.Errorf("error sending request: %w", err)
 	}
 
 	if resp.StatusCode >= 400 {
+		release()
+		defer cancel()
 		return nil, HandleAPIError(resp)
 	}
 
-	ctx, cancel := context.WithCancel(ctx)
+	// The timeouts start with the first read, so retries, backoff and time before the stream is read do not count.
+	watchdog := newStreamWatchdog(rcancel, c.StreamFirstTokenTimeout, c.StreamIdleTimeout)
+	decoder := newSSEDecoder(resp.Body)
+	decoder.onLine = watchdog.lineReceived
+
 	stream := &chatCompletionStream{
-		ctx:    ctx,
-		cancel: cancel,
-		resp:   resp,
-		reader: bufio.NewReader(resp.Body),
+		ctx:      ctx,
+		cancel:   cancel,
+		resp:     resp,
+		decoder:  decoder,
+		release:  release,
+		watchdog: watchdog,
 	}
 	return stream, nil
 }
 
-// CreateFIMCompletion is a beta feature. It sends a FIM completion request and returns the generated response.
-// the base URL is set to "https://api.deepseek.com/beta/"
-func (c *Client) CreateFIMCompletion(
+// createFIMCompletion sends a FIM completion request without running the middleware chain.
+func (c *Client) createFIMCompletion(
 	ctx context.Context,
 	request *FIMCompletionRequest,
 ) (*FIMCompletionResponse, error) {
-	if request.MaxTokens > 4000 {
-		return nil, fmt.Errorf("max tokens must be <= 4000")
+	if request == nil {
+		return nil, fmt.Errorf("request cannot be nil")
+	}
+	if err := c.requireDeepSeek("FIM completion"); err != nil {
+		return nil, err
+	}
+	if request.MaxTokens > fimMaxTokens {
+		return nil, &ValidationError{Errors: []FieldError{{Field: "max_tokens", Message: fmt.Sprintf("must be <= %d", fimMaxTokens)}}}
 	}
 	baseURL := "https://api.deepseek.com/beta/"
 
-	if request == nil {
-		return nil, fmt.Errorf("request cannot be nil")
+	ctx, release, err := c.waitLimiter(ctx, EstimateTokenCount(request.Prompt+request.Suffix).EstimatedTokens)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	body, err := c.requestBody(request, request.ExtraBody)
+	if err != nil {
+		return nil, fmt.Errorf("error building request: %w", err)
 	}
 	req, err := utils.NewRequestBuilder(c.AuthToken).
 		SetBaseURL(baseURL).
 		SetPath("/completions").
-		SetBodyFromStruct(request).
+		SetBodyFromStruct(body).
 		Build(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("error building request: %w", err)
@@ -133,39 +334,80 @@ func (c *Client) CreateFIMCompletion(
 	return updatedResp, nil
 }
 
-// CreateFIMStreamCompletion sends a FIM completion request with stream = true and returns the delta
-func (c *Client) CreateFIMStreamCompletion(
+// createFIMStreamCompletion opens a FIM completion stream without running the middleware chain.
+func (c *Client) createFIMStreamCompletion(
 	ctx context.Context,
 	request *FIMStreamCompletionRequest,
 ) (FIMChatCompletionStream, error) {
+	if request == nil {
+		return nil, fmt.Errorf("request cannot be nil")
+	}
+	if err := c.requireDeepSeek("FIM completion"); err != nil {
+		return nil, err
+	}
 	baseURL := "https://api.deepseek.com/beta/"
 
+	ctx, tcancel, err := getTimeoutContext(ctx, c.Timeout)
+	if err != nil {
+		return nil, fmt.Errorf("error getting timeout context: %w", err)
+	}
+	ctx, rcancel := context.WithCancel(ctx)
+	cancel := func() { rcancel(); tcancel() }
+
+	ctx, release, err := c.waitLimiter(ctx, EstimateTokenCount(request.Prompt+request.Suffix).EstimatedTokens)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
 	request.Stream = true
+	sent := *request
+	if c.tracksUsage() {
+		sent.StreamOptions.IncludeUsage = true
+	}
+	body, err := c.requestBody(&sent, request.ExtraBody)
+	if err != nil {
+		release()
+		cancel()
+		return nil, fmt.Errorf("error building request: %w", err)
+	}
 	req, err := utils.NewRequestBuilder(c.AuthToken).
 		SetBaseURL(baseURL).
 		SetPath("/completions"). //Note to maintianer: This is a really bad implementation with manual path insertion. Please create an issue.
-		SetBodyFromStruct(request).
+		SetBodyFromStruct(body).
 		BuildStream(ctx)
 
 	if err != nil {
+		release()
+		cancel()
 		return nil, fmt.Errorf("error building request: %w", err)
 	}
 
 	resp, err := HandleSendChatCompletionRequest(*c, req)
 	if err != nil {
+		release()
+		cancel()
 		return nil, fmt.Errorf("error sending request: %w", err)
 	}
 
 	if resp.StatusCode >= 400 {
+		release()
+		defer cancel()
 		return nil, HandleAPIError(resp)
 	}
 
-	ctx, cancel := context.WithCancel(ctx)
+	// The timeouts start with the first read, so retries, backoff and time before the stream is read do not count.
+	watchdog := newStreamWatchdog(rcancel, c.StreamFirstTokenTimeout, c.StreamIdleTimeout)
+	decoder := newSSEDecoder(resp.Body)
+	decoder.onLine = watchdog.lineReceived
+
 	stream := &fimCompletionStream{
-		ctx:    ctx,
-		cancel: cancel,
-		resp:   resp,
-		reader: bufio.NewReader(resp.Body),
+		ctx:      ctx,
+		cancel:   cancel,
+		resp:     resp,
+		decoder:  decoder,
+		release:  release,
+		watchdog: watchdog,
 	}
 	return stream, nil
 }
@@ -0,0 +1,241 @@
+package deepseek
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// DefaultCompactionPrompt is the system prompt used to summarize compacted turns.
+const DefaultCompactionPrompt = "You compress chat transcripts. Summarize the conversation below so that it can replace the " +
+	"original messages. Keep facts, decisions, open questions, names, numbers and the results of tool calls. " +
+	"Write in the language of the conversation and answer with the summary only."
+
+// ErrConversationChanged is returned by Compact when the history was modified while the summary was being generated.
+var ErrConversationChanged = errors.New("conversation changed during compaction")
+
+// Compactor replaces the older turns of a Conversation with a summary generated by the model.
+// Leading system messages, the last KeepTurns turns and any tool calls without results are kept verbatim.
+// A turn starts with a user message. Everything but the Counter is saved with the conversation's state.
+type Compactor struct {
+	Threshold   int          `json:"threshold"`              // Compact when the history uses more prompt tokens than this. Zero disables automatic compaction.
+	KeepTurns   int          `json:"keep_turns"`             // Number of most recent turns kept verbatim. Defaults to 2.
+	Prompt      string       `json:"prompt,omitempty"`       // System prompt for the summary. Defaults to DefaultCompactionPrompt.
+	Model       string       `json:"model,omitempty"`        // Model that writes the summary. Defaults to the conversation's model.
+	MaxTokens   int          `json:"max_tokens,omitempty"`   // Maximum length of the summary. Zero means no limit.
+	SummaryRole string       `json:"summary_role,omitempty"` // Role of the summary message, system or assistant. Defaults to system.
+	Counter     TokenCounter `json:"-"`                      // How prompt tokens are counted. Defaults to EstimateRequestTokens.
+}
+
+// CompactionRecord describes one compaction of a conversation.
+type CompactionRecord struct {
+	Time      time.Time               `json:"time"`       // When the compaction happened.
+	Start     int                     `json:"start"`      // Index of the first replaced message in the history before the compaction.
+	End       int                     `json:"end"`        // Index after the last replaced message in the history before the compaction.
+	Replaced  []ChatCompletionMessage `json:"replaced"`   // The messages that were replaced.
+	Summary   ChatCompletionMessage   `json:"summary"`    // The message that replaced them.
+	Model     string                  `json:"model"`      // Model that wrote the summary.
+	Usage     Usage                   `json:"usage"`      // Tokens used for the summary.
+	TokensOld int                     `json:"tokens_old"` // Estimated prompt tokens of the history before the compaction.
+	TokensNew int                     `json:"tokens_new"` // Estimated prompt tokens of the history after the compaction.
+}
+
+// NewCompactor creates a Compactor that compacts above threshold tokens and keeps the last keepTurns turns.
+func NewCompactor(threshold, keepTurns int) *Compactor {
+	return &Compactor{Threshold: threshold, KeepTurns: keepTurns}
+}
+
+// SetCompactor sets the compactor used before every send. The history is compacted automatically when it
+// crosses the compactor's threshold. A nil compactor disables automatic compaction.
+func (c *Conversation) SetCompactor(compactor *Compactor) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.compactor = compactor
+}
+
+// Compactions returns the record of every compaction, oldest first.
+func (c *Conversation) Compactions() []CompactionRecord {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]CompactionRecord(nil), c.compactions...)
+}
+
+// Compact summarizes the older turns with the given compactor, regardless of its threshold.
+// It returns nil if there is nothing to compact.
+func (c *Conversation) Compact(ctx context.Context, compactor *Compactor) (*CompactionRecord, error) {
+	if compactor == nil {
+		return nil, fmt.Errorf("compactor cannot be nil")
+	}
+	return c.compact(ctx, compactor, false)
+}
+
+// maybeCompact compacts the history if a compactor is set and its threshold is crossed.
+func (c *Conversation) maybeCompact(ctx context.Context) error {
+	c.mu.Lock()
+	compactor := c.compactor
+	c.mu.Unlock()
+	if compactor == nil || compactor.Threshold <= 0 {
+		return nil
+	}
+	if _, err := c.compact(ctx, compactor, true); err != nil {
+		return fmt.Errorf("compacting conversation: %w", err)
+	}
+	return nil
+}
+
+// compact replaces the compactable range of the history with a summary.
+func (c *Conversation) compact(ctx context.Context, compactor *Compactor, checkThreshold bool) (*CompactionRecord, error) {
+	c.mu.Lock()
+	model := c.template.Model
+	tools := c.template.Tools
+	messages := append([]ChatCompletionMessage(nil), c.messages...)
+	version := c.version
+	summaryIndex := c.summaryIndex
+	c.mu.Unlock()
+
+	count := compactor.counter(model, tools)
+	before := count(messages)
+	if checkThreshold && before <= compactor.Threshold {
+		return nil, nil
+	}
+	start, end := compactor.compactRange(messages, summaryIndex)
+	if end-start < 1 || (end-start == 1 && start == summaryIndex) {
+		return nil, nil
+	}
+
+	summaryModel := compactor.Model
+	if summaryModel == "" {
+		summaryModel = model
+	}
+	resp, err := c.client.CreateChatCompletion(ctx, &ChatCompletionRequest{
+		Model:     summaryModel,
+		MaxTokens: compactor.MaxTokens,
+		Messages: []ChatCompletionMessage{
+			{Role: ChatMessageRoleSystem, Content: compactor.prompt()},
+			{Role: ChatMessageRoleUser, Content: renderTranscript(messages[start:end])},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Choices) == 0 || resp.Choices[0].Message.Content == "" {
+		return nil, fmt.Errorf("no summary in response")
+	}
+
+	role := compactor.SummaryRole
+	if role == "" {
+		role = ChatMessageRoleSystem
+	}
+	summary := ChatCompletionMessage{Role: role, Content: "Summary of the earlier conversation:\n" + resp.Choices[0].Message.Content}
+
+	compacted := make([]ChatCompletionMessage, 0, len(messages)-(end-start)+1)
+	compacted = append(compacted, messages[:start]...)
+	compacted = append(compacted, summary)
+	compacted = append(compacted, messages[end:]...)
+
+	record := CompactionRecord{
+		Time:      time.Now(),
+		Start:     start,
+		End:       end,
+		Replaced:  append([]ChatCompletionMessage(nil), messages[start:end]...),
+		Summary:   summary,
+		Model:     summaryModel,
+		Usage:     resp.Usage,
+		TokensOld: before,
+		TokensNew: count(compacted),
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.version != version {
+		return nil, ErrConversationChanged
+	}
+	c.messages = compacted
+	c.summaryIndex = start
+	c.compactions = append(c.compactions, record)
+	c.usage = addUsage(c.usage, resp.Usage)
+	c.version++
+	return &record, nil
+}
+
+// compactRange returns the half-open range of messages to summarize. Leading system messages are kept,
+// except an earlier summary, which is folded into the new one. The range ends before the last KeepTurns
+// turns and before the first assistant message whose tool calls have not all been answered.
+func (p *Compactor) compactRange(messages []ChatCompletionMessage, summaryIndex int) (start, end int) {
+	for start < len(messages) && messages[start].Role == ChatMessageRoleSystem && start != summaryIndex {
+		start++
+	}
+
+	keep := p.KeepTurns
+	if keep <= 0 {
+		keep = 2
+	}
+	end = len(messages)
+	for i := len(messages) - 1; i >= start && keep > 0; i-- {
+		if messages[i].Role == ChatMessageRoleUser {
+			end = i
+			keep--
+		}
+	}
+	if keep > 0 {
+		// There are not enough turns to keep; nothing can be compacted.
+		return start, start
+	}
+
+	answered := make(map[string]bool)
+	for _, msg := range messages {
+		if msg.Role == ChatMessageRoleTool {
+			answered[msg.ToolCallID] = true
+		}
+	}
+	for i := start; i < end; i++ {
+		for _, call := range messages[i].ToolCalls {
+			if !answered[call.ID] {
+				return start, i
+			}
+		}
+	}
+	return start, end
+}
+
+// counter returns a function counting the prompt tokens of messages.
+func (p *Compactor) counter(model string, tools []Tool) func([]ChatCompletionMessage) int {
+	counter := p.Counter
+	if counter == nil {
+		counter = EstimateRequestTokens
+	}
+	return func(messages []ChatCompletionMessage) int {
+		return counter(&ChatCompletionRequest{Model: model, Messages: messages, Tools: tools})
+	}
+}
+
+// prompt returns the summarization prompt.
+func (p *Compactor) prompt() string {
+	if p.Prompt == "" {
+		return DefaultCompactionPrompt
+	}
+	return p.Prompt
+}
+
+// renderTranscript formats messages as plain text for the summarization request.
+func renderTranscript(messages []ChatCompletionMessage) string {
+	var b strings.Builder
+	for _, msg := range messages {
+		switch {
+		case msg.Role == ChatMessageRoleTool:
+			fmt.Fprintf(&b, "tool result (%s): %s\n", msg.ToolCallID, msg.Content)
+		case len(msg.ToolCalls) > 0:
+			if msg.Content != "" {
+				fmt.Fprintf(&b, "%s: %s\n", msg.Role, msg.Content)
+			}
+			for _, call := range msg.ToolCalls {
+				fmt.Fprintf(&b, "%s called %s(%s) (%s)\n", msg.Role, call.Function.Name, call.Function.Arguments, call.ID)
+			}
+		default:
+			fmt.Fprintf(&b, "%s: %s\n", msg.Role, msg.Content)
+		}
+	}
+	return b.String()
+}
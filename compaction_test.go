@@ -0,0 +1,143 @@
+package deepseek_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/cohesion-org/deepseek-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// summarizingServer answers summarization requests with "short version" and records their transcripts.
+// Every other request is answered with "ok".
+func summarizingServer(t *testing.T, prompt string, transcripts *[]string) *httptest.Server {
+	t.Helper()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req deepseek.ChatCompletionRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		content := "ok"
+		if req.Messages[0].Content == prompt {
+			*transcripts = append(*transcripts, req.Messages[1].Content)
+			content = "short version"
+		}
+		json.NewEncoder(w).Encode(deepseek.ChatCompletionResponse{
+			ID:      "compact",
+			Choices: []deepseek.Choice{{Message: deepseek.Message{Role: deepseek.ChatMessageRoleAssistant, Content: content}}},
+			Usage:   deepseek.Usage{TotalTokens: 42},
+		})
+	}))
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func newCompactingConversation(t *testing.T, ts *httptest.Server) *deepseek.Conversation {
+	t.Helper()
+	client, err := deepseek.NewClientWithOptions("token", deepseek.WithBaseURL(ts.URL+"/"))
+	require.NoError(t, err)
+	return deepseek.NewConversation(client, deepseek.ChatCompletionRequest{
+		Model:    deepseek.DeepSeekChat,
+		Messages: []deepseek.ChatCompletionMessage{{Role: deepseek.ChatMessageRoleSystem, Content: "be brief"}},
+	})
+}
+
+func TestConversationCompact(t *testing.T) {
+	var transcripts []string
+	ts := summarizingServer(t, deepseek.DefaultCompactionPrompt, &transcripts)
+	conv := newCompactingConversation(t, ts)
+	conv.Append(
+		deepseek.ChatCompletionMessage{Role: deepseek.ChatMessageRoleUser, Content: "first"},
+		deepseek.ChatCompletionMessage{Role: deepseek.ChatMessageRoleAssistant, Content: "one"},
+		deepseek.ChatCompletionMessage{Role: deepseek.ChatMessageRoleUser, Content: "second"},
+		deepseek.ChatCompletionMessage{Role: deepseek.ChatMessageRoleAssistant, Content: "two"},
+		deepseek.ChatCompletionMessage{Role: deepseek.ChatMessageRoleUser, Content: "third"},
+		deepseek.ChatCompletionMessage{Role: deepseek.ChatMessageRoleAssistant, Content: "three"},
+	)
+
+	record, err := conv.Compact(context.Background(), deepseek.NewCompactor(0, 1))
+	require.NoError(t, err)
+	require.NotNil(t, record)
+	assert.Equal(t, 1, record.Start)
+	assert.Equal(t, 5, record.End)
+	assert.Len(t, record.Replaced, 4)
+	assert.Equal(t, 42, record.Usage.TotalTokens)
+	assert.Equal(t, "user: first\nassistant: one\nuser: second\nassistant: two\n", transcripts[0])
+
+	history := conv.Messages()
+	require.Len(t, history, 4)
+	assert.Equal(t, "be brief", history[0].Content)
+	assert.Equal(t, deepseek.ChatMessageRoleSystem, history[1].Role)
+	assert.True(t, strings.HasSuffix(history[1].Content, "short version"))
+	assert.Equal(t, "third", history[2].Content)
+	assert.Equal(t, []deepseek.CompactionRecord{*record}, conv.Compactions())
+
+	// A second compaction folds the earlier summary into the new one.
+	conv.Append(
+		deepseek.ChatCompletionMessage{Role: deepseek.ChatMessageRoleUser, Content: "fourth"},
+		deepseek.ChatCompletionMessage{Role: deepseek.ChatMessageRoleAssistant, Content: "four"},
+	)
+	record, err = conv.Compact(context.Background(), deepseek.NewCompactor(0, 1))
+	require.NoError(t, err)
+	require.NotNil(t, record)
+	assert.Equal(t, 1, record.Start)
+	assert.Contains(t, transcripts[1], "short version")
+	assert.Len(t, conv.Messages(), 4)
+	assert.Len(t, conv.Compactions(), 2)
+
+	// Nothing is left to compact.
+	record, err = conv.Compact(context.Background(), deepseek.NewCompactor(0, 1))
+	require.NoError(t, err)
+	assert.Nil(t, record)
+}
+
+func TestConversationCompactKeepsUnresolvedToolCalls(t *testing.T) {
+	var transcripts []string
+	ts := summarizingServer(t, deepseek.DefaultCompactionPrompt, &transcripts)
+	conv := newCompactingConversation(t, ts)
+	conv.Append(
+		deepseek.ChatCompletionMessage{Role: deepseek.ChatMessageRoleUser, Content: "first"},
+		deepseek.ChatCompletionMessage{Role: deepseek.ChatMessageRoleAssistant, ToolCalls: []deepseek.ToolCall{cityCall("a", "Paris")}},
+		deepseek.ChatCompletionMessage{Role: deepseek.ChatMessageRoleTool, ToolCallID: "a", Content: "sunny"},
+		deepseek.ChatCompletionMessage{Role: deepseek.ChatMessageRoleAssistant, ToolCalls: []deepseek.ToolCall{cityCall("b", "Rome")}},
+		deepseek.ChatCompletionMessage{Role: deepseek.ChatMessageRoleUser, Content: "second"},
+		deepseek.ChatCompletionMessage{Role: deepseek.ChatMessageRoleAssistant, Content: "two"},
+	)
+
+	record, err := conv.Compact(context.Background(), deepseek.NewCompactor(0, 1))
+	require.NoError(t, err)
+	require.NotNil(t, record)
+	assert.Equal(t, 4, record.End, "the unanswered call for Rome is kept")
+	assert.Contains(t, transcripts[0], `called lookup({"city":"Paris"})`)
+	assert.Contains(t, transcripts[0], "tool result (a): sunny")
+	assert.Len(t, conv.Messages(), 5)
+}
+
+func TestConversationAutoCompact(t *testing.T) {
+	var transcripts []string
+	ts := summarizingServer(t, "squash it", &transcripts)
+	conv := newCompactingConversation(t, ts)
+	conv.SetCompactor(&deepseek.Compactor{
+		Threshold:   50,
+		KeepTurns:   1,
+		Prompt:      "squash it",
+		SummaryRole: deepseek.ChatMessageRoleAssistant,
+		Counter:     messageCounter,
+	})
+
+	for _, content := range []string{"a", "b", "c"} {
+		_, err := conv.Send(context.Background(), content)
+		require.NoError(t, err)
+	}
+	assert.Empty(t, conv.Compactions(), "five messages are below the threshold")
+
+	_, err := conv.Send(context.Background(), "d")
+	require.NoError(t, err)
+	require.Len(t, conv.Compactions(), 1)
+	history := conv.Messages()
+	assert.Equal(t, []string{"be brief", "Summary of the earlier conversation:\nshort version", "c", "ok", "d", "ok"}, contents(history))
+	assert.Equal(t, deepseek.ChatMessageRoleAssistant, history[1].Role)
+}
@@ -1,10 +1,10 @@
 package deepseek
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"net/url"
-	"os"
 	"time"
 )
 
@@ -23,26 +23,43 @@ type Client struct {
 	Timeout   time.Duration // The timeout for the current Client
 	Path      string        // The path for the API request. Defaults to "chat/completions"
 
-	HTTPClient HTTPDoer // The HTTP client to send the request and get the response
+	HTTPClient  HTTPDoer       // The HTTP client to send the request and get the response
+	RetryPolicy *RetryPolicy   // Optional retry policy. Requests are sent once if nil.
+	Limiter     *Limiter       // Optional client-side rate and concurrency limiter.
+	Middleware  []Middleware   // Middleware run around every Create* call, outermost first.
+	Models      *ModelRegistry // Model capabilities and prices. DefaultModelRegistry is used if nil.
+	Ledger      *UsageLedger   // Optional ledger recording the usage and cost of every call.
+	Budget      *BudgetGuard   // Optional guard refusing calls that would exceed the spend limits.
+
+	ValidateRequests bool // Validate requests against the model registry before sending them.
+
+	ExtraBody    map[string]any    // Fields added to the body of chat and FIM requests unless the request sets them.
+	ExtraHeaders map[string]string // Headers added to every request.
+	Profile      *ProviderProfile  // Optional provider the client talks to. See WithProvider.
+	Keys         *KeyPool          // Optional pool of API keys used instead of AuthToken. See WithKeyPool.
+
+	Credentials CredentialProvider // Optional provider of the API key, consulted for every request instead of AuthToken.
+	Log         *LogConfig         // Optional structured logging of calls, requests and streams. See WithLogger.
+
+	StreamFirstTokenTimeout time.Duration // Maximum time from the first Recv of a stream to its first chunk. Zero disables it.
+	StreamIdleTimeout       time.Duration // Maximum time between two chunks of a stream. Zero disables it.
 }
 
 // NewClient creates a new client with an authentication token and an optional custom baseURL.
 // If no baseURL is provided, it defaults to "https://api.deepseek.com/".
+// If the token is empty, the key is read from the DEEPSEEK_API_KEY environment variable for every request
+// with DefaultCredential, and requests fail with ErrNoCredential while it is not set.
+// It returns nil if baseURL is not a valid URL; use NewClientWithOptions with WithBaseURL to get the error.
 // You can't set path with this method. If you want to set path, use NewClientWithOptions.
 func NewClient(AuthToken string, baseURL ...string) *Client {
+	var credentials CredentialProvider
 	if AuthToken == "" {
-		if envKey, ok := os.LookupEnv("DEEPSEEK_API_KEY"); ok && envKey != "" {
-			AuthToken = envKey
-		} else {
-			fmt.Printf("authToken is empty. Please provide a valid token or set the DEEPSEEK_API_KEY environment variable")
-			return nil
-		}
+		credentials = DefaultCredential
+		AuthToken, _ = DefaultCredential.Credential(context.Background())
 	}
 	// check if this is a valid URL
 	if len(baseURL) > 0 {
-		_, err := url.ParseRequestURI(baseURL[0])
-		if err != nil {
-			fmt.Printf("Invalid URL: %s. \nIf you are using options please use NewClientWithOptions", baseURL[0])
+		if _, err := url.ParseRequestURI(baseURL[0]); err != nil {
 			return nil
 		}
 	}
@@ -51,9 +68,10 @@ func NewClient(AuthToken string, baseURL ...string) *Client {
 		url = baseURL[0]
 	}
 	return &Client{
-		AuthToken: AuthToken,
-		BaseURL:   url,
-		Path:      "chat/completions",
+		AuthToken:   AuthToken,
+		BaseURL:     url,
+		Path:        "chat/completions",
+		Credentials: credentials,
 	}
 }
 
@@ -64,24 +82,24 @@ type Option func(*Client) error
 // Defaults:
 // - BaseURL: "https://api.deepseek.com/"
 // - Timeout: 5 minutes
+//
+// The authentication token may be empty if the client gets its keys from WithKeyPool or WithCredentialProvider.
+// Otherwise an empty token reads the key from the DEEPSEEK_API_KEY environment variable for every request
+// with DefaultCredential, which must be set when the client is created.
 func NewClientWithOptions(authToken string, opts ...Option) (*Client, error) {
-	// Check for empty auth token and try to use environment variable
+	var credentials CredentialProvider
+	var credentialErr error
 	if authToken == "" {
-		if envKey, ok := os.LookupEnv("DEEPSEEK_API_KEY"); ok {
-			authToken = envKey
-		} else {
-			return nil, fmt.Errorf("authToken is empty and DEEPSEEK_API_KEY environment variable is not set")
-		}
-	}
-	if authToken == "" {
-		return nil, fmt.Errorf("authToken is empty. Please provide a valid token or set the DEEPSEEK_API_KEY environment variable.")
+		credentials = DefaultCredential
+		authToken, credentialErr = DefaultCredential.Credential(context.Background())
 	}
 
 	client := &Client{
-		AuthToken: authToken,
-		BaseURL:   "https://api.deepseek.com/",
-		Timeout:   5 * time.Minute,
-		Path:      "chat/completions",
+		AuthToken:   authToken,
+		BaseURL:     "https://api.deepseek.com/",
+		Timeout:     5 * time.Minute,
+		Path:        "chat/completions",
+		Credentials: credentials,
 	}
 
 	for _, opt := range opts {
@@ -90,13 +108,22 @@ func NewClientWithOptions(authToken string, opts ...Option) (*Client, error) {
 		}
 	}
 
+	if client.AuthToken == "" && client.Keys == nil && (client.Credentials == nil || client.Credentials == credentials) {
+		if credentialErr != nil {
+			return nil, fmt.Errorf("authToken is empty: %w", credentialErr)
+		}
+		return nil, fmt.Errorf("authToken is empty. Please provide a valid token or set the DEEPSEEK_API_KEY environment variable.")
+	}
 	return client, nil
 }
 
-// WithBaseURL sets the base URL for the API client
-func WithBaseURL(url string) Option {
+// WithBaseURL sets the base URL for the API client. It returns an error if the URL is not valid.
+func WithBaseURL(baseURL string) Option {
 	return func(c *Client) error {
-		c.BaseURL = url
+		if _, err := url.ParseRequestURI(baseURL); err != nil {
+			return fmt.Errorf("invalid base URL: %w", err)
+		}
+		c.BaseURL = baseURL
 		return nil
 	}
 }
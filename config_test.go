@@ -16,7 +16,8 @@ func TestNewCleint(t *testing.T) {
 	t.Run("empty api key with no env fallback", func(t *testing.T) {
 		os.Unsetenv("DEEPSEEK_API_KEY")
 		client := deepseek.NewClient("")
-		require.Nil(t, client)
+		require.NotNil(t, client)
+		require.Equal(t, deepseek.DefaultCredential, client.Credentials)
 	})
 
 	// test empty api key with env fallback
@@ -29,13 +30,10 @@ func TestNewCleint(t *testing.T) {
 		require.Equal(t, "test", client.AuthToken)
 	})
 
-	//test empty api key
-	client := deepseek.NewClient("")
-	require.Nil(t, client)
-
 	//test valid api key
-	client = deepseek.NewClient("test")
+	client := deepseek.NewClient("test")
 	require.NotNil(t, client)
+	require.Nil(t, client.Credentials)
 
 	//test with a non url as base url
 	client = deepseek.NewClient("test", "invalid-url")
@@ -76,6 +74,11 @@ func TestNewClientWithOptions(t *testing.T) {
 			expectedTimeout: 10 * time.Second,
 			expectError:     false,
 		},
+		{
+			name:        "invalid base URL",
+			opts:        []deepseek.Option{deepseek.WithBaseURL("invalid-url")},
+			expectError: true,
+		},
 		{
 			name:        "invalid timeout",
 			opts:        []deepseek.Option{deepseek.WithTimeout(-1 * time.Second)},
@@ -163,6 +166,9 @@ func TestNewClientWithOptions_EmptyAuthToken(t *testing.T) {
 			if client != nil && client.AuthToken != tt.wantAuthToken {
 				t.Errorf("expected auth token to be '%s', got '%s'", tt.wantAuthToken, client.AuthToken)
 			}
+			if client != nil && tt.inputToken == "" && client.Credentials != deepseek.DefaultCredential {
+				t.Errorf("expected the key to be read with DefaultCredential, got %v", client.Credentials)
+			}
 		})
 	}
 }
@@ -0,0 +1,284 @@
+package deepseek
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrContextWindowExceeded is returned when a request cannot be made to fit into the model's context window.
+var ErrContextWindowExceeded = errors.New("request does not fit into the context window")
+
+// TokenCounter returns the number of prompt tokens a request uses.
+type TokenCounter func(request *ChatCompletionRequest) int
+
+// EstimateRequestTokens is the default TokenCounter. It is based on EstimateTokensFromMessages.
+func EstimateRequestTokens(request *ChatCompletionRequest) int {
+	return EstimateTokensFromMessages(request).EstimatedTokens
+}
+
+// TruncationStrategy removes messages until fits reports true, and returns the remaining messages.
+// It must not modify the given slice. If the messages cannot be made to fit, it returns its best attempt;
+// the ContextManager checks the result again.
+type TruncationStrategy func(messages []ChatCompletionMessage, fits func([]ChatCompletionMessage) bool) []ChatCompletionMessage
+
+// ContextManager fits the messages of a request into the context window of its model before it is sent.
+// System messages are always kept, and an assistant message with tool calls is only ever removed together
+// with its tool results, so the truncated request stays valid.
+type ContextManager struct {
+	Strategy      TruncationStrategy // How messages are removed. Defaults to DropOldest.
+	Counter       TokenCounter       // How prompt tokens are counted. Defaults to EstimateRequestTokens.
+	ContextWindow int                // Context window in tokens. Zero means it is looked up in Models.
+	Reserve       int                // Extra tokens to keep free, for example to absorb estimation errors.
+	Models        *ModelRegistry     // Registry the model's limits are looked up in. Defaults to DefaultModelRegistry.
+}
+
+// NewContextManager creates a ContextManager that uses the given strategy.
+func NewContextManager(strategy TruncationStrategy) *ContextManager {
+	return &ContextManager{Strategy: strategy}
+}
+
+// Budget returns the number of prompt tokens available for the request. Room for MaxTokens is reserved;
+// if MaxTokens is not set, the model's default output length is reserved instead.
+func (m *ContextManager) Budget(model string, maxTokens int) (int, error) {
+	models := m.Models
+	if models == nil {
+		models = DefaultModelRegistry()
+	}
+	caps, ok := models.Lookup(model)
+	window := m.ContextWindow
+	if window <= 0 {
+		if !ok || caps.ContextLength <= 0 {
+			return 0, fmt.Errorf("unknown context window for model %q; set ContextWindow", model)
+		}
+		window = caps.ContextLength
+	}
+	output := maxTokens
+	if output <= 0 {
+		output = caps.DefaultOutputTokens
+	}
+	return window - output - m.Reserve, nil
+}
+
+// Fit returns a copy of the request whose messages fit into the budget. The request itself is not modified.
+// If nothing needs to be removed, the request is returned unchanged.
+func (m *ContextManager) Fit(request *ChatCompletionRequest) (*ChatCompletionRequest, error) {
+	if request == nil {
+		return nil, fmt.Errorf("request cannot be nil")
+	}
+	messages, err := m.fit(request.Model, request.MaxTokens, request.Messages, request.Tools)
+	if err != nil {
+		return nil, err
+	}
+	if len(messages) == len(request.Messages) {
+		return request, nil
+	}
+	fitted := *request
+	fitted.Messages = messages
+	return &fitted, nil
+}
+
+// FitStream is Fit for streaming requests.
+func (m *ContextManager) FitStream(request *StreamChatCompletionRequest) (*StreamChatCompletionRequest, error) {
+	if request == nil {
+		return nil, fmt.Errorf("request cannot be nil")
+	}
+	messages, err := m.fit(request.Model, request.MaxTokens, request.Messages, request.Tools)
+	if err != nil {
+		return nil, err
+	}
+	if len(messages) == len(request.Messages) {
+		return request, nil
+	}
+	fitted := *request
+	fitted.Messages = messages
+	return &fitted, nil
+}
+
+// Middleware returns middleware that fits every chat completion request before it is sent.
+func (m *ContextManager) Middleware() Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, call *Call) (*Result, error) {
+			var err error
+			switch {
+			case call.ChatRequest != nil:
+				call.ChatRequest, err = m.Fit(call.ChatRequest)
+			case call.StreamRequest != nil:
+				call.StreamRequest, err = m.FitStream(call.StreamRequest)
+			}
+			if err != nil {
+				return nil, err
+			}
+			return next(ctx, call)
+		}
+	}
+}
+
+// fit applies the strategy to messages.
+func (m *ContextManager) fit(model string, maxTokens int, messages []ChatCompletionMessage, tools []Tool) ([]ChatCompletionMessage, error) {
+	budget, err := m.Budget(model, maxTokens)
+	if err != nil {
+		return nil, err
+	}
+	counter := m.Counter
+	if counter == nil {
+		counter = EstimateRequestTokens
+	}
+	count := func(msgs []ChatCompletionMessage) int {
+		return counter(&ChatCompletionRequest{Model: model, Messages: msgs, Tools: tools})
+	}
+	fits := func(msgs []ChatCompletionMessage) bool { return count(msgs) <= budget }
+
+	if fits(messages) {
+		return messages, nil
+	}
+	strategy := m.Strategy
+	if strategy == nil {
+		strategy = DropOldest()
+	}
+	fitted := strategy(messages, fits)
+	if used := count(fitted); used > budget {
+		return nil, fmt.Errorf("%w: %d tokens needed, %d available for model %q", ErrContextWindowExceeded, used, budget, model)
+	}
+	return fitted, nil
+}
+
+// messageGroup is a run of messages that must be kept or removed together:
+// a single message, or an assistant message with tool calls followed by its tool results.
+type messageGroup struct {
+	start, end int // Half-open range of message indices.
+	system     bool
+}
+
+// groupMessages splits messages into groups.
+func groupMessages(messages []ChatCompletionMessage) []messageGroup {
+	var groups []messageGroup
+	for i := 0; i < len(messages); {
+		g := messageGroup{start: i, end: i + 1, system: messages[i].Role == ChatMessageRoleSystem}
+		if len(messages[i].ToolCalls) > 0 {
+			ids := make(map[string]bool, len(messages[i].ToolCalls))
+			for _, call := range messages[i].ToolCalls {
+				ids[call.ID] = true
+			}
+			for g.end < len(messages) && messages[g.end].Role == ChatMessageRoleTool && ids[messages[g.end].ToolCallID] {
+				g.end++
+			}
+		}
+		groups = append(groups, g)
+		i = g.end
+	}
+	return groups
+}
+
+// joinGroups returns the messages of the groups that keep reports true for.
+func joinGroups(messages []ChatCompletionMessage, groups []messageGroup, keep []bool) []ChatCompletionMessage {
+	var out []ChatCompletionMessage
+	for i, g := range groups {
+		if keep[i] {
+			out = append(out, messages[g.start:g.end]...)
+		}
+	}
+	return out
+}
+
+// dropInOrder removes the non-system groups in the given order until the messages fit.
+// The last group, usually the newest user message, is never removed.
+func dropInOrder(messages []ChatCompletionMessage, groups []messageGroup, keep []bool, order []int, fits func([]ChatCompletionMessage) bool) []ChatCompletionMessage {
+	out := joinGroups(messages, groups, keep)
+	for _, i := range order {
+		if fits(out) {
+			break
+		}
+		if groups[i].system || i == len(groups)-1 || !keep[i] {
+			continue
+		}
+		keep[i] = false
+		out = joinGroups(messages, groups, keep)
+	}
+	return out
+}
+
+// allGroups returns a keep slice with every group kept.
+func allGroups(groups []messageGroup) []bool {
+	keep := make([]bool, len(groups))
+	for i := range keep {
+		keep[i] = true
+	}
+	return keep
+}
+
+// DropOldest removes the oldest messages first.
+func DropOldest() TruncationStrategy {
+	return func(messages []ChatCompletionMessage, fits func([]ChatCompletionMessage) bool) []ChatCompletionMessage {
+		groups := groupMessages(messages)
+		order := make([]int, len(groups))
+		for i := range order {
+			order[i] = i
+		}
+		return dropInOrder(messages, groups, allGroups(groups), order, fits)
+	}
+}
+
+// KeepSystemAndLastN keeps the system messages and at most the last n other messages.
+// If that is still too large, the oldest of the remaining messages are removed as with DropOldest.
+func KeepSystemAndLastN(n int) TruncationStrategy {
+	return func(messages []ChatCompletionMessage, fits func([]ChatCompletionMessage) bool) []ChatCompletionMessage {
+		groups := groupMessages(messages)
+		keep := allGroups(groups)
+		kept, dropped := 0, false
+		for i := len(groups) - 1; i >= 0; i-- {
+			if groups[i].system {
+				continue
+			}
+			size := groups[i].end - groups[i].start
+			if dropped || (kept+size > n && i != len(groups)-1) {
+				keep[i] = false
+				dropped = true
+				continue
+			}
+			kept += size
+		}
+		order := make([]int, len(groups))
+		for i := range order {
+			order[i] = i
+		}
+		return dropInOrder(messages, groups, keep, order, fits)
+	}
+}
+
+// MiddleOut removes messages from the middle of the conversation outwards. The first non-system message,
+// which usually states the task, and the newest messages are kept the longest.
+func MiddleOut() TruncationStrategy {
+	return func(messages []ChatCompletionMessage, fits func([]ChatCompletionMessage) bool) []ChatCompletionMessage {
+		groups := groupMessages(messages)
+		var candidates []int
+		first := true
+		for i, g := range groups {
+			if g.system {
+				continue
+			}
+			if first {
+				first = false
+				continue
+			}
+			candidates = append(candidates, i)
+		}
+		// Order the candidates by distance from the middle.
+		order := make([]int, 0, len(candidates))
+		mid := len(candidates) / 2
+		for d := 0; len(order) < len(candidates); d++ {
+			if mid-d >= 0 && d > 0 {
+				order = append(order, candidates[mid-d])
+			}
+			if mid+d < len(candidates) && len(order) < len(candidates) {
+				order = append(order, candidates[mid+d])
+			}
+		}
+		out := dropInOrder(messages, groups, allGroups(groups), order, fits)
+		if fits(out) {
+			return out
+		}
+		// Give up the first message as a last resort.
+		return DropOldest()(out, fits)
+	}
+}
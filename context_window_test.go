@@ -0,0 +1,145 @@
+package deepseek_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cohesion-org/deepseek-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// messageCounter counts every message as 10 tokens.
+func messageCounter(request *deepseek.ChatCompletionRequest) int {
+	return 10 * len(request.Messages)
+}
+
+// longHistory returns a system message, a task, a tool call with two results and three more turns.
+func longHistory() []deepseek.ChatCompletionMessage {
+	return []deepseek.ChatCompletionMessage{
+		{Role: deepseek.ChatMessageRoleSystem, Content: "system"},
+		{Role: deepseek.ChatMessageRoleUser, Content: "task"},
+		{Role: deepseek.ChatMessageRoleAssistant, ToolCalls: []deepseek.ToolCall{cityCall("a", "Paris"), cityCall("b", "Rome")}},
+		{Role: deepseek.ChatMessageRoleTool, ToolCallID: "a", Content: "sunny"},
+		{Role: deepseek.ChatMessageRoleTool, ToolCallID: "b", Content: "rainy"},
+		{Role: deepseek.ChatMessageRoleAssistant, Content: "answer"},
+		{Role: deepseek.ChatMessageRoleUser, Content: "follow up"},
+		{Role: deepseek.ChatMessageRoleAssistant, Content: "second answer"},
+		{Role: deepseek.ChatMessageRoleUser, Content: "last"},
+	}
+}
+
+func contents(messages []deepseek.ChatCompletionMessage) []string {
+	out := make([]string, 0, len(messages))
+	for _, msg := range messages {
+		if msg.Content == "" && len(msg.ToolCalls) > 0 {
+			out = append(out, "<calls>")
+			continue
+		}
+		out = append(out, msg.Content)
+	}
+	return out
+}
+
+func fitWith(t *testing.T, strategy deepseek.TruncationStrategy, budget int) ([]deepseek.ChatCompletionMessage, error) {
+	t.Helper()
+	manager := deepseek.NewContextManager(strategy)
+	manager.Counter = messageCounter
+	manager.ContextWindow = budget + 100
+	request := &deepseek.ChatCompletionRequest{Model: deepseek.DeepSeekChat, MaxTokens: 100, Messages: longHistory()}
+	fitted, err := manager.Fit(request)
+	if err != nil {
+		return nil, err
+	}
+	assert.Len(t, request.Messages, 9, "the request must not be modified")
+	return fitted.Messages, nil
+}
+
+func TestContextManagerStrategies(t *testing.T) {
+	t.Run("fits unchanged", func(t *testing.T) {
+		messages, err := fitWith(t, deepseek.DropOldest(), 90)
+		require.NoError(t, err)
+		assert.Len(t, messages, 9)
+	})
+
+	t.Run("drop oldest keeps tool calls with their results", func(t *testing.T) {
+		messages, err := fitWith(t, deepseek.DropOldest(), 50)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"system", "answer", "follow up", "second answer", "last"}, contents(messages))
+
+		// Seven messages would fit, but the tool results cannot be kept without their call.
+		messages, err = fitWith(t, deepseek.DropOldest(), 70)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"system", "answer", "follow up", "second answer", "last"}, contents(messages))
+	})
+
+	t.Run("keep system and last n", func(t *testing.T) {
+		messages, err := fitWith(t, deepseek.KeepSystemAndLastN(4), 80)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"system", "answer", "follow up", "second answer", "last"}, contents(messages))
+
+		messages, err = fitWith(t, deepseek.KeepSystemAndLastN(4), 30)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"system", "second answer", "last"}, contents(messages))
+	})
+
+	t.Run("middle out keeps the task", func(t *testing.T) {
+		messages, err := fitWith(t, deepseek.MiddleOut(), 60)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"system", "task", "<calls>", "sunny", "rainy", "last"}, contents(messages))
+
+		messages, err = fitWith(t, deepseek.MiddleOut(), 30)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"system", "task", "last"}, contents(messages))
+	})
+
+	t.Run("too large", func(t *testing.T) {
+		_, err := fitWith(t, deepseek.DropOldest(), 10)
+		require.ErrorIs(t, err, deepseek.ErrContextWindowExceeded)
+	})
+}
+
+func TestContextManagerBudget(t *testing.T) {
+	manager := deepseek.NewContextManager(nil)
+	budget, err := manager.Budget(deepseek.DeepSeekChat, 8000)
+	require.NoError(t, err)
+	assert.Equal(t, 120_000, budget)
+
+	budget, err = manager.Budget(deepseek.DeepSeekReasoner, 0)
+	require.NoError(t, err)
+	assert.Equal(t, 96_000, budget, "the default output length is reserved")
+
+	_, err = manager.Budget("unknown-model", 0)
+	require.Error(t, err)
+
+	manager.ContextWindow = 1000
+	manager.Reserve = 100
+	budget, err = manager.Budget("unknown-model", 200)
+	require.NoError(t, err)
+	assert.Equal(t, 700, budget)
+}
+
+func TestContextManagerMiddleware(t *testing.T) {
+	var received deepseek.ChatCompletionRequest
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&received))
+		w.Write([]byte(retryChatResponse))
+	}))
+	defer ts.Close()
+
+	manager := deepseek.NewContextManager(deepseek.KeepSystemAndLastN(1))
+	manager.Counter = messageCounter
+	manager.ContextWindow = 130
+	client, err := deepseek.NewClientWithOptions("token",
+		deepseek.WithBaseURL(ts.URL+"/"), deepseek.WithMiddleware(manager.Middleware()))
+	require.NoError(t, err)
+
+	_, err = client.CreateChatCompletion(context.Background(), &deepseek.ChatCompletionRequest{
+		Model: deepseek.DeepSeekChat, MaxTokens: 100, Messages: longHistory(),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"system", "last"}, contents(received.Messages))
+}
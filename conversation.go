@@ -0,0 +1,334 @@
+package deepseek
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"sync"
+)
+
+// ErrConversationBusy is returned when a conversation is sent while an earlier send is still in flight.
+var ErrConversationBusy = errors.New("conversation has a send in flight")
+
+// Conversation owns the message history of a multi-turn chat. Completed turns, including tool calls and
+// reasoning content, are appended to the history automatically. A turn is only recorded once the model
+// has answered, so a failed Send leaves the history unchanged. Only one send may be in flight at a time;
+// a stream counts as in flight until it has been read to the end or closed.
+type Conversation struct {
+	mu           sync.Mutex
+	id           string
+	client       *Client
+	template     ChatCompletionRequest
+	messages     []ChatCompletionMessage
+	version      int // Incremented on every change of messages.
+	compactor    *Compactor
+	compactions  []CompactionRecord
+	summaryIndex int // Index of the summary written by the last compaction, or -1.
+	usage        Usage
+	sending      bool // Whether a send is in flight.
+}
+
+// NewConversation creates a conversation with a random ID that sends its requests with client.
+// The template provides the model and request parameters; its Messages become the initial history.
+func NewConversation(client *Client, template ChatCompletionRequest) *Conversation {
+	messages := append([]ChatCompletionMessage(nil), template.Messages...)
+	template.Messages = nil
+	return &Conversation{id: newConversationID(), client: client, template: template, messages: messages, summaryIndex: -1}
+}
+
+// newConversationID returns a random conversation ID.
+func newConversationID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// ID returns the ID of the conversation.
+func (c *Conversation) ID() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.id
+}
+
+// Usage returns the tokens used by all turns and compactions of the conversation.
+func (c *Conversation) Usage() Usage {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.usage
+}
+
+// Model returns the model used by the conversation.
+func (c *Conversation) Model() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.template.Model
+}
+
+// Messages returns a copy of the history.
+func (c *Conversation) Messages() []ChatCompletionMessage {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]ChatCompletionMessage(nil), c.messages...)
+}
+
+// Len returns the number of messages in the history.
+func (c *Conversation) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.messages)
+}
+
+// Append adds messages to the history without sending them.
+func (c *Conversation) Append(messages ...ChatCompletionMessage) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.messages = append(c.messages, messages...)
+	c.version++
+}
+
+// Reset removes all messages and compaction records from the history.
+func (c *Conversation) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.messages = nil
+	c.compactions = nil
+	c.summaryIndex = -1
+	c.version++
+}
+
+// Clone returns an independent copy of the conversation with a new ID, sharing the same client.
+func (c *Conversation) Clone() *Conversation {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.forkLocked(len(c.messages))
+}
+
+// Fork returns an independent conversation with a new ID containing the first n messages of the history.
+// It can be used to branch off an earlier point of the conversation.
+func (c *Conversation) Fork(n int) (*Conversation, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if n < 0 || n > len(c.messages) {
+		return nil, fmt.Errorf("fork point %d out of range [0, %d]", n, len(c.messages))
+	}
+	return c.forkLocked(n), nil
+}
+
+// forkLocked copies the conversation up to n messages. The caller must hold mu.
+func (c *Conversation) forkLocked(n int) *Conversation {
+	template := c.template
+	template.Tools = append([]Tool(nil), c.template.Tools...)
+	template.Stop = append([]string(nil), c.template.Stop...)
+	template.ExtraBody = maps.Clone(c.template.ExtraBody)
+	summaryIndex := c.summaryIndex
+	if summaryIndex >= n {
+		summaryIndex = -1
+	}
+	return &Conversation{
+		id:           newConversationID(),
+		client:       c.client,
+		template:     template,
+		messages:     append([]ChatCompletionMessage(nil), c.messages[:n]...),
+		compactor:    c.compactor,
+		compactions:  append([]CompactionRecord(nil), c.compactions...),
+		summaryIndex: summaryIndex,
+		usage:        c.usage,
+	}
+}
+
+// Send adds a user message, sends the conversation and records the assistant's answer.
+func (c *Conversation) Send(ctx context.Context, content string) (*ChatCompletionResponse, error) {
+	return c.SendMessages(ctx, ChatCompletionMessage{Role: ChatMessageRoleUser, Content: content})
+}
+
+// SendMessages adds the given messages, for example tool results, sends the conversation and records the answer.
+func (c *Conversation) SendMessages(ctx context.Context, messages ...ChatCompletionMessage) (*ChatCompletionResponse, error) {
+	if c.client == nil {
+		return nil, fmt.Errorf("conversation has no client")
+	}
+	if err := c.beginSend(); err != nil {
+		return nil, err
+	}
+	defer c.endSend()
+	if err := c.maybeCompact(ctx); err != nil {
+		return nil, err
+	}
+	request := c.prepare(messages)
+
+	resp, err := c.client.CreateChatCompletion(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Choices) == 0 {
+		return nil, fmt.Errorf("no choices in response")
+	}
+	c.commit(messages, resp.Choices[0].Message, resp.Usage)
+	return resp, nil
+}
+
+// SendStream adds a user message and opens a stream for the answer. The answer is recorded once the stream
+// has been read to the end; a stream that fails or is closed early leaves the history unchanged.
+func (c *Conversation) SendStream(ctx context.Context, content string) (ChatCompletionStream, error) {
+	return c.SendMessagesStream(ctx, ChatCompletionMessage{Role: ChatMessageRoleUser, Content: content})
+}
+
+// SendMessagesStream adds the given messages and opens a stream for the answer. See SendStream.
+func (c *Conversation) SendMessagesStream(ctx context.Context, messages ...ChatCompletionMessage) (ChatCompletionStream, error) {
+	if c.client == nil {
+		return nil, fmt.Errorf("conversation has no client")
+	}
+	if err := c.beginSend(); err != nil {
+		return nil, err
+	}
+	if err := c.maybeCompact(ctx); err != nil {
+		c.endSend()
+		return nil, err
+	}
+	request := c.prepare(messages)
+
+	stream, err := c.client.CreateChatCompletionStream(ctx, streamRequestFromChat(request))
+	if err != nil {
+		c.endSend()
+		return nil, err
+	}
+	return &conversationStream{
+		ChatCompletionStream: stream,
+		conversation:         c,
+		sent:                 messages,
+		acc:                  NewChatCompletionAccumulator(),
+	}, nil
+}
+
+// beginSend marks a send as in flight, or returns ErrConversationBusy if one already is.
+func (c *Conversation) beginSend() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.sending {
+		return ErrConversationBusy
+	}
+	c.sending = true
+	return nil
+}
+
+// endSend marks the send in flight as finished.
+func (c *Conversation) endSend() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.sending = false
+}
+
+// prepare builds the request for the history plus messages.
+func (c *Conversation) prepare(messages []ChatCompletionMessage) *ChatCompletionRequest {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	history := make([]ChatCompletionMessage, 0, len(c.messages)+len(messages))
+	history = append(history, c.messages...)
+	history = append(history, messages...)
+
+	request := c.template
+	model, _ := c.client.modelRegistry().Lookup(request.Model)
+	request.Messages = prepareMessages(model, history)
+	return &request
+}
+
+// commit records the sent messages, the answer and the tokens used.
+func (c *Conversation) commit(sent []ChatCompletionMessage, answer Message, usage Usage) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.messages = append(c.messages, sent...)
+	c.messages = append(c.messages, ChatCompletionMessage{
+		Role:             ChatMessageRoleAssistant,
+		Content:          answer.Content,
+		ReasoningContent: answer.ReasoningContent,
+		ToolCalls:        answer.ToolCalls,
+	})
+	c.usage = addUsage(c.usage, usage)
+	c.version++
+}
+
+// prepareMessages returns the messages as they must be sent to the model.
+// Models with StripReasoning reject reasoning_content in earlier turns, so it is removed from every message
+// except a trailing prefix message used for Chat Prefix Completion.
+func prepareMessages(model ModelCapabilities, messages []ChatCompletionMessage) []ChatCompletionMessage {
+	if !model.StripReasoning {
+		return messages
+	}
+	prepared := make([]ChatCompletionMessage, len(messages))
+	for i, msg := range messages {
+		if msg.ReasoningContent != "" && !(msg.Prefix && i == len(messages)-1) {
+			msg.ReasoningContent = ""
+		}
+		prepared[i] = msg
+	}
+	return prepared
+}
+
+// streamRequestFromChat converts a chat completion request to its streaming counterpart, asking for the usage.
+func streamRequestFromChat(r *ChatCompletionRequest) *StreamChatCompletionRequest {
+	stream := r.ToChatRequest().streamChatCompletionRequest()
+	stream.StreamOptions = StreamOptions{IncludeUsage: true}
+	return stream
+}
+
+// conversationStream records the streamed answer in the conversation when the stream ends.
+type conversationStream struct {
+	ChatCompletionStream
+	conversation *Conversation
+	sent         []ChatCompletionMessage
+	acc          *ChatCompletionAccumulator
+	done         bool // Whether the stream ended and the send is no longer in flight.
+}
+
+// Recv receives the next chunk and records the answer at the end of the stream.
+func (s *conversationStream) Recv() (*StreamChatCompletionResponse, error) {
+	chunk, err := s.ChatCompletionStream.Recv()
+	if err != nil {
+		if errors.Is(err, io.EOF) && !s.done {
+			resp := s.acc.Response()
+			if len(resp.Choices) > 0 {
+				s.conversation.commit(s.sent, resp.Choices[0].Message, resp.Usage)
+			}
+		}
+		s.finish()
+		return chunk, err
+	}
+	s.acc.Add(chunk)
+	return chunk, nil
+}
+
+// Close closes the stream. An answer that was not read to the end is not recorded.
+func (s *conversationStream) Close() error {
+	s.finish()
+	return s.ChatCompletionStream.Close()
+}
+
+// cancelStream cancels the request of the wrapped stream.
+func (s *conversationStream) cancelStream() bool {
+	return cancelStream(s.ChatCompletionStream)
+}
+
+// finish ends the send in flight once.
+func (s *conversationStream) finish() {
+	if !s.done {
+		s.done = true
+		s.conversation.endSend()
+	}
+}
+
+// addUsage returns the sum of two usages.
+func addUsage(a, b Usage) Usage {
+	return Usage{
+		PromptTokens:          a.PromptTokens + b.PromptTokens,
+		CompletionTokens:      a.CompletionTokens + b.CompletionTokens,
+		TotalTokens:           a.TotalTokens + b.TotalTokens,
+		PromptCacheHitTokens:  a.PromptCacheHitTokens + b.PromptCacheHitTokens,
+		PromptCacheMissTokens: a.PromptCacheMissTokens + b.PromptCacheMissTokens,
+		CompletionTokensDetails: CompletionTokensDetails{
+			ReasoningTokens: a.CompletionTokensDetails.ReasoningTokens + b.CompletionTokensDetails.ReasoningTokens,
+		},
+	}
+}
@@ -0,0 +1,198 @@
+package deepseek_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cohesion-org/deepseek-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// echoServer answers with the number of messages it received and records every request.
+func echoServer(t *testing.T, requests *[]deepseek.ChatCompletionRequest) *httptest.Server {
+	t.Helper()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var raw map[string]json.RawMessage
+		body, _ := io.ReadAll(r.Body)
+		require.NoError(t, json.Unmarshal(body, &raw))
+		var req deepseek.ChatCompletionRequest
+		require.NoError(t, json.Unmarshal(body, &req))
+		*requests = append(*requests, req)
+
+		content := fmt.Sprintf("seen %d", len(req.Messages))
+		if _, ok := raw["stream"]; ok {
+			fmt.Fprintf(w, "data: {\"id\":\"s\",\"choices\":[{\"index\":0,\"delta\":{\"role\":\"assistant\",\"content\":%q}}]}\n\ndata: [DONE]\n\n", content)
+			return
+		}
+		json.NewEncoder(w).Encode(deepseek.ChatCompletionResponse{
+			ID: "c",
+			Choices: []deepseek.Choice{{Message: deepseek.Message{
+				Role:             deepseek.ChatMessageRoleAssistant,
+				Content:          content,
+				ReasoningContent: "thinking",
+			}}},
+			Usage: deepseek.Usage{PromptTokens: 2, CompletionTokens: 1, TotalTokens: 3},
+		})
+	}))
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func TestConversation(t *testing.T) {
+	var requests []deepseek.ChatCompletionRequest
+	ts := echoServer(t, &requests)
+	client, err := deepseek.NewClientWithOptions("token", deepseek.WithBaseURL(ts.URL+"/"))
+	require.NoError(t, err)
+
+	conv := deepseek.NewConversation(client, deepseek.ChatCompletionRequest{
+		Model:    deepseek.DeepSeekReasoner,
+		Messages: []deepseek.ChatCompletionMessage{{Role: deepseek.ChatMessageRoleSystem, Content: "be brief"}},
+	})
+
+	resp, err := conv.Send(context.Background(), "hello")
+	require.NoError(t, err)
+	assert.Equal(t, "seen 2", resp.Choices[0].Message.Content)
+	assert.Equal(t, 3, conv.Len())
+
+	history := conv.Messages()
+	assert.Equal(t, deepseek.ChatMessageRoleAssistant, history[2].Role)
+	assert.Equal(t, "thinking", history[2].ReasoningContent, "reasoning is kept in the history")
+
+	_, err = conv.Send(context.Background(), "again")
+	require.NoError(t, err)
+	require.Len(t, requests, 2)
+	for _, msg := range requests[1].Messages {
+		assert.Empty(t, msg.ReasoningContent, "reasoning must not be sent to deepseek-reasoner")
+	}
+	assert.Equal(t, 5, conv.Len())
+}
+
+func TestConversationStream(t *testing.T) {
+	var requests []deepseek.ChatCompletionRequest
+	ts := echoServer(t, &requests)
+	client, err := deepseek.NewClientWithOptions("token", deepseek.WithBaseURL(ts.URL+"/"))
+	require.NoError(t, err)
+
+	conv := deepseek.NewConversation(client, deepseek.ChatCompletionRequest{Model: deepseek.DeepSeekChat})
+
+	stream, err := conv.SendStream(context.Background(), "hello")
+	require.NoError(t, err)
+	assert.Equal(t, 0, conv.Len(), "the turn is recorded only when the stream ends")
+	_, err = conv.Send(context.Background(), "too early")
+	require.ErrorIs(t, err, deepseek.ErrConversationBusy, "the stream is still in flight")
+
+	resp, err := deepseek.CollectStream(stream)
+	require.NoError(t, err)
+	assert.Equal(t, "seen 1", resp.Choices[0].Message.Content)
+
+	history := conv.Messages()
+	require.Len(t, history, 2)
+	assert.Equal(t, "hello", history[0].Content)
+	assert.Equal(t, "seen 1", history[1].Content)
+
+	stream, err = conv.SendStream(context.Background(), "closed early")
+	require.NoError(t, err)
+	require.NoError(t, stream.Close())
+	_, err = conv.Send(context.Background(), "after close")
+	require.NoError(t, err, "closing the stream ends the send")
+	assert.Equal(t, 4, conv.Len())
+}
+
+func TestConversationStripReasoningCapability(t *testing.T) {
+	var requests []deepseek.ChatCompletionRequest
+	ts := echoServer(t, &requests)
+	registry := deepseek.NewModelRegistry(deepseek.ModelCapabilities{ID: "custom-reasoner", StripReasoning: true})
+	client, err := deepseek.NewClientWithOptions("token", deepseek.WithBaseURL(ts.URL+"/"), deepseek.WithModelRegistry(registry))
+	require.NoError(t, err)
+
+	conv := deepseek.NewConversation(client, deepseek.ChatCompletionRequest{Model: "custom-reasoner"})
+	conv.Append(deepseek.ChatCompletionMessage{Role: deepseek.ChatMessageRoleAssistant, Content: "a", ReasoningContent: "thinking"})
+	_, err = conv.Send(context.Background(), "hello")
+	require.NoError(t, err)
+	assert.Empty(t, requests[0].Messages[0].ReasoningContent)
+
+	conv = deepseek.NewConversation(client, deepseek.ChatCompletionRequest{Model: deepseek.DeepSeekChat})
+	conv.Append(deepseek.ChatCompletionMessage{Role: deepseek.ChatMessageRoleAssistant, Content: "a", ReasoningContent: "thinking"})
+	_, err = conv.Send(context.Background(), "hello")
+	require.NoError(t, err)
+	assert.Equal(t, "thinking", requests[1].Messages[0].ReasoningContent, "unregistered models keep the reasoning")
+}
+
+func TestConversationFailedSend(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer ts.Close()
+	client, err := deepseek.NewClientWithOptions("token", deepseek.WithBaseURL(ts.URL+"/"))
+	require.NoError(t, err)
+
+	conv := deepseek.NewConversation(client, deepseek.ChatCompletionRequest{Model: deepseek.DeepSeekChat})
+	_, err = conv.Send(context.Background(), "hello")
+	require.Error(t, err)
+	assert.Equal(t, 0, conv.Len())
+}
+
+func TestConversationCloneAndFork(t *testing.T) {
+	var requests []deepseek.ChatCompletionRequest
+	ts := echoServer(t, &requests)
+	client, err := deepseek.NewClientWithOptions("token", deepseek.WithBaseURL(ts.URL+"/"))
+	require.NoError(t, err)
+
+	conv := deepseek.NewConversation(client, deepseek.ChatCompletionRequest{Model: deepseek.DeepSeekChat})
+	_, err = conv.Send(context.Background(), "one")
+	require.NoError(t, err)
+
+	clone := conv.Clone()
+	_, err = clone.Send(context.Background(), "two")
+	require.NoError(t, err)
+	assert.Equal(t, 2, conv.Len())
+	assert.Equal(t, 4, clone.Len())
+
+	fork, err := clone.Fork(1)
+	require.NoError(t, err)
+	assert.Equal(t, []deepseek.ChatCompletionMessage{{Role: deepseek.ChatMessageRoleUser, Content: "one"}}, fork.Messages())
+
+	_, err = clone.Fork(10)
+	require.Error(t, err)
+}
+
+func TestConversationToolCalls(t *testing.T) {
+	calls := []deepseek.ToolCall{cityCall("a", "Paris")}
+	ts := toolCallingServer(t, false, calls...)
+	client, err := deepseek.NewClientWithOptions("token", deepseek.WithBaseURL(ts.URL+"/"))
+	require.NoError(t, err)
+	registry := deepseek.NewToolRegistry()
+	require.NoError(t, deepseek.RegisterTool(registry, "lookup", "Look up a city",
+		func(ctx context.Context, args cityArgs) (string, error) { return "sunny", nil }))
+
+	conv := deepseek.NewConversation(client, deepseek.ChatCompletionRequest{Model: deepseek.DeepSeekChat, Tools: registry.Tools()})
+	resp, err := conv.Send(context.Background(), "weather?")
+	require.NoError(t, err)
+
+	history := conv.Messages()
+	require.Len(t, history, 2)
+	assert.Equal(t, calls, history[1].ToolCalls)
+
+	toolMsg := registry.Dispatch(context.Background(), resp.Choices[0].Message.ToolCalls[0])
+	resp, err = conv.SendMessages(context.Background(), toolMsg)
+	require.NoError(t, err)
+	assert.Equal(t, "sunny", resp.Choices[0].Message.Content)
+	assert.Equal(t, 4, conv.Len())
+}
+
+func TestMapMessageKeepsToolCalls(t *testing.T) {
+	msg, err := deepseek.MapMessageToChatCompletionMessage(deepseek.Message{
+		Role:             deepseek.ChatMessageRoleAssistant,
+		ReasoningContent: "hidden",
+		ToolCalls:        []deepseek.ToolCall{cityCall("a", "Paris")},
+	})
+	require.NoError(t, err)
+	assert.Len(t, msg.ToolCalls, 1)
+	assert.Empty(t, msg.ReasoningContent)
+}
@@ -0,0 +1,40 @@
+package deepseek
+
+import "fmt"
+
+// Cost is the price of a single call, split by kind of token.
+type Cost struct {
+	CacheHit  float64 `json:"cache_hit"`  // Price of the prompt tokens served from the context cache.
+	CacheMiss float64 `json:"cache_miss"` // Price of the prompt tokens not served from the cache.
+	Output    float64 `json:"output"`     // Price of the completion tokens, including reasoning tokens.
+	Total     float64 `json:"total"`      // Sum of all parts.
+	Currency  string  `json:"currency"`   // Currency of the prices.
+}
+
+// CalculateCost returns the price of usage under pricing. Responses that do not report cache hits and misses,
+// as returned by some providers, are priced as cache misses. Reasoning tokens are part of the completion tokens
+// and are therefore priced as output.
+func CalculateCost(usage Usage, pricing ModelPricing) Cost {
+	hit := usage.PromptCacheHitTokens
+	miss := usage.PromptCacheMissTokens
+	if hit == 0 && miss == 0 {
+		miss = usage.PromptTokens
+	}
+	cost := Cost{
+		CacheHit:  float64(hit) * pricing.InputCacheHit / 1e6,
+		CacheMiss: float64(miss) * pricing.InputCacheMiss / 1e6,
+		Output:    float64(usage.CompletionTokens) * pricing.Output / 1e6,
+		Currency:  pricing.Currency,
+	}
+	cost.Total = cost.CacheHit + cost.CacheMiss + cost.Output
+	return cost
+}
+
+// EstimateCost returns the price of usage for model, using the prices in the client's model registry.
+func (c *Client) EstimateCost(model string, usage Usage) (Cost, error) {
+	caps, ok := c.modelRegistry().Lookup(model)
+	if !ok || caps.Pricing == nil {
+		return Cost{}, fmt.Errorf("no pricing known for model %q", model)
+	}
+	return CalculateCost(usage, *caps.Pricing), nil
+}
@@ -0,0 +1,42 @@
+package deepseek_test
+
+import (
+	"testing"
+
+	"github.com/cohesion-org/deepseek-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCalculateCost(t *testing.T) {
+	pricing := deepseek.ModelPricing{InputCacheHit: 0.1, InputCacheMiss: 1, Output: 2, Currency: "USD"}
+
+	cost := deepseek.CalculateCost(deepseek.Usage{
+		PromptTokens:            3_000_000,
+		PromptCacheHitTokens:    2_000_000,
+		PromptCacheMissTokens:   1_000_000,
+		CompletionTokens:        500_000,
+		CompletionTokensDetails: deepseek.CompletionTokensDetails{ReasoningTokens: 400_000},
+	}, pricing)
+	assert.InDelta(t, 0.2, cost.CacheHit, 1e-9)
+	assert.InDelta(t, 1.0, cost.CacheMiss, 1e-9)
+	assert.InDelta(t, 1.0, cost.Output, 1e-9, "reasoning tokens are part of the completion tokens")
+	assert.InDelta(t, 2.2, cost.Total, 1e-9)
+	assert.Equal(t, "USD", cost.Currency)
+
+	// Without cache information every prompt token is a miss.
+	cost = deepseek.CalculateCost(deepseek.Usage{PromptTokens: 1_000_000}, pricing)
+	assert.InDelta(t, 1.0, cost.Total, 1e-9)
+}
+
+func TestClientEstimateCost(t *testing.T) {
+	client, err := deepseek.NewClientWithOptions("token")
+	require.NoError(t, err)
+
+	cost, err := client.EstimateCost(deepseek.DeepSeekChat, deepseek.Usage{PromptCacheMissTokens: 1_000_000})
+	require.NoError(t, err)
+	assert.Positive(t, cost.Total)
+
+	_, err = client.EstimateCost(deepseek.OpenRouterDeepSeekR1, deepseek.Usage{PromptTokens: 10})
+	require.Error(t, err, "models without pricing cannot be priced")
+}
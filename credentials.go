@@ -0,0 +1,150 @@
+package deepseek
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrNoCredential is returned when a CredentialProvider has no API key to give.
+var ErrNoCredential = errors.New("no credential")
+
+// CredentialProvider returns the API key to send a request with. It is consulted for every request,
+// so a rotated key is picked up without creating a new client. Implementations must be safe for concurrent use.
+type CredentialProvider interface {
+	Credential(ctx context.Context) (string, error)
+}
+
+// WithCredentialProvider gets the API key of every request from provider instead of the client's AuthToken.
+// A KeyPool set with WithKeyPool takes precedence over it.
+func WithCredentialProvider(provider CredentialProvider) Option {
+	return func(c *Client) error {
+		if provider == nil {
+			return fmt.Errorf("credential provider cannot be nil")
+		}
+		c.Credentials = provider
+		return nil
+	}
+}
+
+// StaticCredential is a fixed API key.
+type StaticCredential string
+
+// Credential returns the key.
+func (s StaticCredential) Credential(ctx context.Context) (string, error) {
+	if s == "" {
+		return "", fmt.Errorf("%w: the static API key is empty", ErrNoCredential)
+	}
+	return string(s), nil
+}
+
+// EnvCredential reads the API key from the named environment variable on every request.
+type EnvCredential string
+
+// DefaultCredential reads the API key from the DEEPSEEK_API_KEY environment variable.
+const DefaultCredential EnvCredential = "DEEPSEEK_API_KEY"
+
+// Credential returns the value of the environment variable.
+func (e EnvCredential) Credential(ctx context.Context) (string, error) {
+	key, ok := os.LookupEnv(string(e))
+	if !ok {
+		return "", fmt.Errorf("%w: environment variable %s is not set", ErrNoCredential, string(e))
+	}
+	if key == "" {
+		return "", fmt.Errorf("%w: environment variable %s is empty", ErrNoCredential, string(e))
+	}
+	return key, nil
+}
+
+// FileCredential reads the API key from a file, such as a secret mounted by an orchestrator.
+// The file is read again whenever its size or modification time changes. Surrounding whitespace is trimmed.
+type FileCredential struct {
+	Path string
+
+	mu      sync.Mutex
+	key     string
+	size    int64
+	modTime time.Time
+}
+
+// NewFileCredential creates a provider reading the API key from the file at path.
+func NewFileCredential(path string) *FileCredential {
+	return &FileCredential{Path: path}
+}
+
+// Credential returns the key in the file, reading it again if it changed.
+func (f *FileCredential) Credential(ctx context.Context) (string, error) {
+	info, err := os.Stat(f.Path)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrNoCredential, err)
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.key != "" && info.Size() == f.size && info.ModTime().Equal(f.modTime) {
+		return f.key, nil
+	}
+	data, err := os.ReadFile(f.Path)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrNoCredential, err)
+	}
+	key := strings.TrimSpace(string(data))
+	if key == "" {
+		return "", fmt.Errorf("%w: credential file %s is empty", ErrNoCredential, f.Path)
+	}
+	f.key, f.size, f.modTime = key, info.Size(), info.ModTime()
+	return key, nil
+}
+
+// CommandCredential runs a command, such as a secret manager's CLI, and uses its trimmed output as the API key.
+// The key is cached for TTL so the command does not run for every request.
+type CommandCredential struct {
+	Command string
+	Args    []string
+	TTL     time.Duration // How long the output is used before the command runs again. Defaults to 5 minutes.
+
+	mu      sync.Mutex
+	key     string
+	expires time.Time
+}
+
+// NewCommandCredential creates a provider running command with args.
+func NewCommandCredential(command string, args ...string) *CommandCredential {
+	return &CommandCredential{Command: command, Args: args}
+}
+
+// Credential returns the cached key, running the command if it expired.
+func (p *CommandCredential) Credential(ctx context.Context) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.key != "" && time.Now().Before(p.expires) {
+		return p.key, nil
+	}
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, p.Command, p.Args...)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("%w: command %s failed: %w: %s", ErrNoCredential, p.Command, err, msg)
+		}
+		return "", fmt.Errorf("%w: command %s failed: %w", ErrNoCredential, p.Command, err)
+	}
+	key := strings.TrimSpace(string(out))
+	if key == "" {
+		return "", fmt.Errorf("%w: command %s printed no key", ErrNoCredential, p.Command)
+	}
+
+	ttl := p.TTL
+	if ttl <= 0 {
+		ttl = 5 * time.Minute
+	}
+	p.key, p.expires = key, time.Now().Add(ttl)
+	return key, nil
+}
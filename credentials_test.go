@@ -0,0 +1,105 @@
+package deepseek_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/cohesion-org/deepseek-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// authServer returns a server answering chat completions and a function returning the last authorization header.
+func authServer(t *testing.T) (*httptest.Server, func() string) {
+	t.Helper()
+	authorization := make(chan string, 100)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization <- r.Header.Get("Authorization")
+		w.Write([]byte(retryChatResponse))
+	}))
+	t.Cleanup(ts.Close)
+	return ts, func() string { return <-authorization }
+}
+
+func TestCredentialProviderPerRequest(t *testing.T) {
+	ts, authorization := authServer(t)
+	path := filepath.Join(t.TempDir(), "key")
+	require.NoError(t, os.WriteFile(path, []byte("first\n"), 0o600))
+	client, err := deepseek.NewClientWithOptions("", deepseek.WithBaseURL(ts.URL+"/"),
+		deepseek.WithCredentialProvider(deepseek.NewFileCredential(path)))
+	require.NoError(t, err, "the token may be empty with a credential provider")
+
+	_, err = client.CreateChatCompletion(context.Background(), chatRequest(deepseek.DeepSeekChat))
+	require.NoError(t, err)
+	assert.Equal(t, "Bearer first", authorization())
+
+	// The rotated key is picked up without a new client.
+	require.NoError(t, os.WriteFile(path, []byte("second-key"), 0o600))
+	_, err = client.CreateChatCompletion(context.Background(), chatRequest(deepseek.DeepSeekChat))
+	require.NoError(t, err)
+	assert.Equal(t, "Bearer second-key", authorization())
+
+	require.NoError(t, os.Remove(path))
+	_, err = client.CreateChatCompletion(context.Background(), chatRequest(deepseek.DeepSeekChat))
+	require.ErrorIs(t, err, deepseek.ErrNoCredential)
+}
+
+func TestNewClientEnvCredential(t *testing.T) {
+	ts, authorization := authServer(t)
+	t.Setenv("DEEPSEEK_API_KEY", "")
+	os.Unsetenv("DEEPSEEK_API_KEY")
+	client := deepseek.NewClient("", ts.URL+"/")
+	require.NotNil(t, client)
+
+	_, err := client.CreateChatCompletion(context.Background(), chatRequest(deepseek.DeepSeekChat))
+	require.ErrorIs(t, err, deepseek.ErrNoCredential)
+	assert.Contains(t, err.Error(), "DEEPSEEK_API_KEY is not set")
+
+	t.Setenv("DEEPSEEK_API_KEY", "from-env")
+	_, err = client.CreateChatCompletion(context.Background(), chatRequest(deepseek.DeepSeekChat))
+	require.NoError(t, err)
+	assert.Equal(t, "Bearer from-env", authorization())
+}
+
+func TestCredentialProviders(t *testing.T) {
+	ctx := context.Background()
+
+	key, err := deepseek.StaticCredential("static").Credential(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "static", key)
+	_, err = deepseek.StaticCredential("").Credential(ctx)
+	require.ErrorIs(t, err, deepseek.ErrNoCredential)
+
+	t.Setenv("TEST_CREDENTIAL", "")
+	_, err = deepseek.EnvCredential("TEST_CREDENTIAL").Credential(ctx)
+	require.ErrorIs(t, err, deepseek.ErrNoCredential)
+
+	path := filepath.Join(t.TempDir(), "key")
+	require.NoError(t, os.WriteFile(path, []byte("  \n"), 0o600))
+	_, err = deepseek.NewFileCredential(path).Credential(ctx)
+	require.ErrorIs(t, err, deepseek.ErrNoCredential, "empty files are rejected")
+
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh is not available")
+	}
+	counter := filepath.Join(t.TempDir(), "runs")
+	cmd := deepseek.NewCommandCredential("sh", "-c", "echo run >> "+counter+"; echo ' from-command '")
+	for i := 0; i < 2; i++ {
+		key, err = cmd.Credential(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, "from-command", key)
+	}
+	runs, err := os.ReadFile(counter)
+	require.NoError(t, err)
+	assert.Equal(t, "run\n", string(runs), "the output is cached")
+
+	cmd = deepseek.NewCommandCredential("sh", "-c", "echo denied >&2; exit 3")
+	_, err = cmd.Credential(ctx)
+	require.ErrorIs(t, err, deepseek.ErrNoCredential)
+	assert.Contains(t, err.Error(), "denied")
+}
@@ -11,20 +11,25 @@ import (
 
 // ExternalProviders demonstrates how to use the Deepseek client with external providers.
 // This is not the library you should be using for this but if you do decide to use other models, they can
-// be accessed by changing the baseURL to the desired provider and replacing model with the desired model name.
-// For instance, using a mistral model from OpenRouter would require changing the baseURL to "https://openrouter.ai/api/v1/"
-// and the model to "mistralai/mistral-7b-instruct"
+// be accessed with a provider profile, which sets the base URL, path, auth header and quirks of the provider,
+// and by replacing model with the desired model name.
+// For instance, using a mistral model from OpenRouter would require deepseek.WithProvider(deepseek.OpenRouter{})
+// and the model "mistralai/mistral-7b-instruct".
+// Other profiles are deepseek.SiliconFlow{}, deepseek.VLLM{BaseURL: ...} and deepseek.OllamaOpenAI{}.
 
 func ExternalProviders() {
 
 	// Azure
-	baseURL := "https://models.inference.ai.azure.com/"
+	provider := deepseek.Azure{Endpoint: os.Getenv("AZURE_ENDPOINT")}
 
 	// OpenRouter
-	// baseURL := "https://openrouter.ai/api/v1/"
+	// provider := deepseek.OpenRouter{Referer: "https://example.com", Title: "My App"}
 
 	// Set up the Deepseek client
-	client := deepseek.NewClient(os.Getenv("PROVIDER_API_KEY"), baseURL)
+	client, err := deepseek.NewClientWithOptions(os.Getenv("PROVIDER_API_KEY"), deepseek.WithProvider(provider))
+	if err != nil {
+		log.Fatalf("error: %v", err)
+	}
 
 	// Create a chat completion request
 	request := &deepseek.ChatCompletionRequest{
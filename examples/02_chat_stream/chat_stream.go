@@ -2,9 +2,7 @@ package deepseek_examples
 
 import (
 	"context"
-	"errors"
 	"fmt"
-	"io"
 	"log"
 	"os"
 
@@ -28,13 +26,8 @@ func Streaming() {
 		log.Fatalf("ChatCompletionStream error: %v", err)
 	}
 	var fullMessage string
-	defer stream.Close()
-	for {
-		response, err := stream.Recv()
-		if errors.Is(err, io.EOF) {
-			fmt.Println("\nStream finished")
-			break
-		}
+	// StreamAll closes the stream once the loop ends.
+	for response, err := range deepseek.StreamAll(stream) {
 		if err != nil {
 			fmt.Printf("\nStream error: %v\n", err)
 			break
@@ -44,6 +37,7 @@ func Streaming() {
 			log.Println(choice.Delta.Content)
 		}
 	}
+	fmt.Println("\nStream finished")
 	log.Println("The full message is: ", fullMessage)
 }
 
@@ -66,13 +60,7 @@ func StreamingWithReasoningContent() {
 
 	var fullMessage string
 	var fullReasoning string
-	defer stream.Close()
-	for {
-		response, err := stream.Recv()
-		if errors.Is(err, io.EOF) {
-			fmt.Println("\nStream finished")
-			break
-		}
+	for response, err := range deepseek.StreamAll(stream) {
 		if err != nil {
 			fmt.Printf("\nStream error: %v\n", err)
 			break
@@ -92,6 +80,7 @@ func StreamingWithReasoningContent() {
 				streamUsage.PromptTokens, streamUsage.CompletionTokens, streamUsage.TotalTokens)
 		}
 	}
+	fmt.Println("\nStream finished")
 	log.Println("Full message: ", fullMessage)
 	log.Println("\nFull reasoning: ", fullReasoning)
 }
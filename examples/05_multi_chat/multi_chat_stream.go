@@ -2,8 +2,6 @@ package deepseek_examples
 
 import (
 	"context"
-	"errors"
-	"io"
 	"log"
 
 	deepseek "github.com/cohesion-org/deepseek-go"
@@ -65,15 +63,9 @@ func streamChatCompletion(ctx context.Context, client *deepseek.Client, messages
 	if err != nil {
 		return "", err
 	}
-	defer stream.Close()
 
 	var fullMessage string
-	for {
-		response, err := stream.Recv()
-		if errors.Is(err, io.EOF) {
-			log.Println("Stream finished")
-			break
-		}
+	for response, err := range deepseek.StreamAll(stream) {
 		if err != nil {
 			return "", err
 		}
@@ -81,5 +73,6 @@ func streamChatCompletion(ctx context.Context, client *deepseek.Client, messages
 			fullMessage += choice.Delta.Content // Accumulate chunk content
 		}
 	}
+	log.Println("Stream finished")
 	return fullMessage, nil
 }
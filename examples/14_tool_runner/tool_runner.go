@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/cohesion-org/deepseek-go"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Println("Usage: tool-runner <message>")
+		return
+	}
+	ToolRunner(os.Args[1])
+}
+
+// WeatherArgs are the arguments of the GetWeather tool. The JSON Schema sent to the model is derived from the tags.
+type WeatherArgs struct {
+	City string `json:"city" description:"Name of the city"`
+	Unit string `json:"unit,omitempty" enum:"celsius,fahrenheit" description:"Temperature unit"`
+}
+
+// ToolRunner lets the runner call the model, run the requested tools and send the results back automatically.
+func ToolRunner(userMessage string) {
+	client := deepseek.NewClient(os.Getenv("DEEPSEEK_API_KEY"))
+
+	registry := deepseek.NewToolRegistry()
+	err := deepseek.RegisterTool(registry, "GetWeather", "Get the current weather in a city.",
+		func(ctx context.Context, args WeatherArgs) (string, error) {
+			return fmt.Sprintf("It is 21 degrees %s and sunny in %s.", args.Unit, args.City), nil
+		})
+	if err != nil {
+		log.Fatalf("error registering tool: %v", err)
+	}
+
+	runner := deepseek.NewToolRunner(client, registry)
+	runner.Parallel = true
+	runner.ToolTimeout = 10 * time.Second
+
+	result, err := runner.Run(context.Background(), &deepseek.ChatCompletionRequest{
+		Model: deepseek.DeepSeekChat,
+		Messages: []deepseek.ChatCompletionMessage{
+			{Role: deepseek.ChatMessageRoleUser, Content: userMessage},
+		},
+	})
+	if err != nil {
+		log.Fatalf("error: %v", err)
+	}
+
+	for i, step := range result.Steps {
+		for _, tool := range step.ToolResults {
+			fmt.Printf("step %d: %s(%s) -> %s\n", i+1, tool.Call.Function.Name, tool.Call.Function.Arguments, tool.Content)
+		}
+	}
+	fmt.Println("response:", result.Response.Choices[0].Message.Content)
+}
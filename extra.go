@@ -0,0 +1,119 @@
+package deepseek
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+)
+
+// Extra body fields and headers pass provider-specific parameters through the client, such as OpenRouter's
+// "provider" and "transforms", vLLM's "guided_json", or the "api-key" header of Azure.
+//
+// Body fields are merged into the JSON of chat and FIM requests. From lowest to highest precedence:
+//  1. Client.ExtraBody
+//  2. the typed fields of the request
+//  3. the ExtraBody of the request
+//
+// So a request's ExtraBody can override anything, including a typed field whose zero value is omitted,
+// such as "temperature": 0, while the client's ExtraBody only fills in fields the request leaves unset.
+//
+// Headers are added to every request of the client, replacing the default ones of the same name.
+// From lowest to highest precedence:
+//  1. the default headers, such as Authorization and Content-Type
+//  2. Client.ExtraHeaders
+//  3. the headers attached with ContextWithExtraHeaders
+
+// extraHeadersKey is the context key of the per-request extra headers.
+type extraHeadersKey struct{}
+
+// WithExtraBody adds fields to the body of every chat and FIM request sent by the client.
+func WithExtraBody(fields map[string]any) Option {
+	return func(c *Client) error {
+		if c.ExtraBody == nil {
+			c.ExtraBody = make(map[string]any, len(fields))
+		}
+		maps.Copy(c.ExtraBody, fields)
+		return nil
+	}
+}
+
+// WithExtraHeaders adds headers to every request sent by the client.
+func WithExtraHeaders(headers map[string]string) Option {
+	return func(c *Client) error {
+		if c.ExtraHeaders == nil {
+			c.ExtraHeaders = make(map[string]string, len(headers))
+		}
+		for key, value := range headers {
+			if key == "" {
+				return fmt.Errorf("header name cannot be empty")
+			}
+			c.ExtraHeaders[key] = value
+		}
+		return nil
+	}
+}
+
+// ContextWithExtraHeaders returns a context that adds headers to the requests sent with it.
+// Headers already attached to ctx are kept unless they are overwritten.
+func ContextWithExtraHeaders(ctx context.Context, headers map[string]string) context.Context {
+	merged := maps.Clone(ExtraHeadersFromContext(ctx))
+	if merged == nil {
+		merged = make(map[string]string, len(headers))
+	}
+	maps.Copy(merged, headers)
+	return context.WithValue(ctx, extraHeadersKey{}, merged)
+}
+
+// ExtraHeadersFromContext returns the extra headers attached to ctx.
+func ExtraHeadersFromContext(ctx context.Context) map[string]string {
+	headers, _ := ctx.Value(extraHeadersKey{}).(map[string]string)
+	return maps.Clone(headers)
+}
+
+// setExtraHeaders sets the client's and the request context's extra headers on req.
+func (c *Client) setExtraHeaders(req *http.Request) {
+	for key, value := range c.ExtraHeaders {
+		req.Header.Set(key, value)
+	}
+	for key, value := range ExtraHeadersFromContext(req.Context()) {
+		req.Header.Set(key, value)
+	}
+}
+
+// requestBody marshals request and merges the client's and the request's extra body fields into it.
+// The client's provider profile is applied before the request's extra body, which is sent as is.
+func (c *Client) requestBody(request any, extra map[string]any) (json.RawMessage, error) {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+	if len(c.ExtraBody) == 0 && len(extra) == 0 && c.Profile == nil {
+		return body, nil
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return nil, err
+	}
+	for key, value := range c.ExtraBody {
+		if _, ok := fields[key]; ok {
+			continue
+		}
+		if fields[key], err = json.Marshal(value); err != nil {
+			return nil, fmt.Errorf("error marshaling extra body field %q: %w", key, err)
+		}
+	}
+	if c.Profile != nil {
+		if err := c.Profile.adaptBody(fields); err != nil {
+			return nil, err
+		}
+	}
+	for key, value := range extra {
+		if fields[key], err = json.Marshal(value); err != nil {
+			return nil, fmt.Errorf("error marshaling extra body field %q: %w", key, err)
+		}
+	}
+	return json.Marshal(fields)
+}
@@ -0,0 +1,77 @@
+package deepseek_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cohesion-org/deepseek-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExtraBodyAndHeaders(t *testing.T) {
+	var body map[string]any
+	var header http.Header
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header.Clone()
+		body = nil
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		w.Write([]byte(retryChatResponse))
+	}))
+	defer ts.Close()
+
+	client, err := deepseek.NewClientWithOptions("token", deepseek.WithBaseURL(ts.URL+"/"),
+		deepseek.WithExtraBody(map[string]any{"transforms": []string{"middle-out"}, "model": "ignored", "seed": 1}),
+		deepseek.WithExtraHeaders(map[string]string{"HTTP-Referer": "https://example.com", "X-Title": "app", "api-key": "client"}))
+	require.NoError(t, err)
+
+	ctx := deepseek.ContextWithExtraHeaders(context.Background(), map[string]string{"api-key": "request"})
+	_, err = client.CreateChatCompletion(ctx, &deepseek.ChatCompletionRequest{
+		Model:     deepseek.DeepSeekChat,
+		Messages:  []deepseek.ChatCompletionMessage{{Role: deepseek.ChatMessageRoleUser, Content: "hi"}},
+		ExtraBody: map[string]any{"seed": 42, "temperature": 0, "provider": map[string]any{"order": []string{"deepseek"}}},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, deepseek.DeepSeekChat, body["model"], "typed fields win over the client's extra body")
+	assert.Equal(t, []any{"middle-out"}, body["transforms"])
+	assert.Equal(t, float64(42), body["seed"], "the request's extra body wins over the client's")
+	assert.Equal(t, float64(0), body["temperature"], "the request's extra body can send omitted zero values")
+	assert.Equal(t, map[string]any{"order": []any{"deepseek"}}, body["provider"])
+
+	assert.Equal(t, "https://example.com", header.Get("HTTP-Referer"))
+	assert.Equal(t, "app", header.Get("X-Title"))
+	assert.Equal(t, "request", header.Get("api-key"), "context headers win over client headers")
+	assert.Equal(t, "Bearer token", header.Get("Authorization"))
+
+	_, err = client.CreateFIMCompletion(context.Background(), &deepseek.FIMCompletionRequest{
+		Model:     deepseek.DeepSeekChat,
+		Prompt:    "func main() {",
+		ExtraBody: map[string]any{"bad": make(chan int)},
+	})
+	require.ErrorContains(t, err, `extra body field "bad"`)
+
+	_, err = deepseek.NewClientWithOptions("token", deepseek.WithExtraHeaders(map[string]string{"": "x"}))
+	require.Error(t, err)
+}
+
+func TestChatRequestBuilderExtraBody(t *testing.T) {
+	var body map[string]any
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		w.Write([]byte(retryChatResponse))
+	}))
+	defer ts.Close()
+
+	client, err := deepseek.NewClientWithOptions("token", deepseek.WithBaseURL(ts.URL+"/"))
+	require.NoError(t, err)
+	_, err = client.CreateChat(context.Background(), deepseek.NewChatRequestBuilder(deepseek.DeepSeekChat).
+		AddUserMessage("hi").
+		SetExtraBody("guided_json", map[string]any{"type": "object"}).
+		Build())
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{"type": "object"}, body["guided_json"])
+}
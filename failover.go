@@ -0,0 +1,294 @@
+package deepseek
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrAllBackendsFailed is returned by a FailoverClient when no backend could serve a call.
+// The error also wraps the error of every backend that was tried.
+var ErrAllBackendsFailed = errors.New("all backends failed")
+
+// Backend is one configured client of a FailoverClient.
+type Backend struct {
+	Name     string            // Unique name reported for the responses the backend serves.
+	Client   *Client           // Client configured for the backend's provider.
+	Models   map[string]string // Maps requested model names to the backend's names, e.g. DeepSeekReasoner to OpenRouterDeepSeekR1. Unmapped names are sent as is.
+	Priority int               // Backends with a lower priority are tried first.
+	Weight   int               // Share of the calls with BalanceWeightedRoundRobin. Defaults to 1.
+}
+
+// BalanceStrategy selects the backend tried first for a call.
+type BalanceStrategy int
+
+const (
+	// BalancePriority tries the healthy backends in order of priority, so the others only serve failovers.
+	BalancePriority BalanceStrategy = iota
+	// BalanceWeightedRoundRobin spreads calls over the healthy backends by weight. Failovers go by priority.
+	BalanceWeightedRoundRobin
+)
+
+// FailoverClient sends calls to one of several backends and fails over to the next one when a backend
+// errors. Backends failing MaxFailures times in a row are ejected for EjectionTime and only used when no
+// healthy backend is left. It is safe for concurrent use.
+type FailoverClient struct {
+	Strategy     BalanceStrategy
+	MaxFailures  int           // Consecutive failures before a backend is ejected. Defaults to 3.
+	EjectionTime time.Duration // How long an ejected backend is skipped. Defaults to 30 seconds.
+
+	// ShouldFailover reports whether an error is worth trying another backend for.
+	// Defaults to every error except invalid requests and the cancellation of the call's context.
+	ShouldFailover func(err error) bool
+
+	mu       sync.Mutex
+	backends []*backendState
+}
+
+// backendState is a backend with its health and round-robin state.
+type backendState struct {
+	Backend
+	failures     int
+	ejectedUntil time.Time
+	current      int // Smooth weighted round-robin counter.
+}
+
+// BackendHealth is the health of a backend, as returned by FailoverClient.Health.
+type BackendHealth struct {
+	Name         string
+	Failures     int       // Consecutive failures.
+	EjectedUntil time.Time // Zero unless the backend is ejected.
+}
+
+// NewFailoverClient creates a client balancing calls over backends with the given strategy.
+func NewFailoverClient(strategy BalanceStrategy, backends ...Backend) (*FailoverClient, error) {
+	if len(backends) == 0 {
+		return nil, fmt.Errorf("at least one backend is required")
+	}
+	fc := &FailoverClient{Strategy: strategy}
+	names := make(map[string]bool, len(backends))
+	for _, b := range backends {
+		if b.Client == nil {
+			return nil, fmt.Errorf("backend %q has no client", b.Name)
+		}
+		if b.Name == "" || names[b.Name] {
+			return nil, fmt.Errorf("backend names must be unique and not empty: %q", b.Name)
+		}
+		if b.Weight < 0 {
+			return nil, fmt.Errorf("weight of backend %q cannot be negative", b.Name)
+		}
+		if b.Weight == 0 {
+			b.Weight = 1
+		}
+		names[b.Name] = true
+		fc.backends = append(fc.backends, &backendState{Backend: b})
+	}
+	sort.SliceStable(fc.backends, func(i, j int) bool { return fc.backends[i].Priority < fc.backends[j].Priority })
+	return fc, nil
+}
+
+// Health returns the health of every backend, in order of priority.
+func (fc *FailoverClient) Health() []BackendHealth {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	now := time.Now()
+	health := make([]BackendHealth, len(fc.backends))
+	for i, b := range fc.backends {
+		health[i] = BackendHealth{Name: b.Name, Failures: b.failures}
+		if b.ejectedUntil.After(now) {
+			health[i].EjectedUntil = b.ejectedUntil
+		}
+	}
+	return health
+}
+
+// CreateChatCompletion sends the request to the first backend that answers it. The name of that backend
+// is set in the response's Backend field.
+func (fc *FailoverClient) CreateChatCompletion(
+	ctx context.Context,
+	request *ChatCompletionRequest,
+) (*ChatCompletionResponse, error) {
+	if request == nil {
+		return nil, fmt.Errorf("request cannot be nil")
+	}
+	var resp *ChatCompletionResponse
+	err := fc.try(ctx, func(b *backendState) error {
+		r := *request
+		r.Model = b.model(request.Model)
+		var err error
+		resp, err = b.Client.CreateChatCompletion(ctx, &r)
+		if err == nil {
+			resp.Backend = b.Name
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// CreateChatCompletionStream opens the stream on the first backend that accepts the request.
+// Calls fail over only while the stream is opened, not once chunks are received.
+// StreamBackend returns the name of the backend serving the stream.
+func (fc *FailoverClient) CreateChatCompletionStream(
+	ctx context.Context,
+	request *StreamChatCompletionRequest,
+) (ChatCompletionStream, error) {
+	if request == nil {
+		return nil, fmt.Errorf("request cannot be nil")
+	}
+	var stream ChatCompletionStream
+	err := fc.try(ctx, func(b *backendState) error {
+		r := *request
+		r.Model = b.model(request.Model)
+		s, err := b.Client.CreateChatCompletionStream(ctx, &r)
+		if err == nil {
+			stream = &backendStream{ChatCompletionStream: s, backend: b.Name}
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return stream, nil
+}
+
+// backendStream is a stream that knows the backend serving it.
+type backendStream struct {
+	ChatCompletionStream
+	backend string
+}
+
+// Backend returns the name of the backend serving the stream.
+func (s *backendStream) Backend() string {
+	return s.backend
+}
+
+// cancelStream cancels the request of the wrapped stream.
+func (s *backendStream) cancelStream() bool {
+	return cancelStream(s.ChatCompletionStream)
+}
+
+// StreamBackend returns the name of the backend serving a stream opened by a FailoverClient,
+// or "" for other streams.
+func StreamBackend(stream ChatCompletionStream) string {
+	if s, ok := stream.(interface{ Backend() string }); ok {
+		return s.Backend()
+	}
+	return ""
+}
+
+// model returns the backend's name for the requested model.
+func (b *backendState) model(requested string) string {
+	if mapped, ok := b.Models[requested]; ok {
+		return mapped
+	}
+	return requested
+}
+
+// try calls send with the backends in the order of the strategy until one succeeds or fails with an error
+// that is not worth a failover.
+func (fc *FailoverClient) try(ctx context.Context, send func(b *backendState) error) error {
+	var errs []error
+	for _, b := range fc.order() {
+		err := send(b)
+		if err == nil {
+			fc.report(b, true)
+			return nil
+		}
+		if !fc.shouldFailover(ctx, err) {
+			return err
+		}
+		fc.report(b, false)
+		errs = append(errs, fmt.Errorf("backend %q: %w", b.Name, err))
+	}
+	return fmt.Errorf("%w: %w", ErrAllBackendsFailed, errors.Join(errs...))
+}
+
+// order returns the backends to try: the healthy ones in the order of the strategy, then the ejected ones
+// in order of priority.
+func (fc *FailoverClient) order() []*backendState {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	now := time.Now()
+	var healthy, ejected []*backendState
+	for _, b := range fc.backends {
+		if b.ejectedUntil.After(now) {
+			ejected = append(ejected, b)
+		} else {
+			healthy = append(healthy, b)
+		}
+	}
+	if fc.Strategy == BalanceWeightedRoundRobin && len(healthy) > 1 {
+		first := fc.nextWeighted(healthy)
+		healthy = append([]*backendState{healthy[first]}, slices.Delete(slices.Clone(healthy), first, first+1)...)
+	}
+	return append(healthy, ejected...)
+}
+
+// nextWeighted picks a backend with smooth weighted round-robin and returns its index. The caller must hold mu.
+func (fc *FailoverClient) nextWeighted(backends []*backendState) int {
+	total, best := 0, 0
+	for i, b := range backends {
+		b.current += b.Weight
+		total += b.Weight
+		if b.current > backends[best].current {
+			best = i
+		}
+	}
+	backends[best].current -= total
+	return best
+}
+
+// report records the outcome of a call to b, ejecting it after too many consecutive failures.
+func (fc *FailoverClient) report(b *backendState, ok bool) {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	if ok {
+		b.failures = 0
+		b.ejectedUntil = time.Time{}
+		return
+	}
+	b.failures++
+	maxFailures := fc.MaxFailures
+	if maxFailures <= 0 {
+		maxFailures = 3
+	}
+	if b.failures >= maxFailures {
+		ejection := fc.EjectionTime
+		if ejection <= 0 {
+			ejection = 30 * time.Second
+		}
+		b.ejectedUntil = time.Now().Add(ejection)
+	}
+}
+
+// shouldFailover reports whether another backend should be tried after err.
+func (fc *FailoverClient) shouldFailover(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	if fc.ShouldFailover != nil {
+		return fc.ShouldFailover(err)
+	}
+	return DefaultShouldFailover(err)
+}
+
+// DefaultShouldFailover reports whether err may be specific to the backend. Invalid requests, which fail
+// on every backend, are not: validation errors and API errors with status 400 or 422.
+func DefaultShouldFailover(err error) bool {
+	if errors.Is(err, ErrInvalidRequest) || errors.Is(err, context.Canceled) {
+		return false
+	}
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode != http.StatusBadRequest && apiErr.StatusCode != http.StatusUnprocessableEntity
+	}
+	return true
+}
@@ -0,0 +1,167 @@
+package deepseek_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cohesion-org/deepseek-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeBackend is a server that answers chat completions and streams, or fails with status while it is set.
+type fakeBackend struct {
+	*httptest.Server
+	mu     sync.Mutex
+	status int
+	models []string
+}
+
+// newFakeBackend starts a fakeBackend.
+func newFakeBackend(t *testing.T) *fakeBackend {
+	t.Helper()
+	b := &fakeBackend{}
+	b.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Model  string `json:"model"`
+			Stream bool   `json:"stream"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		b.mu.Lock()
+		b.models = append(b.models, body.Model)
+		status := b.status
+		b.mu.Unlock()
+		if status != 0 {
+			w.WriteHeader(status)
+			w.Write([]byte(`{"code":1,"message":"failed"}`))
+			return
+		}
+		if body.Stream {
+			w.Write([]byte("data: {\"id\":\"s\",\"choices\":[{\"index\":0,\"delta\":{\"content\":\"hi\"}}]}\n\ndata: [DONE]\n\n"))
+			return
+		}
+		w.Write([]byte(retryChatResponse))
+	}))
+	t.Cleanup(b.Close)
+	return b
+}
+
+// fail makes the backend answer with status. Zero makes it succeed again.
+func (b *fakeBackend) fail(status int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.status = status
+}
+
+// calls returns the number of requests the backend received.
+func (b *fakeBackend) calls() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.models)
+}
+
+// backend returns a Backend sending to b.
+func (b *fakeBackend) backend(t *testing.T, name string, priority, weight int, models map[string]string) deepseek.Backend {
+	t.Helper()
+	client, err := deepseek.NewClientWithOptions("token", deepseek.WithBaseURL(b.URL+"/"))
+	require.NoError(t, err)
+	return deepseek.Backend{Name: name, Client: client, Priority: priority, Weight: weight, Models: models}
+}
+
+func chatRequest(model string) *deepseek.ChatCompletionRequest {
+	return &deepseek.ChatCompletionRequest{
+		Model:    model,
+		Messages: []deepseek.ChatCompletionMessage{{Role: deepseek.ChatMessageRoleUser, Content: "hi"}},
+	}
+}
+
+func TestFailoverClientPriority(t *testing.T) {
+	primary, secondary := newFakeBackend(t), newFakeBackend(t)
+	fc, err := deepseek.NewFailoverClient(deepseek.BalancePriority,
+		secondary.backend(t, "openrouter", 1, 0, map[string]string{deepseek.DeepSeekReasoner: deepseek.OpenRouterDeepSeekR1}),
+		primary.backend(t, "deepseek", 0, 0, nil))
+	require.NoError(t, err)
+	fc.MaxFailures = 2
+	fc.EjectionTime = time.Hour
+	ctx := context.Background()
+
+	resp, err := fc.CreateChatCompletion(ctx, chatRequest(deepseek.DeepSeekReasoner))
+	require.NoError(t, err)
+	assert.Equal(t, "deepseek", resp.Backend)
+
+	primary.fail(http.StatusServiceUnavailable)
+	for i := 0; i < 2; i++ {
+		resp, err = fc.CreateChatCompletion(ctx, chatRequest(deepseek.DeepSeekReasoner))
+		require.NoError(t, err)
+		assert.Equal(t, "openrouter", resp.Backend)
+	}
+	assert.Equal(t, []string{deepseek.OpenRouterDeepSeekR1, deepseek.OpenRouterDeepSeekR1}, secondary.models, "models are mapped")
+	assert.Equal(t, 3, primary.calls())
+
+	health := fc.Health()
+	assert.Equal(t, "deepseek", health[0].Name)
+	assert.False(t, health[0].EjectedUntil.IsZero(), "the primary is ejected after two failures")
+
+	stream, err := fc.CreateChatCompletionStream(ctx, &deepseek.StreamChatCompletionRequest{
+		Model:    deepseek.DeepSeekReasoner,
+		Messages: []deepseek.ChatCompletionMessage{{Role: deepseek.ChatMessageRoleUser, Content: "hi"}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "openrouter", deepseek.StreamBackend(stream))
+	_, err = deepseek.CollectStream(stream)
+	require.NoError(t, err)
+	assert.Equal(t, 3, primary.calls(), "ejected backends are skipped")
+
+	// Ejected backends are still tried when every healthy backend fails.
+	primary.fail(0)
+	secondary.fail(http.StatusBadGateway)
+	resp, err = fc.CreateChatCompletion(ctx, chatRequest(deepseek.DeepSeekReasoner))
+	require.NoError(t, err)
+	assert.Equal(t, "deepseek", resp.Backend)
+	assert.True(t, fc.Health()[0].EjectedUntil.IsZero(), "a success clears the ejection")
+}
+
+func TestFailoverClientErrors(t *testing.T) {
+	a, b := newFakeBackend(t), newFakeBackend(t)
+	fc, err := deepseek.NewFailoverClient(deepseek.BalancePriority, a.backend(t, "a", 0, 0, nil), b.backend(t, "b", 1, 0, nil))
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	a.fail(http.StatusBadRequest)
+	_, err = fc.CreateChatCompletion(ctx, chatRequest(deepseek.DeepSeekChat))
+	var apiErr *deepseek.APIError
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, 0, b.calls(), "invalid requests do not fail over")
+
+	a.fail(http.StatusInternalServerError)
+	b.fail(http.StatusTooManyRequests)
+	_, err = fc.CreateChatCompletion(ctx, chatRequest(deepseek.DeepSeekChat))
+	require.ErrorIs(t, err, deepseek.ErrAllBackendsFailed)
+	assert.Contains(t, err.Error(), `backend "a"`)
+	assert.Contains(t, err.Error(), `backend "b"`)
+
+	_, err = deepseek.NewFailoverClient(deepseek.BalancePriority, a.backend(t, "a", 0, 0, nil), b.backend(t, "a", 0, 0, nil))
+	require.Error(t, err, "backend names must be unique")
+	_, err = deepseek.NewFailoverClient(deepseek.BalancePriority)
+	require.Error(t, err)
+}
+
+func TestFailoverClientWeightedRoundRobin(t *testing.T) {
+	heavy, light := newFakeBackend(t), newFakeBackend(t)
+	fc, err := deepseek.NewFailoverClient(deepseek.BalanceWeightedRoundRobin,
+		heavy.backend(t, "heavy", 0, 3, nil), light.backend(t, "light", 0, 1, nil))
+	require.NoError(t, err)
+
+	served := make(map[string]int)
+	for i := 0; i < 8; i++ {
+		resp, err := fc.CreateChatCompletion(context.Background(), chatRequest(deepseek.DeepSeekChat))
+		require.NoError(t, err)
+		served[resp.Backend]++
+	}
+	assert.Equal(t, map[string]int{"heavy": 6, "light": 2}, served)
+}
@@ -1,29 +1,26 @@
 package deepseek
 
 import (
-	"bufio"
 	"context"
-	"encoding/json"
 	"fmt"
-	"io"
 	"net/http"
-	"strings"
 )
 
 // FIMCompletionRequest represents the request body for a Fill-In-the-Middle (FIM) completion.
 type FIMCompletionRequest struct {
-	Model            string   `json:"model"`                       // Model name to use for completion.
-	Prompt           string   `json:"prompt"`                      // The prompt to start the completion from.
-	Suffix           string   `json:"suffix,omitempty"`            // Optional: The suffix to complete the prompt with.
-	MaxTokens        int      `json:"max_tokens,omitempty"`        // Optional: Maximum tokens to generate, > 1 and <= 4000.
-	Temperature      float64  `json:"temperature,omitempty"`       // Optional: Sampling temperature, between 0 and 1.
-	TopP             float64  `json:"top_p,omitempty"`             // Optional: Nucleus sampling probability threshold.
-	N                int      `json:"n,omitempty"`                 // Optional: Number of completions to generate.
-	Logprobs         int      `json:"logprobs,omitempty"`          // Optional: Number of log probabilities to return.
-	Echo             bool     `json:"echo,omitempty"`              // Optional: Whether to echo the prompt in the completion.
-	Stop             []string `json:"stop,omitempty"`              // Optional: List of stop sequences.
-	PresencePenalty  float64  `json:"presence_penalty,omitempty"`  // Optional: Penalty for new tokens based on their presence in the text so far.
-	FrequencyPenalty float64  `json:"frequency_penalty,omitempty"` // Optional: Penalty for new tokens based on their frequency in the text so far.
+	Model            string         `json:"model"`                       // Model name to use for completion.
+	Prompt           string         `json:"prompt"`                      // The prompt to start the completion from.
+	Suffix           string         `json:"suffix,omitempty"`            // Optional: The suffix to complete the prompt with.
+	MaxTokens        int            `json:"max_tokens,omitempty"`        // Optional: Maximum tokens to generate, > 1 and <= 4000.
+	Temperature      float64        `json:"temperature,omitempty"`       // Optional: Sampling temperature, between 0 and 1.
+	TopP             float64        `json:"top_p,omitempty"`             // Optional: Nucleus sampling probability threshold.
+	N                int            `json:"n,omitempty"`                 // Optional: Number of completions to generate.
+	Logprobs         int            `json:"logprobs,omitempty"`          // Optional: Number of log probabilities to return.
+	Echo             bool           `json:"echo,omitempty"`              // Optional: Whether to echo the prompt in the completion.
+	Stop             []string       `json:"stop,omitempty"`              // Optional: List of stop sequences.
+	PresencePenalty  float64        `json:"presence_penalty,omitempty"`  // Optional: Penalty for new tokens based on their presence in the text so far.
+	FrequencyPenalty float64        `json:"frequency_penalty,omitempty"` // Optional: Penalty for new tokens based on their frequency in the text so far.
+	ExtraBody        map[string]any `json:"-"`                           // Optional: Provider-specific fields merged into the request body.
 }
 
 // FIMCompletionResponse represents the response body for a Fill-In-the-Middle (FIM) completion.
@@ -48,20 +45,21 @@ type FIMCompletionResponse struct {
 // FIMStreamCompletionRequest represents the request body for a streaming Fill-In-the-Middle (FIM) completion.
 // It's similar to FIMCompletionRequest but includes a `Stream` field.
 type FIMStreamCompletionRequest struct {
-	Model            string        `json:"model"`                       // Model name to use for completion.
-	Prompt           string        `json:"prompt"`                      // The prompt to start the completion from.
-	Stream           bool          `json:"stream"`                      // Whether to stream the completion.  This is the key difference.
-	StreamOptions    StreamOptions `json:"stream_options,omitempty"`    // Optional: Options for streaming the completion.
-	Suffix           string        `json:"suffix,omitempty"`            // Optional: The suffix to complete the prompt with.
-	MaxTokens        int           `json:"max_tokens,omitempty"`        // Optional: Maximum tokens to generate, > 1 and <= 4000.
-	Temperature      float64       `json:"temperature,omitempty"`       // Optional: Sampling temperature, between 0 and 1.
-	TopP             float64       `json:"top_p,omitempty"`             // Optional: Nucleus sampling probability threshold.
-	N                int           `json:"n,omitempty"`                 // Optional: Number of completions to generate.
-	Logprobs         int           `json:"logprobs,omitempty"`          // Optional: Number of log probabilities to return.
-	Echo             bool          `json:"echo,omitempty"`              // Optional: Whether to echo the prompt in the completion.
-	Stop             []string      `json:"stop,omitempty"`              // Optional: List of stop sequences.
-	PresencePenalty  float64       `json:"presence_penalty,omitempty"`  // Optional: Penalty for new tokens based on their presence in the text so far.
-	FrequencyPenalty float64       `json:"frequency_penalty,omitempty"` // Optional: Penalty for new tokens based on their frequency in the text so far.
+	Model            string         `json:"model"`                       // Model name to use for completion.
+	Prompt           string         `json:"prompt"`                      // The prompt to start the completion from.
+	Stream           bool           `json:"stream"`                      // Whether to stream the completion.  This is the key difference.
+	StreamOptions    StreamOptions  `json:"stream_options,omitempty"`    // Optional: Options for streaming the completion.
+	Suffix           string         `json:"suffix,omitempty"`            // Optional: The suffix to complete the prompt with.
+	MaxTokens        int            `json:"max_tokens,omitempty"`        // Optional: Maximum tokens to generate, > 1 and <= 4000.
+	Temperature      float64        `json:"temperature,omitempty"`       // Optional: Sampling temperature, between 0 and 1.
+	TopP             float64        `json:"top_p,omitempty"`             // Optional: Nucleus sampling probability threshold.
+	N                int            `json:"n,omitempty"`                 // Optional: Number of completions to generate.
+	Logprobs         int            `json:"logprobs,omitempty"`          // Optional: Number of log probabilities to return.
+	Echo             bool           `json:"echo,omitempty"`              // Optional: Whether to echo the prompt in the completion.
+	Stop             []string       `json:"stop,omitempty"`              // Optional: List of stop sequences.
+	PresencePenalty  float64        `json:"presence_penalty,omitempty"`  // Optional: Penalty for new tokens based on their presence in the text so far.
+	FrequencyPenalty float64        `json:"frequency_penalty,omitempty"` // Optional: Penalty for new tokens based on their frequency in the text so far.
+	ExtraBody        map[string]any `json:"-"`                           // Optional: Provider-specific fields merged into the request body.
 }
 
 // FIMStreamChoice represents a single choice within a streaming Fill-In-the-Middle (FIM) completion response.
@@ -93,14 +91,25 @@ type FIMStreamCompletionResponse struct {
 	Object string `json:"object"`
 	// Usage statistics for the completion request (if available). May be `nil`.
 	Usage *StreamUsage `json:"usage,omitempty"`
+	// Server-sent event metadata of the chunk (not part of the JSON payload).
+	Event *StreamEvent `json:"-"`
+	// In-band error sent by some providers. FIMRecv returns it as *APIError.
+	Error *StreamError `json:"error,omitempty"`
+}
+
+// streamError returns the in-band error of the chunk, if any.
+func (r *FIMStreamCompletionResponse) streamError() *StreamError {
+	return r.Error
 }
 
 // fimCompletionStream implements the ChatCompletionStream interface.
 type fimCompletionStream struct {
-	ctx    context.Context    // Context for cancellation.
-	cancel context.CancelFunc // Cancel function for the context.
-	resp   *http.Response     // HTTP response from the API call.
-	reader *bufio.Reader      // Reader for the response body.
+	ctx      context.Context    // Context for cancellation.
+	cancel   context.CancelFunc // Cancel function for the context.
+	resp     *http.Response     // HTTP response from the API call.
+	decoder  *sseDecoder        // Server-sent event decoder for the response body.
+	release  func()             // Releases the limiter slot held by the stream.
+	watchdog *streamWatchdog    // Enforces the first-token and idle timeouts, nil if disabled.
 }
 
 // FIMChatCompletionStream is an interface for receiving streaming chat completion responses.
@@ -110,41 +119,38 @@ type FIMChatCompletionStream interface {
 }
 
 // FIMRecv receives the next response from the stream.
+// In-band error events are returned as *APIError.
 func (s *fimCompletionStream) FIMRecv() (*FIMStreamCompletionResponse, error) {
-	reader := s.reader
-	for {
-		line, err := reader.ReadString('\n') // Read until newline
-		if err != nil {
-			if err == io.EOF {
-				return nil, io.EOF
-			}
-			return nil, fmt.Errorf("error reading stream: %w", err)
-		}
-
-		line = strings.TrimSpace(line)
-		if line == "data: [DONE]" {
-			return nil, io.EOF // End of stream
-		}
-		if len(line) > 6 && line[:6] == "data: " {
-			trimmed := line[6:] // Trim the "data: " prefix
-			var response FIMStreamCompletionResponse
-			if err := json.Unmarshal([]byte(trimmed), &response); err != nil {
-				return nil, fmt.Errorf("unmarshal error: %w, raw data: %s", err, trimmed)
-			}
-			if response.Usage == nil {
-				response.Usage = &StreamUsage{}
-			}
-			return &response, nil
-		}
+	var response FIMStreamCompletionResponse
+	s.watchdog.beforeRead()
+	event, err := s.decoder.decode(s.resp.StatusCode, &response)
+	s.watchdog.afterRead(err == nil)
+	if err != nil {
+		return nil, s.watchdog.wrap(err)
+	}
+	response.Event = event
+	if response.Usage == nil {
+		response.Usage = &StreamUsage{}
 	}
+	return &response, nil
 }
 
 // FIMClose terminates the stream.
 func (s *fimCompletionStream) FIMClose() error {
+	s.watchdog.stop()
 	s.cancel()
+	if s.release != nil {
+		s.release()
+	}
 	err := s.resp.Body.Close()
 	if err != nil {
 		return fmt.Errorf("failed to close response body: %w", err)
 	}
 	return nil
 }
+
+// cancelStream cancels the request of the stream.
+func (s *fimCompletionStream) cancelStream() bool {
+	s.cancel()
+	return true
+}
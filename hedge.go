@@ -0,0 +1,282 @@
+package deepseek
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+// HedgeLabel is the usage label set on the estimated usage recorded for a cancelled hedged attempt.
+// Its value is HedgeLabelCancelled, so reports can be grouped with GroupByLabel(HedgeLabel).
+const (
+	HedgeLabel          = "hedge"
+	HedgeLabelCancelled = "cancelled"
+)
+
+// HedgePolicy configures when a HedgedClient sends a second attempt of a call.
+type HedgePolicy struct {
+	// Percentile of the recent latencies after which the hedge is sent, between 0 and 1. Defaults to 0.95.
+	Percentile float64
+	// Number of recent latencies the percentile is computed over. Defaults to 100.
+	Window int
+	// Latencies needed before the percentile is used. InitialDelay is used until then. Defaults to 10.
+	MinSamples int
+	// Delay before the hedge while there are too few latencies. Defaults to 2 seconds.
+	InitialDelay time.Duration
+	// Bounds of the delay. Zero values disable the bound.
+	MinDelay, MaxDelay time.Duration
+
+	// MaxHedgeRatio caps the extra spend: at most this share of the calls is hedged, such as 0.1 for 10%.
+	// Zero means the default of 0.1; set Disabled to send no hedges. Hedges sent because the first attempt
+	// failed are not capped, as they add no spend to a successful attempt.
+	MaxHedgeRatio float64
+	// MaxHedgeBurst is the number of hedges that may be sent in a row before MaxHedgeRatio applies.
+	// Defaults to 10.
+	MaxHedgeBurst float64
+	// Disabled turns hedging off: every call is only sent to the first backend.
+	Disabled bool
+}
+
+// HedgedClient cuts tail latency by sending a second, identical attempt of a call that has not returned
+// within a percentile of the recent latencies. The first successful attempt wins and the other one is cancelled.
+// The first attempt goes to the first backend and the hedge to the second one, or to the first again if there
+// is only one. It is safe for concurrent use.
+//
+// Each attempt is a regular call of its backend's client, so it runs the client's middleware, budget guard and
+// usage ledger. The ledger of a cancelled attempt's client records the estimated prompt tokens of the request,
+// labelled with HedgeLabel, as the provider may bill it.
+type HedgedClient struct {
+	Policy HedgePolicy
+
+	primary, hedge *backendState
+
+	mu        sync.Mutex
+	latencies []time.Duration // Ring buffer of recent latencies.
+	next      int             // Index of the next latency to overwrite once the buffer is full.
+	credits   float64         // Hedges that may be sent, refilled by MaxHedgeRatio per call.
+	hedges    int
+}
+
+// NewHedgedClient creates a client hedging calls between the first two backends. Priority and Weight are ignored.
+func NewHedgedClient(policy HedgePolicy, backends ...Backend) (*HedgedClient, error) {
+	if len(backends) == 0 || len(backends) > 2 {
+		return nil, fmt.Errorf("one or two backends are required, got %d", len(backends))
+	}
+	if policy.Percentile < 0 || policy.Percentile > 1 {
+		return nil, fmt.Errorf("percentile must be between 0 and 1, got %v", policy.Percentile)
+	}
+	if policy.MaxHedgeRatio < 0 || policy.MaxHedgeBurst < 0 {
+		return nil, fmt.Errorf("hedge ratio and burst cannot be negative")
+	}
+	for _, b := range backends {
+		if b.Client == nil {
+			return nil, fmt.Errorf("backend %q has no client", b.Name)
+		}
+	}
+	h := &HedgedClient{Policy: policy, primary: &backendState{Backend: backends[0]}}
+	h.hedge = h.primary
+	if len(backends) == 2 {
+		h.hedge = &backendState{Backend: backends[1]}
+	}
+	h.credits = h.Policy.maxHedgeBurst()
+	return h, nil
+}
+
+// Delay returns how long a call currently waits before it is hedged.
+func (h *HedgedClient) Delay() time.Duration {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.delayLocked()
+}
+
+// Hedges returns the number of hedges sent so far.
+func (h *HedgedClient) Hedges() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.hedges
+}
+
+// hedgeAttempt is the outcome of one attempt of a hedged call.
+type hedgeAttempt struct {
+	ctx     context.Context // Context the attempt was sent with, carrying the key it used.
+	backend *backendState
+	resp    *ChatCompletionResponse
+	err     error
+	latency time.Duration
+}
+
+// CreateChatCompletion sends the request and hedges it once it is slower than the policy's percentile,
+// or at once if the first attempt fails before. The response's Backend field is set to the name of the
+// backend that answered. If both attempts fail, the errors are joined.
+func (h *HedgedClient) CreateChatCompletion(
+	ctx context.Context,
+	request *ChatCompletionRequest,
+) (*ChatCompletionResponse, error) {
+	if request == nil {
+		return nil, fmt.Errorf("request cannot be nil")
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	attempts := make(chan hedgeAttempt, 2)
+	send := func(b *backendState) {
+		r := *request
+		r.Model = b.model(request.Model)
+		attemptCtx := ctx
+		if b.Client.Keys != nil {
+			attemptCtx = b.Client.Keys.withUsedKey(ctx)
+		}
+		go func() {
+			start := time.Now()
+			resp, err := b.Client.CreateChatCompletion(attemptCtx, &r)
+			attempts <- hedgeAttempt{ctx: attemptCtx, backend: b, resp: resp, err: err, latency: time.Since(start)}
+		}()
+	}
+
+	delay := h.startCall()
+	send(h.primary)
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	pending, hedged := 1, false
+	var errs []error
+	for {
+		select {
+		case <-timer.C:
+			if !hedged && h.takeHedge(false) {
+				hedged = true
+				pending++
+				send(h.hedge)
+			}
+		case a := <-attempts:
+			pending--
+			if a.err == nil {
+				h.observe(a.latency)
+				cancel()
+				if pending > 0 {
+					go h.recordCancelled(attempts, request)
+				}
+				a.resp.Backend = a.backend.Name
+				return a.resp, nil
+			}
+			errs = append(errs, a.err)
+			if !hedged && ctx.Err() == nil && h.takeHedge(true) {
+				hedged = true
+				pending++
+				send(h.hedge)
+				continue
+			}
+			if pending == 0 {
+				return nil, errors.Join(errs...)
+			}
+		}
+	}
+}
+
+// recordCancelled waits for the losing attempt of a call and, if it was cancelled, records its estimated usage
+// with the attempt's context, so it is added to the key the attempt used. A losing attempt that completed
+// anyway has already recorded its real usage.
+func (h *HedgedClient) recordCancelled(attempts <-chan hedgeAttempt, request *ChatCompletionRequest) {
+	a := <-attempts
+	if a.err == nil || !errors.Is(a.err, context.Canceled) {
+		return
+	}
+	usage := Usage{PromptTokens: EstimateRequestTokens(request)}
+	usage.TotalTokens = usage.PromptTokens
+	ctx := ContextWithUsageLabels(a.ctx, map[string]string{HedgeLabel: HedgeLabelCancelled})
+	a.backend.Client.recordUsage(ctx, OperationChatCompletion, a.backend.model(request.Model), usage)
+}
+
+// startCall refills the hedge credits for a new call and returns the delay before it is hedged.
+func (h *HedgedClient) startCall() time.Duration {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.credits = min(h.credits+h.Policy.maxHedgeRatio(), h.Policy.maxHedgeBurst())
+	return h.delayLocked()
+}
+
+// takeHedge reports whether a hedge may be sent and uses up its credit. A hedge replacing a failed
+// attempt needs no credit.
+func (h *HedgedClient) takeHedge(failed bool) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.Policy.Disabled {
+		return false
+	}
+	if !failed {
+		if h.credits < 1 {
+			return false
+		}
+		h.credits--
+	}
+	h.hedges++
+	return true
+}
+
+// observe adds the latency of a successful attempt.
+func (h *HedgedClient) observe(latency time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(h.latencies) < h.Policy.window() {
+		h.latencies = append(h.latencies, latency)
+		return
+	}
+	h.latencies[h.next%len(h.latencies)] = latency
+	h.next++
+}
+
+// delayLocked returns the percentile of the recent latencies, within the policy's bounds. The caller must hold mu.
+func (h *HedgedClient) delayLocked() time.Duration {
+	p := h.Policy
+	delay := p.InitialDelay
+	if delay <= 0 {
+		delay = 2 * time.Second
+	}
+	minSamples := p.MinSamples
+	if minSamples <= 0 {
+		minSamples = 10
+	}
+	if len(h.latencies) >= minSamples {
+		sorted := slices.Clone(h.latencies)
+		slices.Sort(sorted)
+		percentile := p.Percentile
+		if percentile == 0 {
+			percentile = 0.95
+		}
+		delay = sorted[int(percentile*float64(len(sorted)-1))]
+	}
+	if p.MinDelay > 0 && delay < p.MinDelay {
+		delay = p.MinDelay
+	}
+	if p.MaxDelay > 0 && delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	return delay
+}
+
+// window returns the number of latencies kept.
+func (p HedgePolicy) window() int {
+	if p.Window <= 0 {
+		return 100
+	}
+	return p.Window
+}
+
+// maxHedgeRatio returns the share of calls that may be hedged.
+func (p HedgePolicy) maxHedgeRatio() float64 {
+	if p.MaxHedgeRatio <= 0 {
+		return 0.1
+	}
+	return p.MaxHedgeRatio
+}
+
+// maxHedgeBurst returns the number of hedges that may be sent in a row.
+func (p HedgePolicy) maxHedgeBurst() float64 {
+	if p.MaxHedgeBurst <= 0 {
+		return 10
+	}
+	return p.MaxHedgeBurst
+}
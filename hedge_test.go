@@ -0,0 +1,158 @@
+package deepseek_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cohesion-org/deepseek-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// slowBackend returns a server answering chat completions after delay, unless the request is cancelled first,
+// and the number of requests it received.
+func slowBackend(t *testing.T, delay time.Duration) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var calls atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		io.Copy(io.Discard, r.Body)
+		select {
+		case <-time.After(delay):
+			w.Write([]byte(retryChatResponse))
+		case <-r.Context().Done():
+		}
+	}))
+	t.Cleanup(ts.Close)
+	return ts, &calls
+}
+
+func TestHedgedClient(t *testing.T) {
+	slow, slowCalls := slowBackend(t, 5*time.Second)
+	fast, fastCalls := slowBackend(t, 0)
+	ledger := deepseek.NewUsageLedger()
+	primary, err := deepseek.NewClientWithOptions("token", deepseek.WithBaseURL(slow.URL+"/"), deepseek.WithUsageLedger(ledger))
+	require.NoError(t, err)
+	secondary, err := deepseek.NewClientWithOptions("token", deepseek.WithBaseURL(fast.URL+"/"))
+	require.NoError(t, err)
+
+	hc, err := deepseek.NewHedgedClient(deepseek.HedgePolicy{InitialDelay: 20 * time.Millisecond},
+		deepseek.Backend{Name: "primary", Client: primary},
+		deepseek.Backend{Name: "secondary", Client: secondary})
+	require.NoError(t, err)
+
+	start := time.Now()
+	resp, err := hc.CreateChatCompletion(context.Background(), chatRequest(deepseek.DeepSeekChat))
+	require.NoError(t, err)
+	assert.Equal(t, "secondary", resp.Backend)
+	assert.Less(t, time.Since(start), time.Second, "the hedge wins")
+	assert.Equal(t, int32(1), slowCalls.Load())
+	assert.Equal(t, int32(1), fastCalls.Load())
+	assert.Equal(t, 1, hc.Hedges())
+
+	require.Eventually(t, func() bool { return len(ledger.Records(deepseek.UsageFilter{})) == 1 },
+		time.Second, 10*time.Millisecond, "the cancelled attempt is recorded")
+	record := ledger.Records(deepseek.UsageFilter{})[0]
+	assert.Equal(t, map[string]string{deepseek.HedgeLabel: deepseek.HedgeLabelCancelled}, record.Labels)
+	assert.Positive(t, record.Usage.PromptTokens)
+	assert.Zero(t, record.Usage.CompletionTokens)
+}
+
+func TestHedgedClientPrimaryFailure(t *testing.T) {
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+	fast, fastCalls := slowBackend(t, 0)
+	primary, err := deepseek.NewClientWithOptions("token", deepseek.WithBaseURL(failing.URL+"/"))
+	require.NoError(t, err)
+	secondary, err := deepseek.NewClientWithOptions("token", deepseek.WithBaseURL(fast.URL+"/"))
+	require.NoError(t, err)
+	hc, err := deepseek.NewHedgedClient(deepseek.HedgePolicy{InitialDelay: 5 * time.Second},
+		deepseek.Backend{Name: "primary", Client: primary},
+		deepseek.Backend{Name: "secondary", Client: secondary})
+	require.NoError(t, err)
+
+	start := time.Now()
+	resp, err := hc.CreateChatCompletion(context.Background(), chatRequest(deepseek.DeepSeekChat))
+	require.NoError(t, err)
+	assert.Equal(t, "secondary", resp.Backend)
+	assert.Less(t, time.Since(start), time.Second, "the hedge is sent as soon as the primary fails")
+	assert.Equal(t, int32(1), fastCalls.Load())
+}
+
+func TestHedgedClientSpendCap(t *testing.T) {
+	slow, _ := slowBackend(t, 100*time.Millisecond)
+	client, err := deepseek.NewClientWithOptions("token", deepseek.WithBaseURL(slow.URL+"/"))
+	require.NoError(t, err)
+	hc, err := deepseek.NewHedgedClient(deepseek.HedgePolicy{
+		InitialDelay:  10 * time.Millisecond,
+		MaxHedgeRatio: 0.5,
+		MaxHedgeBurst: 1,
+	}, deepseek.Backend{Name: "only", Client: client})
+	require.NoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		_, err := hc.CreateChatCompletion(context.Background(), chatRequest(deepseek.DeepSeekChat))
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 2, hc.Hedges(), "one in two calls may be hedged")
+
+	hc, err = deepseek.NewHedgedClient(deepseek.HedgePolicy{InitialDelay: 10 * time.Millisecond, Disabled: true},
+		deepseek.Backend{Name: "only", Client: client})
+	require.NoError(t, err)
+	_, err = hc.CreateChatCompletion(context.Background(), chatRequest(deepseek.DeepSeekChat))
+	require.NoError(t, err)
+	assert.Zero(t, hc.Hedges(), "a disabled policy sends no hedge")
+}
+
+func TestHedgedClientKeyPool(t *testing.T) {
+	slow, _ := slowBackend(t, 5*time.Second)
+	fast, _ := slowBackend(t, 0)
+	pool, err := deepseek.NewKeyPool(deepseek.KeyRoundRobin, deepseek.APIKey{Name: "a", Key: "key-a"})
+	require.NoError(t, err)
+	primary, err := deepseek.NewClientWithOptions("token", deepseek.WithBaseURL(slow.URL+"/"), deepseek.WithKeyPool(pool))
+	require.NoError(t, err)
+	secondary, err := deepseek.NewClientWithOptions("token", deepseek.WithBaseURL(fast.URL+"/"))
+	require.NoError(t, err)
+	hc, err := deepseek.NewHedgedClient(deepseek.HedgePolicy{InitialDelay: 20 * time.Millisecond},
+		deepseek.Backend{Name: "primary", Client: primary},
+		deepseek.Backend{Name: "secondary", Client: secondary})
+	require.NoError(t, err)
+
+	_, err = hc.CreateChatCompletion(context.Background(), chatRequest(deepseek.DeepSeekChat))
+	require.NoError(t, err)
+	require.Eventually(t, func() bool { return pool.Stats()[0].Usage.PromptTokens > 0 },
+		time.Second, 10*time.Millisecond, "the cancelled attempt is added to the key it used")
+}
+
+func TestHedgedClientDelay(t *testing.T) {
+	fast, _ := slowBackend(t, 0)
+	client, err := deepseek.NewClientWithOptions("token", deepseek.WithBaseURL(fast.URL+"/"))
+	require.NoError(t, err)
+	hc, err := deepseek.NewHedgedClient(deepseek.HedgePolicy{
+		InitialDelay: time.Minute,
+		MinSamples:   3,
+		MinDelay:     50 * time.Millisecond,
+		MaxDelay:     10 * time.Second,
+	}, deepseek.Backend{Name: "only", Client: client})
+	require.NoError(t, err)
+	assert.Equal(t, 10*time.Second, hc.Delay(), "the initial delay is capped")
+
+	for i := 0; i < 3; i++ {
+		_, err := hc.CreateChatCompletion(context.Background(), chatRequest(deepseek.DeepSeekChat))
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 50*time.Millisecond, hc.Delay(), "the percentile of fast calls is raised to the minimum")
+	assert.Zero(t, hc.Hedges())
+
+	_, err = deepseek.NewHedgedClient(deepseek.HedgePolicy{Percentile: 2}, deepseek.Backend{Name: "only", Client: client})
+	require.Error(t, err)
+	_, err = deepseek.NewHedgedClient(deepseek.HedgePolicy{})
+	require.Error(t, err)
+}
@@ -1,7 +1,6 @@
 package deepseek
 
 import (
-	"bufio"
 	"context"
 	"encoding/base64"
 	"fmt"
@@ -52,6 +51,8 @@ type ChatCompletionRequestWithImage struct {
 	LogProbs         bool                             `json:"logprobs,omitempty"`          // Whether to return log probabilities of the most likely tokens (optional).
 	TopLogProbs      int                              `json:"top_logprobs,omitempty"`      // The number of top most likely tokens to return log probabilities for (optional).
 	JSONMode         bool                             `json:"json,omitempty"`              // [deepseek-go feature] Optional: Enable JSON mode. If you're using the JSON mode, please mention "json" anywhere in your prompt, and also include the JSON schema in the request.
+	EnableThinking   bool                             `json:"enable_thinking,omitempty"`   // Optional: Enable thinking (for qwen3 api)
+	ExtraBody        map[string]any                   `json:"-"`                           // Optional: Provider-specific fields merged into the request body
 }
 
 // StreamChatCompletionRequestWithImage represents the request body for a streaming chat completion API call with image support.
@@ -68,11 +69,15 @@ type StreamChatCompletionRequestWithImage struct {
 	ResponseFormat   *ResponseFormat                  `json:"response_format,omitempty"`   // Optional: Custom response format: just don't try, it breaks rn ;)
 	Stop             []string                         `json:"stop,omitempty"`              // Optional: Stop signals
 	Tools            []Tool                           `json:"tools,omitempty"`             // Optional: List of tools
+	ToolChoice       interface{}                      `json:"tool_choice,omitempty"`       // Optional: Controls which (if any) tool is called by the model
 	LogProbs         bool                             `json:"logprobs,omitempty"`          // Optional: Enable log probabilities
 	TopLogProbs      int                              `json:"top_logprobs,omitempty"`      // Optional: Number of top tokens with log probabilities, <= 20
+	JSONMode         bool                             `json:"json,omitempty"`              // [deepseek-go feature] Optional: Enable JSON mode
+	EnableThinking   bool                             `json:"enable_thinking,omitempty"`   // Optional: Enable thinking (for qwen3 api)
+	ExtraBody        map[string]any                   `json:"-"`                           // Optional: Provider-specific fields merged into the request body
 }
 
-// CreateChatCompletion sends a chat completion request and returns the generated response.
+// CreateChatCompletionWithImage sends a chat completion request with images and returns the generated response.
 func (c *Client) CreateChatCompletionWithImage(
 	ctx context.Context,
 	request *ChatCompletionRequestWithImage,
@@ -81,16 +86,93 @@ func (c *Client) CreateChatCompletionWithImage(
 		return nil, fmt.Errorf("request cannot be nil")
 	}
 
+	result, err := c.runMiddleware(ctx, &Call{Operation: OperationChatCompletionWithImage, ImageRequest: request},
+		func(ctx context.Context, call *Call) (*Result, error) {
+			if err := c.validateCall(call); err != nil {
+				return nil, err
+			}
+			if err := c.checkBudget(ctx, call); err != nil {
+				return nil, err
+			}
+			resp, err := c.createChatCompletionWithImage(ctx, call.ImageRequest)
+			if err != nil {
+				return nil, err
+			}
+			c.recordUsage(ctx, call.Operation, call.ImageRequest.Model, resp.Usage)
+			return &Result{ChatResponse: resp}, nil
+		})
+	if err != nil {
+		return nil, err
+	}
+	if result.ChatResponse == nil {
+		return nil, ErrUnexpectedResponseFormat
+	}
+	return result.ChatResponse, nil
+}
+
+// CreateChatCompletionStreamWithImage sends a chat completion request with images and stream = true and returns the delta
+func (c *Client) CreateChatCompletionStreamWithImage(
+	ctx context.Context,
+	request *StreamChatCompletionRequestWithImage,
+) (ChatCompletionStream, error) {
+	if request == nil {
+		return nil, fmt.Errorf("request cannot be nil")
+	}
+
+	result, err := c.runMiddleware(ctx, &Call{Operation: OperationChatCompletionStreamWithImage, ImageStreamRequest: request},
+		func(ctx context.Context, call *Call) (*Result, error) {
+			if err := c.validateCall(call); err != nil {
+				return nil, err
+			}
+			if err := c.checkBudget(ctx, call); err != nil {
+				return nil, err
+			}
+			stream, err := c.createChatCompletionStreamWithImage(ctx, call.ImageStreamRequest)
+			if err != nil {
+				return nil, err
+			}
+			if c.tracksUsage() {
+				model := call.ImageStreamRequest.Model
+				stream = &usageRecordingChatStream{ChatCompletionStream: stream, record: func(usage Usage) {
+					c.recordUsage(ctx, call.Operation, model, usage)
+				}}
+			}
+			return &Result{Stream: stream}, nil
+		})
+	if err != nil {
+		return nil, err
+	}
+	if result.Stream == nil {
+		return nil, ErrUnexpectedResponseFormat
+	}
+	return result.Stream, nil
+}
+
+// createChatCompletionWithImage sends a chat completion request with images without running the middleware chain.
+func (c *Client) createChatCompletionWithImage(
+	ctx context.Context,
+	request *ChatCompletionRequestWithImage,
+) (*ChatCompletionResponse, error) {
 	ctx, tcancel, err := getTimeoutContext(ctx, c.Timeout)
 	if err != nil {
 		return nil, err
 	}
 	defer tcancel()
 
+	ctx, release, err := c.waitLimiter(ctx, estimateImageRequestTokens(request.Messages, request.Tools))
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	body, err := c.requestBody(request.ToChatRequest(), request.ExtraBody)
+	if err != nil {
+		return nil, fmt.Errorf("error building request: %w", err)
+	}
 	req, err := utils.NewRequestBuilder(c.AuthToken).
 		SetBaseURL(c.BaseURL).
 		SetPath(c.Path).
-		SetBodyFromStruct(request).
+		SetBodyFromStruct(body).
 		Build(ctx)
 
 	if err != nil {
@@ -116,46 +198,72 @@ func (c *Client) CreateChatCompletionWithImage(
 	return updatedResp, err
 }
 
-// CreateChatCompletionStream sends a chat completion request with stream = true and returns the delta
-func (c *Client) CreateChatCompletionStreamWithImage(
+// createChatCompletionStreamWithImage opens a chat completion stream with images without running the middleware chain.
+func (c *Client) createChatCompletionStreamWithImage(
 	ctx context.Context,
 	request *StreamChatCompletionRequestWithImage,
 ) (ChatCompletionStream, error) {
-	if request == nil {
-		return nil, fmt.Errorf("request cannot be nil")
+	ctx, tcancel, err := getTimeoutContext(ctx, c.Timeout)
+	if err != nil {
+		return nil, fmt.Errorf("error getting timeout context: %w", err)
 	}
+	ctx, rcancel := context.WithCancel(ctx)
+	cancel := func() { rcancel(); tcancel() }
 
-	ctx, _, err := getTimeoutContext(ctx, c.Timeout)
+	ctx, release, err := c.waitLimiter(ctx, estimateImageRequestTokens(request.Messages, request.Tools))
 	if err != nil {
-		return nil, fmt.Errorf("error getting timeout context: %w", err)
+		cancel()
+		return nil, err
 	}
 
 	request.Stream = true
+	chat := request.ToChatRequest()
+	if c.tracksUsage() {
+		chat.StreamOptions = &StreamOptions{IncludeUsage: true}
+	}
+	body, err := c.requestBody(chat, request.ExtraBody)
+	if err != nil {
+		release()
+		cancel()
+		return nil, fmt.Errorf("error building request: %w", err)
+	}
 	req, err := utils.NewRequestBuilder(c.AuthToken).
 		SetBaseURL(c.BaseURL).
 		SetPath(c.Path).
-		SetBodyFromStruct(request).
+		SetBodyFromStruct(body).
 		BuildStream(ctx)
 
 	if err != nil {
+		release()
+		cancel()
 		return nil, fmt.Errorf("error building request: %w", err)
 	}
 
 	resp, err := HandleSendChatCompletionRequest(*c, req)
 	if err != nil {
+		release()
+		cancel()
 		return nil, fmt.Errorf("error sending request: %w", err)
 	}
 
 	if resp.StatusCode >= 400 {
+		release()
+		defer cancel()
 		return nil, HandleAPIError(resp)
 	}
 
-	ctx, cancel := context.WithCancel(ctx)
+	// The timeouts start with the first read, so retries, backoff and time before the stream is read do not count.
+	watchdog := newStreamWatchdog(rcancel, c.StreamFirstTokenTimeout, c.StreamIdleTimeout)
+	decoder := newSSEDecoder(resp.Body)
+	decoder.onLine = watchdog.lineReceived
+
 	stream := &chatCompletionStream{
-		ctx:    ctx,
-		cancel: cancel,
-		resp:   resp,
-		reader: bufio.NewReader(resp.Body),
+		ctx:      ctx,
+		cancel:   cancel,
+		resp:     resp,
+		decoder:  decoder,
+		release:  release,
+		watchdog: watchdog,
 	}
 	return stream, nil
 }
@@ -0,0 +1,277 @@
+package deepseek
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrNoAPIKeyAvailable is returned when every key of a KeyPool is benched.
+var ErrNoAPIKeyAvailable = errors.New("no API key available")
+
+// KeyStrategy selects the key of a KeyPool used for a request.
+type KeyStrategy int
+
+const (
+	// KeyRoundRobin uses the available keys in turn.
+	KeyRoundRobin KeyStrategy = iota
+	// KeyLeastUsed uses the available key with the fewest tokens used, then the fewest requests.
+	KeyLeastUsed
+)
+
+// APIKey is a key of a KeyPool.
+type APIKey struct {
+	Name string // Name the key is reported under, e.g. the project it belongs to. Defaults to the masked key.
+	Key  string
+}
+
+// KeyPool spreads the requests of a client over several API keys. A key answered with 429 Too Many Requests
+// or 402 Payment Required is benched for a while, and the requests and token usage of every key are tracked.
+// It is safe for concurrent use and may be shared by several clients.
+type KeyPool struct {
+	Strategy       KeyStrategy
+	RateLimitBench time.Duration // How long a key is benched after a 429. Retry-After takes precedence. Defaults to 1 minute.
+	BalanceBench   time.Duration // How long a key is benched after a 402. Defaults to 1 hour.
+
+	mu   sync.Mutex
+	keys []*pooledKey
+	next int // Round-robin position.
+}
+
+// pooledKey is a key with its usage and bench state.
+type pooledKey struct {
+	APIKey
+	requests     int
+	usage        Usage
+	benchedUntil time.Time
+	benchStatus  int
+}
+
+// KeyStats is the usage and state of a key, as returned by KeyPool.Stats.
+type KeyStats struct {
+	Name         string
+	Requests     int       // Requests sent with the key, including failed ones.
+	Usage        Usage     // Tokens used by the calls sent with the key.
+	BenchedUntil time.Time // Zero unless the key is benched.
+	BenchStatus  int       // HTTP status that benched the key.
+}
+
+// NewKeyPool creates a pool of keys used with the given strategy.
+func NewKeyPool(strategy KeyStrategy, keys ...APIKey) (*KeyPool, error) {
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("at least one API key is required")
+	}
+	p := &KeyPool{Strategy: strategy}
+	names := make(map[string]bool, len(keys))
+	for _, k := range keys {
+		if k.Key == "" {
+			return nil, fmt.Errorf("API key %q is empty", k.Name)
+		}
+		if k.Name == "" {
+			k.Name = maskKey(k.Key)
+		}
+		if names[k.Name] {
+			return nil, fmt.Errorf("API key names must be unique: %q", k.Name)
+		}
+		names[k.Name] = true
+		p.keys = append(p.keys, &pooledKey{APIKey: k})
+	}
+	return p, nil
+}
+
+// WithKeyPool sends every request of the client with a key of pool instead of the client's AuthToken.
+// The key is chosen for every attempt, so a retried request moves on from a benched key.
+func WithKeyPool(pool *KeyPool) Option {
+	return func(c *Client) error {
+		if pool == nil {
+			return fmt.Errorf("key pool cannot be nil")
+		}
+		c.Keys = pool
+		return nil
+	}
+}
+
+// maskKey returns the key with all but its last four characters hidden.
+func maskKey(key string) string {
+	if len(key) <= 4 {
+		return "****"
+	}
+	return "****" + key[len(key)-4:]
+}
+
+// Stats returns the usage and state of every key, in the order they were added.
+func (p *KeyPool) Stats() []KeyStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := time.Now()
+	stats := make([]KeyStats, len(p.keys))
+	for i, k := range p.keys {
+		stats[i] = KeyStats{Name: k.Name, Requests: k.requests, Usage: k.usage}
+		if k.benchedUntil.After(now) {
+			stats[i].BenchedUntil = k.benchedUntil
+			stats[i].BenchStatus = k.benchStatus
+		}
+	}
+	return stats
+}
+
+// GetBalance returns the balance of the key named name, sending the request with the settings of c.
+// A key benched for an insufficient balance is made available again if the balance is.
+func (p *KeyPool) GetBalance(ctx context.Context, c *Client, name string) (*BalanceResponse, error) {
+	key := p.lookup(name)
+	if key == nil {
+		return nil, fmt.Errorf("unknown API key %q", name)
+	}
+	kc := *c
+	kc.Keys = nil
+	kc.Credentials = nil
+	kc.AuthToken = key.Key
+	balance, err := GetBalance(&kc, ctx)
+	if err != nil {
+		return nil, err
+	}
+	if balance.IsAvailable {
+		p.mu.Lock()
+		if key.benchStatus == http.StatusPaymentRequired {
+			key.benchedUntil = time.Time{}
+		}
+		p.mu.Unlock()
+	}
+	return balance, nil
+}
+
+// lookup returns the key named name, or nil.
+func (p *KeyPool) lookup(name string) *pooledKey {
+	for _, k := range p.keys {
+		if k.Name == name {
+			return k
+		}
+	}
+	return nil
+}
+
+// pick returns the key to send the next request with.
+func (p *KeyPool) pick() (*pooledKey, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := time.Now()
+	var best *pooledKey
+	bestIndex := 0
+	for i := range p.keys {
+		index := (p.next + i) % len(p.keys)
+		k := p.keys[index]
+		if k.benchedUntil.After(now) {
+			continue
+		}
+		if best == nil || (p.Strategy == KeyLeastUsed && k.lessUsed(best)) {
+			best, bestIndex = k, index
+		}
+		if p.Strategy == KeyRoundRobin {
+			break
+		}
+	}
+	if best == nil {
+		return nil, ErrNoAPIKeyAvailable
+	}
+	// Start the next search after the chosen key, so ties of KeyLeastUsed rotate too.
+	p.next = (bestIndex + 1) % len(p.keys)
+	best.requests++
+	return best, nil
+}
+
+// lessUsed reports whether k has used fewer tokens than other, or as many in fewer requests.
+func (k *pooledKey) lessUsed(other *pooledKey) bool {
+	if k.usage.TotalTokens != other.usage.TotalTokens {
+		return k.usage.TotalTokens < other.usage.TotalTokens
+	}
+	return k.requests < other.requests
+}
+
+// report benches k if resp shows it is rate limited or out of balance.
+func (p *KeyPool) report(k *pooledKey, resp *http.Response) {
+	if resp == nil {
+		return
+	}
+	var bench time.Duration
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests:
+		bench = p.RateLimitBench
+		if bench <= 0 {
+			bench = time.Minute
+		}
+		if retryAfter, ok := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()); ok {
+			bench = retryAfter
+		}
+	case http.StatusPaymentRequired:
+		bench = p.BalanceBench
+		if bench <= 0 {
+			bench = time.Hour
+		}
+	default:
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	k.benchedUntil = time.Now().Add(bench)
+	k.benchStatus = resp.StatusCode
+}
+
+// usedKeyKey is the context key of the usedKey of a call.
+type usedKeyKey struct{}
+
+// usedKey holds the key the last request of a call was sent with, so the call's usage is added to it.
+type usedKey struct {
+	pool *KeyPool
+	key  atomic.Pointer[pooledKey]
+}
+
+// withUsedKey returns ctx carrying a usedKey of the pool, unless it already carries one.
+func (p *KeyPool) withUsedKey(ctx context.Context) context.Context {
+	if used, ok := ctx.Value(usedKeyKey{}).(*usedKey); ok && used.pool == p {
+		return ctx
+	}
+	return context.WithValue(ctx, usedKeyKey{}, &usedKey{pool: p})
+}
+
+// addUsage adds the usage of a call to the key its request was sent with.
+func (p *KeyPool) addUsage(ctx context.Context, usage Usage) {
+	used, _ := ctx.Value(usedKeyKey{}).(*usedKey)
+	if used == nil || used.pool != p {
+		return
+	}
+	k := used.key.Load()
+	if k == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	k.usage = addUsage(k.usage, usage)
+}
+
+// keyPoolDoer sends every request with a key of the client's pool.
+type keyPoolDoer struct {
+	HTTPDoer
+	client *Client
+}
+
+// Do picks a key, sets it on req and sends it. The client's extra headers are set again afterwards,
+// so they still take precedence over the key.
+func (d *keyPoolDoer) Do(req *http.Request) (*http.Response, error) {
+	pool := d.client.Keys
+	k, err := pool.pick()
+	if err != nil {
+		return nil, err
+	}
+	if used, ok := req.Context().Value(usedKeyKey{}).(*usedKey); ok && used.pool == pool {
+		used.key.Store(k)
+	}
+	d.client.setAuthToken(req, k.Key)
+	d.client.setExtraHeaders(req)
+	resp, err := d.HTTPDoer.Do(req)
+	pool.report(k, resp)
+	return resp, err
+}
@@ -0,0 +1,189 @@
+package deepseek_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cohesion-org/deepseek-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// keyServer returns a server answering chat completions with 10 tokens of usage, or with the status set for
+// the key of the request, and the keys it received.
+func keyServer(t *testing.T, statuses map[string]int) (*httptest.Server, func() []string) {
+	t.Helper()
+	var mu sync.Mutex
+	var keys []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		mu.Lock()
+		keys = append(keys, key)
+		mu.Unlock()
+		if status := statuses[key]; status != 0 {
+			w.WriteHeader(status)
+			w.Write([]byte(`{"code":1,"message":"failed"}`))
+			return
+		}
+		w.Write([]byte(`{"id":"1","object":"chat.completion","model":"deepseek-chat","choices":[{"index":0,` +
+			`"message":{"role":"assistant","content":"hi"},"finish_reason":"stop"}],` +
+			`"usage":{"prompt_tokens":6,"completion_tokens":4,"total_tokens":10}}`))
+	}))
+	t.Cleanup(ts.Close)
+	return ts, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), keys...)
+	}
+}
+
+func TestKeyPoolRoundRobin(t *testing.T) {
+	ts, received := keyServer(t, nil)
+	pool, err := deepseek.NewKeyPool(deepseek.KeyRoundRobin,
+		deepseek.APIKey{Name: "search", Key: "key-a"},
+		deepseek.APIKey{Name: "chat", Key: "key-b"},
+		deepseek.APIKey{Key: "key-c"})
+	require.NoError(t, err)
+	client, err := deepseek.NewClientWithOptions("token", deepseek.WithBaseURL(ts.URL+"/"), deepseek.WithKeyPool(pool))
+	require.NoError(t, err)
+
+	for i := 0; i < 4; i++ {
+		_, err := client.CreateChatCompletion(context.Background(), chatRequest(deepseek.DeepSeekChat))
+		require.NoError(t, err)
+	}
+	assert.Equal(t, []string{"key-a", "key-b", "key-c", "key-a"}, received())
+
+	stats := pool.Stats()
+	require.Len(t, stats, 3)
+	assert.Equal(t, "search", stats[0].Name)
+	assert.Equal(t, "****ey-c", stats[2].Name, "unnamed keys are masked")
+	assert.Equal(t, 2, stats[0].Requests)
+	assert.Equal(t, 20, stats[0].Usage.TotalTokens)
+	assert.Equal(t, 10, stats[1].Usage.TotalTokens)
+
+	stream, err := client.CreateChatCompletionStream(context.Background(), &deepseek.StreamChatCompletionRequest{
+		Model:    deepseek.DeepSeekChat,
+		Messages: []deepseek.ChatCompletionMessage{{Role: deepseek.ChatMessageRoleUser, Content: "hi"}},
+	})
+	require.NoError(t, err)
+	stream.Close()
+	assert.Equal(t, "key-b", received()[4], "streams use the pool too")
+}
+
+func TestKeyPoolBench(t *testing.T) {
+	ts, received := keyServer(t, map[string]int{"key-a": http.StatusTooManyRequests, "key-b": http.StatusPaymentRequired})
+	pool, err := deepseek.NewKeyPool(deepseek.KeyRoundRobin,
+		deepseek.APIKey{Name: "a", Key: "key-a"},
+		deepseek.APIKey{Name: "b", Key: "key-b"},
+		deepseek.APIKey{Name: "c", Key: "key-c"})
+	require.NoError(t, err)
+	policy := deepseek.DefaultRetryPolicy()
+	policy.InitialBackoff = time.Millisecond
+	policy.RetryableStatusCodes = append(policy.RetryableStatusCodes, http.StatusPaymentRequired)
+	client, err := deepseek.NewClientWithOptions("", deepseek.WithBaseURL(ts.URL+"/"),
+		deepseek.WithKeyPool(pool), deepseek.WithRetryPolicy(policy))
+	require.NoError(t, err, "the token may be empty with a key pool")
+
+	_, err = client.CreateChatCompletion(context.Background(), chatRequest(deepseek.DeepSeekChat))
+	require.NoError(t, err, "retries move on from benched keys")
+	assert.Equal(t, []string{"key-a", "key-b", "key-c"}, received())
+
+	stats := pool.Stats()
+	assert.Equal(t, http.StatusTooManyRequests, stats[0].BenchStatus)
+	assert.WithinDuration(t, time.Now().Add(time.Minute), stats[0].BenchedUntil, 5*time.Second)
+	assert.Equal(t, http.StatusPaymentRequired, stats[1].BenchStatus)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), stats[1].BenchedUntil, 5*time.Second)
+	assert.Equal(t, 10, stats[2].Usage.TotalTokens)
+	assert.Zero(t, stats[0].Usage.TotalTokens)
+
+	_, err = client.CreateChatCompletion(context.Background(), chatRequest(deepseek.DeepSeekChat))
+	require.NoError(t, err)
+	assert.Equal(t, "key-c", received()[3], "benched keys are skipped")
+
+	single, err := deepseek.NewKeyPool(deepseek.KeyRoundRobin, deepseek.APIKey{Key: "key-a"})
+	require.NoError(t, err)
+	client, err = deepseek.NewClientWithOptions("", deepseek.WithBaseURL(ts.URL+"/"), deepseek.WithKeyPool(single))
+	require.NoError(t, err)
+	_, err = client.CreateChatCompletion(context.Background(), chatRequest(deepseek.DeepSeekChat))
+	require.Error(t, err)
+	_, err = client.CreateChatCompletion(context.Background(), chatRequest(deepseek.DeepSeekChat))
+	require.ErrorIs(t, err, deepseek.ErrNoAPIKeyAvailable)
+
+	retry := deepseek.DefaultRetryPolicy()
+	retry.InitialBackoff = 10 * time.Second
+	client, err = deepseek.NewClientWithOptions("", deepseek.WithBaseURL(ts.URL+"/"),
+		deepseek.WithKeyPool(single), deepseek.WithRetryPolicy(retry))
+	require.NoError(t, err)
+	start := time.Now()
+	_, err = client.CreateChatCompletion(context.Background(), chatRequest(deepseek.DeepSeekChat))
+	require.ErrorIs(t, err, deepseek.ErrNoAPIKeyAvailable)
+	assert.Less(t, time.Since(start), time.Second, "a pool without available keys is not retried")
+}
+
+func TestKeyPoolLeastUsed(t *testing.T) {
+	ts, received := keyServer(t, nil)
+	pool, err := deepseek.NewKeyPool(deepseek.KeyLeastUsed, deepseek.APIKey{Key: "key-a"}, deepseek.APIKey{Key: "key-b"})
+	require.NoError(t, err)
+	client, err := deepseek.NewClientWithOptions("token", deepseek.WithBaseURL(ts.URL+"/"), deepseek.WithKeyPool(pool))
+	require.NoError(t, err)
+
+	for i := 0; i < 4; i++ {
+		_, err := client.CreateChatCompletion(context.Background(), chatRequest(deepseek.DeepSeekChat))
+		require.NoError(t, err)
+	}
+	assert.Equal(t, []string{"key-a", "key-b", "key-a", "key-b"}, received())
+
+	_, err = deepseek.NewKeyPool(deepseek.KeyLeastUsed)
+	require.Error(t, err)
+	_, err = deepseek.NewKeyPool(deepseek.KeyLeastUsed, deepseek.APIKey{Name: "a", Key: "1"}, deepseek.APIKey{Name: "a", Key: "2"})
+	require.Error(t, err)
+	_, err = deepseek.NewKeyPool(deepseek.KeyLeastUsed, deepseek.APIKey{Name: "a"})
+	require.Error(t, err)
+}
+
+// balanceDoer answers every request with an available balance and records the authorization header.
+type balanceDoer struct {
+	authorization string
+}
+
+func (d *balanceDoer) Do(req *http.Request) (*http.Response, error) {
+	d.authorization = req.Header.Get("Authorization")
+	body := `{"is_available":true,"balance_infos":[{"currency":"USD","total_balance":"5.00"}]}`
+	return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(body)), Header: http.Header{}}, nil
+}
+
+func TestKeyPoolGetBalance(t *testing.T) {
+	ts, _ := keyServer(t, map[string]int{"key-b": http.StatusPaymentRequired})
+	pool, err := deepseek.NewKeyPool(deepseek.KeyRoundRobin, deepseek.APIKey{Name: "a", Key: "key-a"}, deepseek.APIKey{Name: "b", Key: "key-b"})
+	require.NoError(t, err)
+	client, err := deepseek.NewClientWithOptions("token", deepseek.WithBaseURL(ts.URL+"/"), deepseek.WithKeyPool(pool))
+	require.NoError(t, err)
+	for i := 0; i < 2; i++ {
+		_, _ = client.CreateChatCompletion(context.Background(), chatRequest(deepseek.DeepSeekChat))
+	}
+	require.Equal(t, http.StatusPaymentRequired, pool.Stats()[1].BenchStatus)
+
+	doer := &balanceDoer{}
+	client.HTTPClient = doer
+	balance, err := pool.GetBalance(context.Background(), client, "b")
+	require.NoError(t, err)
+	assert.Equal(t, "5.00", balance.BalanceInfos[0].TotalBalance)
+	assert.Equal(t, "Bearer key-b", doer.authorization)
+	assert.True(t, pool.Stats()[1].BenchedUntil.IsZero(), "a topped up key is available again")
+
+	_, err = pool.GetBalance(context.Background(), client, "unknown")
+	require.Error(t, err)
+
+	client, err = deepseek.NewClientWithOptions("", deepseek.WithHTTPClient(doer),
+		deepseek.WithKeyPool(pool), deepseek.WithCredentialProvider(deepseek.StaticCredential("credential-key")))
+	require.NoError(t, err)
+	_, err = pool.GetBalance(context.Background(), client, "a")
+	require.NoError(t, err)
+	assert.Equal(t, "Bearer key-a", doer.authorization, "the pool key wins over the credential provider")
+}
@@ -0,0 +1,247 @@
+package deepseek
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Limiter throttles requests sent by a Client. It limits requests per second, estimated prompt tokens
+// per minute and the number of requests in flight. A single Limiter is safe for concurrent use.
+type Limiter struct {
+	mu       sync.Mutex
+	requests *tokenBucket  // Requests per second, nil if unlimited.
+	tokens   *tokenBucket  // Estimated prompt tokens per minute, nil if unlimited.
+	slots    chan struct{} // Semaphore for in-flight requests, nil if unlimited.
+}
+
+// tokenBucket is a token bucket that allows its balance to go negative so callers can reserve capacity ahead of time.
+type tokenBucket struct {
+	rate   float64 // Tokens added per second.
+	burst  float64 // Maximum number of tokens held.
+	tokens float64 // Current balance.
+	last   time.Time
+}
+
+// newTokenBucket creates a full bucket.
+func newTokenBucket(rate, burst float64) *tokenBucket {
+	return &tokenBucket{rate: rate, burst: burst, tokens: burst, last: time.Now()}
+}
+
+// reserve takes n tokens from the bucket and returns how long the caller has to wait before using them,
+// and the number of tokens actually taken.
+func (b *tokenBucket) reserve(now time.Time, n float64) (time.Duration, float64) {
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.last = now
+
+	// A single request larger than the burst would never fit, so it waits for at most a full bucket.
+	if n > b.burst {
+		n = b.burst
+	}
+	b.tokens -= n
+	if b.tokens >= 0 {
+		return 0, n
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second)), n
+}
+
+// refund gives back n tokens reserved by a caller that gave up before using them.
+func (b *tokenBucket) refund(n float64) {
+	b.tokens += n
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+}
+
+// NewLimiter creates a Limiter. Zero values disable the corresponding limit.
+func NewLimiter(requestsPerSecond float64, tokensPerMinute int, maxInFlight int) *Limiter {
+	l := &Limiter{}
+	l.setRequestsPerSecond(requestsPerSecond)
+	l.setTokensPerMinute(tokensPerMinute)
+	l.setMaxInFlight(maxInFlight)
+	return l
+}
+
+func (l *Limiter) setRequestsPerSecond(rps float64) {
+	if rps <= 0 {
+		l.requests = nil
+		return
+	}
+	burst := rps
+	if burst < 1 {
+		burst = 1
+	}
+	l.requests = newTokenBucket(rps, burst)
+}
+
+func (l *Limiter) setTokensPerMinute(tpm int) {
+	if tpm <= 0 {
+		l.tokens = nil
+		return
+	}
+	l.tokens = newTokenBucket(float64(tpm)/60, float64(tpm))
+}
+
+func (l *Limiter) setMaxInFlight(n int) {
+	if n <= 0 {
+		l.slots = nil
+		return
+	}
+	l.slots = make(chan struct{}, n)
+}
+
+// Wait blocks until a request with the given estimated token count may be sent, or ctx is done.
+// On success it returns a release function that frees the in-flight slot. Release is safe to call more than once.
+func (l *Limiter) Wait(ctx context.Context, tokens int) (func(), error) {
+	noop := func() {}
+	if l == nil {
+		return noop, nil
+	}
+	refund, err := l.waitRate(ctx, tokens)
+	if err != nil {
+		return noop, err
+	}
+
+	l.mu.Lock()
+	slots := l.slots
+	l.mu.Unlock()
+	if slots == nil {
+		return noop, nil
+	}
+	select {
+	case slots <- struct{}{}:
+	case <-ctx.Done():
+		refund()
+		return noop, fmt.Errorf("rate limiter: %w", ctx.Err())
+	}
+	var once sync.Once
+	return func() { once.Do(func() { <-slots }) }, nil
+}
+
+// waitRate blocks until a request with the given estimated token count fits the request and token rates,
+// or ctx is done. It does not take an in-flight slot. On success it returns a function that refunds the
+// reservation, for a caller that gives up before sending.
+func (l *Limiter) waitRate(ctx context.Context, tokens int) (func(), error) {
+	if l == nil {
+		return func() {}, nil
+	}
+
+	l.mu.Lock()
+	now := time.Now()
+	var wait time.Duration
+	var reservedRequests, reservedTokens float64
+	requests, tokenLimit := l.requests, l.tokens
+	if requests != nil {
+		wait, reservedRequests = requests.reserve(now, 1)
+	}
+	if tokenLimit != nil && tokens > 0 {
+		var d time.Duration
+		if d, reservedTokens = tokenLimit.reserve(now, float64(tokens)); d > wait {
+			wait = d
+		}
+	}
+	l.mu.Unlock()
+
+	refund := func() {
+		l.mu.Lock()
+		if requests != nil {
+			requests.refund(reservedRequests)
+		}
+		if tokenLimit != nil {
+			tokenLimit.refund(reservedTokens)
+		}
+		l.mu.Unlock()
+	}
+	if err := sleepContext(ctx, wait); err != nil {
+		refund()
+		return nil, fmt.Errorf("rate limiter: %w", err)
+	}
+	return refund, nil
+}
+
+// limiterTokensKey is the context key of the estimated token count of a call's requests.
+type limiterTokensKey struct{}
+
+// waitLimiter waits for the client's limiter before the first attempt of a call. The returned context
+// carries the estimated token count, so doWithRetry takes a new rate reservation for every retry.
+// The in-flight slot is held across retries until release is called.
+func (c *Client) waitLimiter(ctx context.Context, tokens int) (context.Context, func(), error) {
+	release, err := c.Limiter.Wait(ctx, tokens)
+	if err != nil {
+		return ctx, release, err
+	}
+	return context.WithValue(ctx, limiterTokensKey{}, tokens), release, nil
+}
+
+// limiter returns the client's limiter, creating it on first use by an Option.
+func (c *Client) limiter() *Limiter {
+	if c.Limiter == nil {
+		c.Limiter = &Limiter{}
+	}
+	return c.Limiter
+}
+
+// WithRequestsPerSecond limits how many requests per second the client sends.
+func WithRequestsPerSecond(rps float64) Option {
+	return func(c *Client) error {
+		if rps < 0 {
+			return fmt.Errorf("requests per second must not be negative")
+		}
+		c.limiter().setRequestsPerSecond(rps)
+		return nil
+	}
+}
+
+// WithTokensPerMinute limits how many prompt tokens per minute the client sends.
+// Tokens are estimated with EstimateTokensFromMessages before the request is sent.
+func WithTokensPerMinute(tpm int) Option {
+	return func(c *Client) error {
+		if tpm < 0 {
+			return fmt.Errorf("tokens per minute must not be negative")
+		}
+		c.limiter().setTokensPerMinute(tpm)
+		return nil
+	}
+}
+
+// WithMaxInFlight limits how many requests the client has in flight at once.
+// Streams hold their slot until they are closed.
+func WithMaxInFlight(n int) Option {
+	return func(c *Client) error {
+		if n < 0 {
+			return fmt.Errorf("max in-flight requests must not be negative")
+		}
+		c.limiter().setMaxInFlight(n)
+		return nil
+	}
+}
+
+// WithLimiter sets a Limiter for the client. The same Limiter can be shared between clients.
+func WithLimiter(l *Limiter) Option {
+	return func(c *Client) error {
+		c.Limiter = l
+		return nil
+	}
+}
+
+// estimateImageRequestTokens estimates the prompt tokens of an image request from its text content.
+func estimateImageRequestTokens(messages []ChatCompletionMessageWithImage, tools []Tool) int {
+	request := &ChatCompletionRequest{Tools: tools}
+	for _, msg := range messages {
+		switch content := msg.Content.(type) {
+		case string:
+			request.Messages = append(request.Messages, ChatCompletionMessage{Role: msg.Role, Content: content})
+		case []ContentItem:
+			for _, item := range content {
+				if item.Type == "text" {
+					request.Messages = append(request.Messages, ChatCompletionMessage{Role: msg.Role, Content: item.Text})
+				}
+			}
+		}
+	}
+	return EstimateTokensFromMessages(request).EstimatedTokens
+}
@@ -0,0 +1,195 @@
+package deepseek_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cohesion-org/deepseek-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLimiterWait(t *testing.T) {
+	t.Run("nil limiter never blocks", func(t *testing.T) {
+		var l *deepseek.Limiter
+		release, err := l.Wait(context.Background(), 1000)
+		require.NoError(t, err)
+		release()
+	})
+
+	t.Run("requests per second", func(t *testing.T) {
+		l := deepseek.NewLimiter(10, 0, 0)
+		start := time.Now()
+		for i := 0; i < 15; i++ {
+			release, err := l.Wait(context.Background(), 0)
+			require.NoError(t, err)
+			release()
+		}
+		// The first 10 requests use the burst, the remaining 5 need ~500ms.
+		assert.GreaterOrEqual(t, time.Since(start), 400*time.Millisecond)
+	})
+
+	t.Run("tokens per minute respects context", func(t *testing.T) {
+		l := deepseek.NewLimiter(0, 60, 0)
+		release, err := l.Wait(context.Background(), 60)
+		require.NoError(t, err)
+		release()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		_, err = l.Wait(ctx, 30)
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+
+	t.Run("cancelled waits refund their reservation", func(t *testing.T) {
+		l := deepseek.NewLimiter(1, 0, 0)
+		release, err := l.Wait(context.Background(), 0)
+		require.NoError(t, err)
+		release()
+
+		for i := 0; i < 5; i++ {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+			_, err := l.Wait(ctx, 0)
+			cancel()
+			require.ErrorIs(t, err, context.DeadlineExceeded)
+		}
+
+		// Without refunds the five cancelled callers would hold the next five seconds.
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		_, err = l.Wait(ctx, 0)
+		require.NoError(t, err)
+	})
+
+	t.Run("max in flight", func(t *testing.T) {
+		l := deepseek.NewLimiter(0, 0, 1)
+		release, err := l.Wait(context.Background(), 0)
+		require.NoError(t, err)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		_, err = l.Wait(ctx, 0)
+		require.Error(t, err)
+
+		release()
+		release() // releasing twice must not free a second slot
+		release2, err := l.Wait(context.Background(), 0)
+		require.NoError(t, err)
+		defer release2()
+	})
+}
+
+func TestClientMaxInFlight(t *testing.T) {
+	var current, peak int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&current, 1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		atomic.AddInt32(&current, -1)
+		w.Write([]byte(retryChatResponse))
+	}))
+	defer ts.Close()
+
+	client, err := deepseek.NewClientWithOptions("token",
+		deepseek.WithBaseURL(ts.URL+"/"),
+		deepseek.WithMaxInFlight(2),
+	)
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 6; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := client.CreateChatCompletion(context.Background(), &deepseek.ChatCompletionRequest{
+				Messages: []deepseek.ChatCompletionMessage{{Role: deepseek.ChatMessageRoleUser, Content: "hi"}},
+			})
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+	assert.LessOrEqual(t, atomic.LoadInt32(&peak), int32(2))
+}
+
+func TestStreamHoldsSlotUntilClose(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("data: [DONE]\n\n"))
+	}))
+	defer ts.Close()
+
+	client, err := deepseek.NewClientWithOptions("token",
+		deepseek.WithBaseURL(ts.URL+"/"),
+		deepseek.WithMaxInFlight(1),
+	)
+	require.NoError(t, err)
+
+	stream, err := client.CreateChatCompletionStream(context.Background(), &deepseek.StreamChatCompletionRequest{})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err = client.CreateChatCompletionStream(ctx, &deepseek.StreamChatCompletionRequest{})
+	require.Error(t, err)
+
+	require.NoError(t, stream.Close())
+	stream, err = client.CreateChatCompletionStream(context.Background(), &deepseek.StreamChatCompletionRequest{})
+	require.NoError(t, err)
+	require.NoError(t, stream.Close())
+}
+
+func TestLimiterRetries(t *testing.T) {
+	var calls atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Write([]byte(retryChatResponse))
+	}))
+	defer ts.Close()
+
+	request := chatRequest(deepseek.DeepSeekChat)
+	request.Messages[0].Content = strings.Repeat("hello world ", 50)
+	tokens := deepseek.EstimateTokensFromMessages(request).EstimatedTokens
+	require.Greater(t, tokens, 50)
+
+	limiter := deepseek.NewLimiter(0, 600, 0)
+	policy := deepseek.DefaultRetryPolicy()
+	policy.InitialBackoff = time.Millisecond
+	client, err := deepseek.NewClientWithOptions("token", deepseek.WithBaseURL(ts.URL+"/"),
+		deepseek.WithLimiter(limiter), deepseek.WithRetryPolicy(policy))
+	require.NoError(t, err)
+	_, err = client.CreateChatCompletion(context.Background(), request)
+	require.NoError(t, err)
+	require.Equal(t, int32(3), calls.Load())
+
+	// Every attempt reserved the tokens of the request, so less than two more requests fit the minute.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = limiter.Wait(ctx, 600-2*tokens-10)
+	require.ErrorIs(t, err, context.DeadlineExceeded, "retries take a reservation too")
+}
+
+func TestLimiterOptions(t *testing.T) {
+	_, err := deepseek.NewClientWithOptions("token", deepseek.WithRequestsPerSecond(-1))
+	require.Error(t, err)
+
+	client, err := deepseek.NewClientWithOptions("token",
+		deepseek.WithRequestsPerSecond(5),
+		deepseek.WithTokensPerMinute(1000),
+		deepseek.WithMaxInFlight(3),
+	)
+	require.NoError(t, err)
+	require.NotNil(t, client.Limiter)
+}
@@ -0,0 +1,385 @@
+package deepseek
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// LogConfig configures the structured events a client logs. See WithLogger.
+//
+// Calls are logged when they start (debug), finish (info) and fail (error), with their operation, model,
+// latency and token usage. Streams are logged when they are opened, finished, failed or closed early.
+// Every HTTP attempt is logged at debug level with its status and latency, and retries at warn level.
+//
+// Request and response bodies, stream chunks and request headers are only added when the logger is enabled
+// for BodyLevel. The Authorization header and the other API key headers are always redacted.
+type LogConfig struct {
+	Logger *slog.Logger
+
+	// BodyLevel is the level the logger must be enabled for to log bodies. WithLogger sets it to slog.LevelDebug.
+	BodyLevel slog.Level
+
+	// RedactContent, if set, replaces the message contents, prompts and tool call arguments of the logged bodies.
+	// RedactedContent hides them entirely.
+	RedactContent func(content string) string
+}
+
+// WithLogger logs the requests of the client to logger. Bodies are logged at debug level.
+func WithLogger(logger *slog.Logger) Option {
+	return func(c *Client) error {
+		if logger == nil {
+			return fmt.Errorf("logger cannot be nil")
+		}
+		if c.Log == nil {
+			c.Log = &LogConfig{BodyLevel: slog.LevelDebug}
+		}
+		c.Log.Logger = logger
+		return nil
+	}
+}
+
+// WithLogBodyLevel sets the level the client's logger must be enabled for to log bodies.
+// Use a level above slog.LevelError to never log them.
+func WithLogBodyLevel(level slog.Level) Option {
+	return func(c *Client) error {
+		if c.Log == nil {
+			return fmt.Errorf("WithLogger must be applied before WithLogBodyLevel")
+		}
+		c.Log.BodyLevel = level
+		return nil
+	}
+}
+
+// WithContentRedactor replaces the message contents of the bodies logged by the client with the result of redact.
+func WithContentRedactor(redact func(content string) string) Option {
+	return func(c *Client) error {
+		if redact == nil {
+			return fmt.Errorf("content redactor cannot be nil")
+		}
+		if c.Log == nil {
+			return fmt.Errorf("WithLogger must be applied before WithContentRedactor")
+		}
+		c.Log.RedactContent = redact
+		return nil
+	}
+}
+
+// RedactedContent is a content redactor keeping only the length of the content.
+func RedactedContent(content string) string {
+	return fmt.Sprintf("[redacted %d bytes]", len(content))
+}
+
+// redactedFields are the body fields replaced by LogConfig.RedactContent.
+var redactedFields = map[string]bool{
+	"content":           true,
+	"reasoning_content": true,
+	"reasoning":         true,
+	"text":              true,
+	"prompt":            true,
+	"suffix":            true,
+	"arguments":         true,
+	"token":             true,
+}
+
+// redactedHeaders are the request headers that are never logged.
+var redactedHeaders = []string{"Authorization", "Proxy-Authorization", "Api-Key", "X-Api-Key"}
+
+// enabled reports whether events of the level are logged.
+func (l *LogConfig) enabled(ctx context.Context, level slog.Level) bool {
+	return l != nil && l.Logger != nil && l.Logger.Enabled(ctx, level)
+}
+
+// log logs an event. body is added if bodies are logged.
+func (l *LogConfig) log(ctx context.Context, level slog.Level, msg string, attrs []slog.Attr, body any) {
+	if !l.enabled(ctx, level) {
+		return
+	}
+	if body != nil && l.enabled(ctx, l.BodyLevel) {
+		attrs = append(attrs, slog.String("body", l.redactBody(body)))
+	}
+	l.Logger.LogAttrs(ctx, level, msg, attrs...)
+}
+
+// redactBody marshals body and applies RedactContent to its content fields.
+func (l *LogConfig) redactBody(body any) string {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Sprintf("<unmarshalable body: %v>", err)
+	}
+	if l.RedactContent == nil {
+		return string(data)
+	}
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		return fmt.Sprintf("<unmarshalable body: %v>", err)
+	}
+	data, _ = json.Marshal(l.redactValue(v, false))
+	return string(data)
+}
+
+// redactValue applies RedactContent to the strings of v that are, or are within, a redacted field.
+func (l *LogConfig) redactValue(v any, redact bool) any {
+	switch v := v.(type) {
+	case string:
+		if redact {
+			return l.RedactContent(v)
+		}
+	case []any:
+		for i, item := range v {
+			v[i] = l.redactValue(item, redact)
+		}
+	case map[string]any:
+		for key, value := range v {
+			v[key] = l.redactValue(value, redact || redactedFields[key])
+		}
+	}
+	return v
+}
+
+// redactHeaders returns the headers of a request with the API key headers redacted.
+func (c *Client) redactHeaders(header http.Header) map[string]string {
+	out := make(map[string]string, len(header))
+	for key := range header {
+		out[key] = header.Get(key)
+	}
+	redacted := redactedHeaders
+	if c.Profile != nil && c.Profile.AuthHeader != "" {
+		redacted = append(redacted[:len(redacted):len(redacted)], c.Profile.AuthHeader)
+	}
+	for _, key := range redacted {
+		key = http.CanonicalHeaderKey(key)
+		if _, ok := out[key]; ok {
+			out[key] = "[redacted]"
+		}
+	}
+	return out
+}
+
+// usageAttr returns the token usage of a call as a log attribute.
+func usageAttr(usage Usage) slog.Attr {
+	return slog.Group("usage",
+		slog.Int("prompt_tokens", usage.PromptTokens),
+		slog.Int("completion_tokens", usage.CompletionTokens),
+		slog.Int("total_tokens", usage.TotalTokens),
+		slog.Int("cache_hit_tokens", usage.PromptCacheHitTokens),
+		slog.Int("reasoning_tokens", usage.CompletionTokensDetails.ReasoningTokens))
+}
+
+// callModel returns the model and the request of a call.
+func callModel(call *Call) (string, any) {
+	switch {
+	case call.ChatRequest != nil:
+		return call.ChatRequest.Model, call.ChatRequest
+	case call.StreamRequest != nil:
+		return call.StreamRequest.Model, call.StreamRequest
+	case call.FIMRequest != nil:
+		return call.FIMRequest.Model, call.FIMRequest
+	case call.FIMStreamRequest != nil:
+		return call.FIMStreamRequest.Model, call.FIMStreamRequest
+	case call.ImageRequest != nil:
+		return call.ImageRequest.Model, call.ImageRequest
+	case call.ImageStreamRequest != nil:
+		return call.ImageStreamRequest.Model, call.ImageStreamRequest
+	}
+	return "", nil
+}
+
+// logCall wraps the innermost handler of a call so its start, end and streams are logged.
+func (c *Client) logCall(next Handler) Handler {
+	return func(ctx context.Context, call *Call) (*Result, error) {
+		l := c.Log
+		model, request := callModel(call)
+		base := []slog.Attr{slog.String("operation", string(call.Operation)), slog.String("model", model)}
+		l.log(ctx, slog.LevelDebug, "call started", base, request)
+
+		start := time.Now()
+		result, err := next(ctx, call)
+		attrs := append(base[:len(base):len(base)], slog.Duration("latency", time.Since(start)))
+		if err != nil {
+			l.log(ctx, slog.LevelError, "call failed", append(attrs, slog.Any("error", err)), nil)
+			return nil, err
+		}
+		switch {
+		case result.ChatResponse != nil:
+			l.log(ctx, slog.LevelInfo, "call finished", append(attrs, usageAttr(result.ChatResponse.Usage)), result.ChatResponse)
+		case result.FIMResponse != nil:
+			usage := Usage{
+				PromptTokens:     result.FIMResponse.Usage.PromptTokens,
+				CompletionTokens: result.FIMResponse.Usage.CompletionTokens,
+				TotalTokens:      result.FIMResponse.Usage.TotalTokens,
+			}
+			l.log(ctx, slog.LevelInfo, "call finished", append(attrs, usageAttr(usage)), result.FIMResponse)
+		case result.Balance != nil:
+			l.log(ctx, slog.LevelInfo, "call finished", attrs, result.Balance)
+		case result.Models != nil:
+			l.log(ctx, slog.LevelInfo, "call finished", attrs, result.Models)
+		case result.Stream != nil:
+			l.log(ctx, slog.LevelInfo, "stream opened", attrs, nil)
+			result.Stream = &loggingChatStream{ChatCompletionStream: result.Stream, streamLog: newStreamLog(ctx, l, base)}
+		case result.FIMStream != nil:
+			l.log(ctx, slog.LevelInfo, "stream opened", attrs, nil)
+			result.FIMStream = &loggingFIMStream{FIMChatCompletionStream: result.FIMStream, streamLog: newStreamLog(ctx, l, base)}
+		}
+		return result, nil
+	}
+}
+
+// streamLog logs the lifecycle of a stream.
+type streamLog struct {
+	ctx    context.Context
+	log    *LogConfig
+	attrs  []slog.Attr
+	start  time.Time
+	chunks int
+	usage  *Usage
+	done   bool
+}
+
+// newStreamLog starts the log of a stream opened by a call with the given attributes.
+func newStreamLog(ctx context.Context, l *LogConfig, attrs []slog.Attr) *streamLog {
+	return &streamLog{ctx: ctx, log: l, attrs: attrs[:len(attrs):len(attrs)], start: time.Now()}
+}
+
+// received logs the outcome of receiving a chunk.
+func (s *streamLog) received(chunk any, usage *StreamUsage, err error) {
+	if s.done {
+		return
+	}
+	switch {
+	case err == nil:
+		s.chunks++
+		if usage != nil && usage.TotalTokens > 0 {
+			u := usage.toUsage()
+			s.usage = &u
+		}
+		s.log.log(s.ctx, s.log.BodyLevel, "stream chunk", s.attrs, chunk)
+	case errors.Is(err, io.EOF):
+		s.finish(slog.LevelInfo, "stream finished", nil)
+	default:
+		s.finish(slog.LevelError, "stream failed", err)
+	}
+}
+
+// finish logs the end of the stream.
+func (s *streamLog) finish(level slog.Level, msg string, err error) {
+	if s.done {
+		return
+	}
+	s.done = true
+	attrs := append(s.attrs, slog.Duration("duration", time.Since(s.start)), slog.Int("chunks", s.chunks))
+	if s.usage != nil {
+		attrs = append(attrs, usageAttr(*s.usage))
+	}
+	if err != nil {
+		attrs = append(attrs, slog.Any("error", err))
+	}
+	s.log.log(s.ctx, level, msg, attrs, nil)
+}
+
+// loggingChatStream logs the lifecycle of a chat completion stream.
+type loggingChatStream struct {
+	ChatCompletionStream
+	*streamLog
+}
+
+// Recv receives the next chunk and logs it.
+func (s *loggingChatStream) Recv() (*StreamChatCompletionResponse, error) {
+	chunk, err := s.ChatCompletionStream.Recv()
+	var usage *StreamUsage
+	if chunk != nil {
+		usage = chunk.Usage
+	}
+	s.received(chunk, usage, err)
+	return chunk, err
+}
+
+// Close closes the stream, logging it if it was not finished.
+func (s *loggingChatStream) Close() error {
+	s.finish(slog.LevelDebug, "stream closed", nil)
+	return s.ChatCompletionStream.Close()
+}
+
+// cancelStream cancels the request of the wrapped stream.
+func (s *loggingChatStream) cancelStream() bool {
+	return cancelStream(s.ChatCompletionStream)
+}
+
+// loggingFIMStream logs the lifecycle of a FIM completion stream.
+type loggingFIMStream struct {
+	FIMChatCompletionStream
+	*streamLog
+}
+
+// FIMRecv receives the next chunk and logs it.
+func (s *loggingFIMStream) FIMRecv() (*FIMStreamCompletionResponse, error) {
+	chunk, err := s.FIMChatCompletionStream.FIMRecv()
+	var usage *StreamUsage
+	if chunk != nil {
+		usage = chunk.Usage
+	}
+	s.received(chunk, usage, err)
+	return chunk, err
+}
+
+// FIMClose closes the stream, logging it if it was not finished.
+func (s *loggingFIMStream) FIMClose() error {
+	s.finish(slog.LevelDebug, "stream closed", nil)
+	return s.FIMChatCompletionStream.FIMClose()
+}
+
+// cancelStream cancels the request of the wrapped stream.
+func (s *loggingFIMStream) cancelStream() bool {
+	return cancelStream(s.FIMChatCompletionStream)
+}
+
+// loggingDoer logs every HTTP attempt of a request.
+type loggingDoer struct {
+	HTTPDoer
+	client   *Client
+	attempts int
+}
+
+// Do sends req and logs the attempt with its status and latency.
+func (d *loggingDoer) Do(req *http.Request) (*http.Response, error) {
+	d.attempts++
+	ctx, l := req.Context(), d.client.Log
+	attrs := []slog.Attr{
+		slog.String("method", req.Method),
+		slog.String("url", req.URL.Redacted()),
+		slog.Int("attempt", d.attempts),
+	}
+	if l.enabled(ctx, slog.LevelDebug) {
+		requestAttrs := attrs
+		if l.enabled(ctx, l.BodyLevel) {
+			requestAttrs = append(requestAttrs[:len(attrs):len(attrs)], slog.Any("headers", d.client.redactHeaders(req.Header)))
+		}
+		l.log(ctx, slog.LevelDebug, "http request", requestAttrs, nil)
+	}
+
+	start := time.Now()
+	resp, err := d.HTTPDoer.Do(req)
+	attrs = append(attrs, slog.Duration("latency", time.Since(start)))
+	if err != nil {
+		l.log(ctx, slog.LevelDebug, "http request failed", append(attrs, slog.Any("error", err)), nil)
+		return resp, err
+	}
+	l.log(ctx, slog.LevelDebug, "http response", append(attrs, slog.Int("status", resp.StatusCode)), nil)
+	return resp, nil
+}
+
+// logRetry logs that a failed attempt of a request is retried after wait.
+func (c *Client) logRetry(ctx context.Context, attempt int, wait time.Duration, resp *http.Response, err error) {
+	attrs := []slog.Attr{slog.Int("attempt", attempt), slog.Duration("backoff", wait)}
+	if err != nil {
+		attrs = append(attrs, slog.Any("error", err))
+	} else {
+		attrs = append(attrs, slog.Int("status", resp.StatusCode))
+	}
+	c.Log.log(ctx, slog.LevelWarn, "retrying request", attrs, nil)
+}
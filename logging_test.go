@@ -0,0 +1,156 @@
+package deepseek_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cohesion-org/deepseek-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// logEvents parses the JSON lines written by a slog.JSONHandler, keyed by message.
+func logEvents(t *testing.T, buf *bytes.Buffer) map[string]map[string]any {
+	t.Helper()
+	events := make(map[string]map[string]any)
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var event map[string]any
+		require.NoError(t, json.Unmarshal([]byte(line), &event))
+		events[event["msg"].(string)] = event
+	}
+	return events
+}
+
+func TestLogger(t *testing.T) {
+	ts, _ := keyServer(t, nil)
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	client, err := deepseek.NewClientWithOptions("secret-key", deepseek.WithBaseURL(ts.URL+"/"), deepseek.WithLogger(logger))
+	require.NoError(t, err)
+
+	_, err = client.CreateChatCompletion(context.Background(), chatRequest(deepseek.DeepSeekChat))
+	require.NoError(t, err)
+	assert.NotContains(t, buf.String(), "secret-key", "the API key is never logged")
+
+	events := logEvents(t, &buf)
+	started := events["call started"]
+	require.NotNil(t, started)
+	assert.Equal(t, "chat_completion", started["operation"])
+	assert.Equal(t, deepseek.DeepSeekChat, started["model"])
+	assert.Contains(t, started["body"], `"content":"hi"`)
+
+	request := events["http request"]
+	require.NotNil(t, request)
+	assert.Equal(t, float64(1), request["attempt"])
+	assert.Equal(t, "[redacted]", request["headers"].(map[string]any)["Authorization"])
+	assert.Equal(t, float64(http.StatusOK), events["http response"]["status"])
+
+	finished := events["call finished"]
+	require.NotNil(t, finished)
+	assert.Equal(t, "INFO", finished["level"])
+	assert.Contains(t, finished, "latency")
+	assert.Equal(t, float64(10), finished["usage"].(map[string]any)["total_tokens"])
+}
+
+func TestLoggerBalanceAndModels(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	client, err := deepseek.NewClientWithOptions("secret-key", deepseek.WithHTTPClient(&balanceDoer{}), deepseek.WithLogger(logger))
+	require.NoError(t, err)
+
+	_, err = deepseek.GetBalance(client, context.Background())
+	require.NoError(t, err)
+	events := logEvents(t, &buf)
+	assert.Equal(t, "get_balance", events["call finished"]["operation"])
+	assert.Contains(t, events["call finished"]["body"], `"total_balance":"5.00"`)
+	assert.NotNil(t, events["http request"])
+
+	buf.Reset()
+	client.HTTPClient = &recordingDoer{}
+	_, err = deepseek.ListAllModels(client, context.Background())
+	require.NoError(t, err)
+	events = logEvents(t, &buf)
+	assert.Equal(t, "list_models", events["call finished"]["operation"])
+	assert.NotContains(t, buf.String(), "secret-key", "the API key is never logged")
+}
+
+func TestLoggerBodyLevelAndRedaction(t *testing.T) {
+	ts, _ := keyServer(t, nil)
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	client, err := deepseek.NewClientWithOptions("token", deepseek.WithBaseURL(ts.URL+"/"),
+		deepseek.WithLogger(logger), deepseek.WithContentRedactor(deepseek.RedactedContent))
+	require.NoError(t, err)
+
+	_, err = client.CreateChatCompletion(context.Background(), chatRequest(deepseek.DeepSeekChat))
+	require.NoError(t, err)
+	events := logEvents(t, &buf)
+	assert.Contains(t, events["call started"]["body"], `"content":"[redacted 2 bytes]"`)
+	assert.Contains(t, events["call started"]["body"], `"model":"deepseek-chat"`, "other fields are kept")
+	assert.NotContains(t, buf.String(), `"content":"hi"`)
+
+	buf.Reset()
+	client, err = deepseek.NewClientWithOptions("token", deepseek.WithBaseURL(ts.URL+"/"),
+		deepseek.WithLogger(logger), deepseek.WithLogBodyLevel(slog.LevelDebug-4))
+	require.NoError(t, err)
+	_, err = client.CreateChatCompletion(context.Background(), chatRequest(deepseek.DeepSeekChat))
+	require.NoError(t, err)
+	events = logEvents(t, &buf)
+	require.NotNil(t, events["call finished"])
+	assert.NotContains(t, events["call started"], "body", "bodies need their own level")
+	assert.NotContains(t, events["http request"], "headers")
+
+	_, err = deepseek.NewClientWithOptions("token", deepseek.WithContentRedactor(deepseek.RedactedContent))
+	require.Error(t, err, "the redactor needs a logger")
+}
+
+func TestLoggerRetriesAndStreams(t *testing.T) {
+	var calls atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("data: {\"id\":\"s\",\"choices\":[{\"index\":0,\"delta\":{\"content\":\"hi\"}}]}\n\n" +
+			"data: {\"id\":\"s\",\"choices\":[],\"usage\":{\"prompt_tokens\":3,\"completion_tokens\":1,\"total_tokens\":4}}\n\n" +
+			"data: [DONE]\n\n"))
+	}))
+	defer ts.Close()
+
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelInfo}))
+	policy := deepseek.DefaultRetryPolicy()
+	policy.InitialBackoff = time.Millisecond
+	client, err := deepseek.NewClientWithOptions("token", deepseek.WithBaseURL(ts.URL+"/"),
+		deepseek.WithLogger(logger), deepseek.WithRetryPolicy(policy))
+	require.NoError(t, err)
+
+	stream, err := client.CreateChatCompletionStream(context.Background(), &deepseek.StreamChatCompletionRequest{
+		Model:    deepseek.DeepSeekChat,
+		Messages: []deepseek.ChatCompletionMessage{{Role: deepseek.ChatMessageRoleUser, Content: "hi"}},
+	})
+	require.NoError(t, err)
+	_, err = deepseek.CollectStream(stream)
+	require.NoError(t, err)
+
+	events := logEvents(t, &buf)
+	assert.NotContains(t, events, "http request", "debug events are filtered by the logger")
+	retry := events["retrying request"]
+	require.NotNil(t, retry)
+	assert.Equal(t, "WARN", retry["level"])
+	assert.Equal(t, float64(http.StatusServiceUnavailable), retry["status"])
+	require.NotNil(t, events["stream opened"])
+	finished := events["stream finished"]
+	require.NotNil(t, finished)
+	assert.Equal(t, float64(2), finished["chunks"])
+	assert.Equal(t, float64(4), finished["usage"].(map[string]any)["total_tokens"])
+	assert.NotContains(t, events, "stream closed", "finished streams are not logged again")
+}
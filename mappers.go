@@ -12,21 +12,23 @@ var validRoles = map[string]bool{
 	constants.ChatMessageRoleSystem:    true,
 }
 
-// MapMessageToChatCompletionMessage maps a Message to a ChatCompletionMessage
+// MapMessageToChatCompletionMessage maps a Message to a ChatCompletionMessage, keeping its tool calls.
 func MapMessageToChatCompletionMessage(m Message) (ChatCompletionMessage, error) {
 	if m.Role == "" {
 		return ChatCompletionMessage{}, errors.New("message role cannot be empty")
 	}
 
-	if m.Content == "" {
+	if m.Content == "" && len(m.ToolCalls) == 0 {
 		return ChatCompletionMessage{}, errors.New("message content cannot be empty")
 	}
 	if !validRoles[m.Role] {
 		return ChatCompletionMessage{}, errors.New("invalid role: %s. Valid roles are can be found in official deepseek documentation")
 	}
 
+	// ReasoningContent is not copied: the API rejects it in earlier turns of a conversation.
 	return ChatCompletionMessage{
-		Role:    m.Role,
-		Content: m.Content,
+		Role:      m.Role,
+		Content:   m.Content,
+		ToolCalls: m.ToolCalls,
 	}, nil
 }
@@ -0,0 +1,161 @@
+package deepseek
+
+import (
+	"context"
+	"fmt"
+)
+
+// Operation identifies the kind of call passing through the middleware chain.
+type Operation string
+
+// Operations passed to middleware.
+const (
+	OperationChatCompletion                Operation = "chat_completion"                   // CreateChatCompletion
+	OperationChatCompletionStream          Operation = "chat_completion_stream"            // CreateChatCompletionStream
+	OperationFIMCompletion                 Operation = "fim_completion"                    // CreateFIMCompletion
+	OperationFIMCompletionStream           Operation = "fim_completion_stream"             // CreateFIMStreamCompletion
+	OperationChatCompletionWithImage       Operation = "chat_completion_with_image"        // CreateChatCompletionWithImage
+	OperationChatCompletionStreamWithImage Operation = "chat_completion_stream_with_image" // CreateChatCompletionStreamWithImage
+	OperationGetBalance                    Operation = "get_balance"                       // GetBalance
+	OperationListModels                    Operation = "list_models"                       // ListAllModels
+)
+
+// Call holds the typed request of a single client call. Only the field matching Operation is set;
+// OperationGetBalance and OperationListModels have no request.
+// Middleware may modify the request in place or replace it before calling the next handler.
+type Call struct {
+	Operation          Operation
+	ChatRequest        *ChatCompletionRequest                // Set for OperationChatCompletion.
+	StreamRequest      *StreamChatCompletionRequest          // Set for OperationChatCompletionStream.
+	FIMRequest         *FIMCompletionRequest                 // Set for OperationFIMCompletion.
+	FIMStreamRequest   *FIMStreamCompletionRequest           // Set for OperationFIMCompletionStream.
+	ImageRequest       *ChatCompletionRequestWithImage       // Set for OperationChatCompletionWithImage.
+	ImageStreamRequest *StreamChatCompletionRequestWithImage // Set for OperationChatCompletionStreamWithImage.
+}
+
+// Result holds the typed response of a single client call. Only the field matching the call's Operation is set.
+type Result struct {
+	ChatResponse *ChatCompletionResponse // Set for OperationChatCompletion and OperationChatCompletionWithImage.
+	Stream       ChatCompletionStream    // Set for OperationChatCompletionStream and OperationChatCompletionStreamWithImage.
+	FIMResponse  *FIMCompletionResponse  // Set for OperationFIMCompletion.
+	FIMStream    FIMChatCompletionStream // Set for OperationFIMCompletionStream.
+	Balance      *BalanceResponse        // Set for OperationGetBalance.
+	Models       *APIModels              // Set for OperationListModels.
+}
+
+// Handler sends a call and returns its result.
+type Handler func(ctx context.Context, call *Call) (*Result, error)
+
+// Middleware wraps a Handler. It can observe or modify the call and the result, or return a result
+// without calling next at all. Retries and rate limiting happen inside the innermost handler,
+// so middleware sees one call per Create* invocation.
+type Middleware func(next Handler) Handler
+
+// WithMiddleware appends middleware to the client's chain. The first middleware is the outermost one.
+func WithMiddleware(middleware ...Middleware) Option {
+	return func(c *Client) error {
+		for _, mw := range middleware {
+			if mw == nil {
+				return fmt.Errorf("middleware cannot be nil")
+			}
+		}
+		c.Middleware = append(c.Middleware, middleware...)
+		return nil
+	}
+}
+
+// runMiddleware sends the call through the client's middleware chain, ending in final.
+func (c *Client) runMiddleware(ctx context.Context, call *Call, final Handler) (*Result, error) {
+	h := final
+	if c.Log != nil {
+		h = c.logCall(h)
+	}
+	for i := len(c.Middleware) - 1; i >= 0; i-- {
+		h = c.Middleware[i](h)
+	}
+	if c.Keys != nil {
+		ctx = c.Keys.withUsedKey(ctx)
+	}
+	result, err := h(ctx, call)
+	if err != nil {
+		return nil, err
+	}
+	if result == nil {
+		return nil, fmt.Errorf("middleware returned no result for %s", call.Operation)
+	}
+	return result, nil
+}
+
+// ChatStreamHook is called for every chunk received from a chat completion stream.
+// It may modify the chunk, or return an error to stop the stream.
+type ChatStreamHook func(chunk *StreamChatCompletionResponse) error
+
+// FIMStreamHook is called for every chunk received from a FIM completion stream.
+// It may modify the chunk, or return an error to stop the stream.
+type FIMStreamHook func(chunk *FIMStreamCompletionResponse) error
+
+// hookedChatStream calls a hook for every chunk of the wrapped stream.
+type hookedChatStream struct {
+	ChatCompletionStream
+	hook ChatStreamHook
+}
+
+// Recv receives the next chunk and passes it to the hook.
+func (s *hookedChatStream) Recv() (*StreamChatCompletionResponse, error) {
+	chunk, err := s.ChatCompletionStream.Recv()
+	if err != nil {
+		return chunk, err
+	}
+	if err := s.hook(chunk); err != nil {
+		return nil, err
+	}
+	return chunk, nil
+}
+
+// cancelStream cancels the request of the wrapped stream.
+func (s *hookedChatStream) cancelStream() bool {
+	return cancelStream(s.ChatCompletionStream)
+}
+
+// hookedFIMStream calls a hook for every chunk of the wrapped stream.
+type hookedFIMStream struct {
+	FIMChatCompletionStream
+	hook FIMStreamHook
+}
+
+// FIMRecv receives the next chunk and passes it to the hook.
+func (s *hookedFIMStream) FIMRecv() (*FIMStreamCompletionResponse, error) {
+	chunk, err := s.FIMChatCompletionStream.FIMRecv()
+	if err != nil {
+		return chunk, err
+	}
+	if err := s.hook(chunk); err != nil {
+		return nil, err
+	}
+	return chunk, nil
+}
+
+// cancelStream cancels the request of the wrapped stream.
+func (s *hookedFIMStream) cancelStream() bool {
+	return cancelStream(s.FIMChatCompletionStream)
+}
+
+// StreamHookMiddleware returns middleware that runs the given hooks on every streamed chunk.
+// Either hook may be nil.
+func StreamHookMiddleware(chatHook ChatStreamHook, fimHook FIMStreamHook) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, call *Call) (*Result, error) {
+			result, err := next(ctx, call)
+			if err != nil || result == nil {
+				return result, err
+			}
+			if chatHook != nil && result.Stream != nil {
+				result.Stream = &hookedChatStream{ChatCompletionStream: result.Stream, hook: chatHook}
+			}
+			if fimHook != nil && result.FIMStream != nil {
+				result.FIMStream = &hookedFIMStream{FIMChatCompletionStream: result.FIMStream, hook: fimHook}
+			}
+			return result, nil
+		}
+	}
+}
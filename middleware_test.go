@@ -0,0 +1,168 @@
+package deepseek_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/cohesion-org/deepseek-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMiddleware(t *testing.T) {
+	t.Run("runs in order and can mutate the request", func(t *testing.T) {
+		var model string
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var body deepseek.ChatCompletionRequest
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			model = body.Model
+			w.Write([]byte(retryChatResponse))
+		}))
+		defer ts.Close()
+
+		var order []string
+		trace := func(name string) deepseek.Middleware {
+			return func(next deepseek.Handler) deepseek.Handler {
+				return func(ctx context.Context, call *deepseek.Call) (*deepseek.Result, error) {
+					order = append(order, name+" before")
+					result, err := next(ctx, call)
+					order = append(order, name+" after")
+					return result, err
+				}
+			}
+		}
+		rewrite := func(next deepseek.Handler) deepseek.Handler {
+			return func(ctx context.Context, call *deepseek.Call) (*deepseek.Result, error) {
+				assert.Equal(t, deepseek.OperationChatCompletion, call.Operation)
+				call.ChatRequest.Model = "rewritten"
+				return next(ctx, call)
+			}
+		}
+
+		client, err := deepseek.NewClientWithOptions("token",
+			deepseek.WithBaseURL(ts.URL+"/"),
+			deepseek.WithMiddleware(trace("outer"), trace("inner"), rewrite),
+		)
+		require.NoError(t, err)
+
+		_, err = client.CreateChatCompletion(context.Background(), &deepseek.ChatCompletionRequest{Model: deepseek.DeepSeekChat})
+		require.NoError(t, err)
+		assert.Equal(t, "rewritten", model)
+		assert.Equal(t, []string{"outer before", "inner before", "inner after", "outer after"}, order)
+	})
+
+	t.Run("can short-circuit", func(t *testing.T) {
+		var calls int32
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&calls, 1)
+		}))
+		defer ts.Close()
+
+		cached := &deepseek.ChatCompletionResponse{ID: "cached"}
+		cache := func(next deepseek.Handler) deepseek.Handler {
+			return func(ctx context.Context, call *deepseek.Call) (*deepseek.Result, error) {
+				return &deepseek.Result{ChatResponse: cached}, nil
+			}
+		}
+
+		client, err := deepseek.NewClientWithOptions("token",
+			deepseek.WithBaseURL(ts.URL+"/"),
+			deepseek.WithMiddleware(cache),
+		)
+		require.NoError(t, err)
+
+		resp, err := client.CreateChatCompletion(context.Background(), &deepseek.ChatCompletionRequest{})
+		require.NoError(t, err)
+		assert.Same(t, cached, resp)
+		assert.Equal(t, int32(0), atomic.LoadInt32(&calls))
+	})
+
+	t.Run("sees errors and keeps their type", func(t *testing.T) {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusBadRequest)
+		}))
+		defer ts.Close()
+
+		var seen error
+		observe := func(next deepseek.Handler) deepseek.Handler {
+			return func(ctx context.Context, call *deepseek.Call) (*deepseek.Result, error) {
+				result, err := next(ctx, call)
+				seen = err
+				return result, err
+			}
+		}
+
+		client, err := deepseek.NewClientWithOptions("token",
+			deepseek.WithBaseURL(ts.URL+"/"),
+			deepseek.WithMiddleware(observe),
+		)
+		require.NoError(t, err)
+
+		_, err = client.CreateChatCompletion(context.Background(), &deepseek.ChatCompletionRequest{})
+		_, ok := err.(*deepseek.APIError)
+		assert.True(t, ok)
+		assert.Equal(t, err, seen)
+	})
+
+	t.Run("missing result is an error", func(t *testing.T) {
+		empty := func(next deepseek.Handler) deepseek.Handler {
+			return func(ctx context.Context, call *deepseek.Call) (*deepseek.Result, error) {
+				return &deepseek.Result{}, nil
+			}
+		}
+		client, err := deepseek.NewClientWithOptions("token", deepseek.WithMiddleware(empty))
+		require.NoError(t, err)
+
+		_, err = client.CreateChatCompletion(context.Background(), &deepseek.ChatCompletionRequest{})
+		require.ErrorIs(t, err, deepseek.ErrUnexpectedResponseFormat)
+	})
+
+	t.Run("rejects nil middleware", func(t *testing.T) {
+		_, err := deepseek.NewClientWithOptions("token", deepseek.WithMiddleware(nil))
+		require.Error(t, err)
+	})
+}
+
+func TestStreamHookMiddleware(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("data: {\"id\":\"1\",\"choices\":[{\"index\":0,\"delta\":{\"content\":\"secret\"}}]}\n\n" +
+			"data: {\"id\":\"1\",\"choices\":[{\"index\":0,\"delta\":{\"content\":\"stop\"}}]}\n\ndata: [DONE]\n\n"))
+	}))
+	defer ts.Close()
+
+	errStop := errors.New("stop")
+	hook := func(chunk *deepseek.StreamChatCompletionResponse) error {
+		if chunk.Choices[0].Delta.Content == "stop" {
+			return errStop
+		}
+		chunk.Choices[0].Delta.Content = strings.Repeat("*", len(chunk.Choices[0].Delta.Content))
+		return nil
+	}
+
+	client, err := deepseek.NewClientWithOptions("token",
+		deepseek.WithBaseURL(ts.URL+"/"),
+		deepseek.WithMiddleware(deepseek.StreamHookMiddleware(hook, nil)),
+	)
+	require.NoError(t, err)
+
+	stream, err := client.CreateChatCompletionStream(context.Background(), &deepseek.StreamChatCompletionRequest{})
+	require.NoError(t, err)
+	defer stream.Close()
+
+	chunk, err := stream.Recv()
+	require.NoError(t, err)
+	assert.Equal(t, "******", chunk.Choices[0].Delta.Content)
+
+	_, err = stream.Recv()
+	require.ErrorIs(t, err, errStop)
+
+	_, err = stream.Recv()
+	require.ErrorIs(t, err, io.EOF)
+}
@@ -0,0 +1,215 @@
+package deepseek
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"sync"
+)
+
+// Request parameters that a model may ignore, named as in the request body.
+const (
+	ParamTemperature      = "temperature"
+	ParamTopP             = "top_p"
+	ParamPresencePenalty  = "presence_penalty"
+	ParamFrequencyPenalty = "frequency_penalty"
+)
+
+// ModelPricing holds the prices of a model per million tokens.
+type ModelPricing struct {
+	InputCacheHit  float64 // Price of a million prompt tokens served from the context cache.
+	InputCacheMiss float64 // Price of a million prompt tokens not served from the cache.
+	Output         float64 // Price of a million completion tokens, including reasoning tokens.
+	Currency       string  // ISO 4217 currency code of the prices, e.g. "USD".
+}
+
+// ModelCapabilities describes what a model supports.
+type ModelCapabilities struct {
+	ID                  string        // The model ID sent in requests.
+	ContextLength       int           // Maximum number of prompt plus completion tokens.
+	MaxOutputTokens     int           // Maximum value of max_tokens.
+	DefaultOutputTokens int           // Completion tokens the API allows when max_tokens is not set.
+	Tools               bool          // Supports function calling.
+	JSONMode            bool          // Supports response_format json_object.
+	FIM                 bool          // Supports FIM (fill in the middle) completion.
+	PrefixCompletion    bool          // Supports Chat Prefix Completion.
+	LogProbs            bool          // Supports logprobs and top_logprobs.
+	Images              bool          // Accepts image content.
+	StripReasoning      bool          // Rejects reasoning_content in earlier turns, so Conversation removes it from the history.
+	IgnoredParams       []string      // Sampling parameters that are accepted but have no effect. See the Param constants.
+	Pricing             *ModelPricing // Prices of the model. Nil if unknown.
+}
+
+// Ignores reports whether the model ignores the given request parameter.
+func (m ModelCapabilities) Ignores(param string) bool {
+	return slices.Contains(m.IgnoredParams, param)
+}
+
+// ModelRegistry maps model IDs to their capabilities. It is safe for concurrent use and can be changed at runtime.
+type ModelRegistry struct {
+	mu     sync.RWMutex
+	models map[string]ModelCapabilities
+}
+
+// deepSeekPricing is the price list of the official DeepSeek API.
+var deepSeekPricing = ModelPricing{InputCacheHit: 0.028, InputCacheMiss: 0.28, Output: 0.42, Currency: "USD"}
+
+// builtinModels holds the capabilities of the models with a constant in this package.
+func builtinModels() []ModelCapabilities {
+	chat := ModelCapabilities{
+		ID:                  DeepSeekChat,
+		ContextLength:       128_000,
+		MaxOutputTokens:     8_000,
+		DefaultOutputTokens: 4_000,
+		Tools:               true,
+		JSONMode:            true,
+		FIM:                 true,
+		PrefixCompletion:    true,
+		LogProbs:            true,
+		Pricing:             &deepSeekPricing,
+	}
+	coder := chat
+	coder.ID = DeepSeekCoder
+
+	reasoner := ModelCapabilities{
+		ID:                  DeepSeekReasoner,
+		ContextLength:       128_000,
+		MaxOutputTokens:     64_000,
+		DefaultOutputTokens: 32_000,
+		Tools:               true,
+		JSONMode:            true,
+		PrefixCompletion:    true,
+		StripReasoning:      true,
+		IgnoredParams:       []string{ParamTemperature, ParamTopP, ParamPresencePenalty, ParamFrequencyPenalty},
+		Pricing:             &deepSeekPricing,
+	}
+
+	r1 := func(id string, contextLength int) ModelCapabilities {
+		return ModelCapabilities{ID: id, ContextLength: contextLength, MaxOutputTokens: 32_000, DefaultOutputTokens: 4_000}
+	}
+	return []ModelCapabilities{
+		chat,
+		coder,
+		reasoner,
+		r1(AzureDeepSeekR1, 128_000),
+		r1(OpenRouterDeepSeekR1, 163_840),
+		r1(OpenRouterDeepSeekR1DistillLlama70B, 131_072),
+		r1(OpenRouterDeepSeekR1DistillLlama8B, 32_000),
+		r1(OpenRouterDeepSeekR1DistillQwen14B, 64_000),
+		r1(OpenRouterDeepSeekR1DistillQwen1_5B, 131_072),
+		r1(OpenRouterDeepSeekR1DistillQwen32B, 128_000),
+	}
+}
+
+// defaultModelRegistry is returned by DefaultModelRegistry.
+var defaultModelRegistry = NewModelRegistry(builtinModels()...)
+
+// DefaultModelRegistry returns the registry used when no other registry is configured.
+// It contains the models with a constant in this package; changes to it affect the whole program.
+func DefaultModelRegistry() *ModelRegistry {
+	return defaultModelRegistry
+}
+
+// NewModelRegistry creates a registry containing the given models.
+func NewModelRegistry(models ...ModelCapabilities) *ModelRegistry {
+	r := &ModelRegistry{models: make(map[string]ModelCapabilities, len(models))}
+	for _, m := range models {
+		r.models[m.ID] = cloneCapabilities(m)
+	}
+	return r
+}
+
+// Register adds a model or replaces the capabilities of a registered one.
+func (r *ModelRegistry) Register(model ModelCapabilities) error {
+	if model.ID == "" {
+		return fmt.Errorf("model ID cannot be empty")
+	}
+	if model.ContextLength < 0 || model.MaxOutputTokens < 0 || model.DefaultOutputTokens < 0 {
+		return fmt.Errorf("token limits of model %q cannot be negative", model.ID)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.models[model.ID] = cloneCapabilities(model)
+	return nil
+}
+
+// Remove removes a model from the registry.
+func (r *ModelRegistry) Remove(id string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.models, id)
+}
+
+// Lookup returns the capabilities of a model.
+func (r *ModelRegistry) Lookup(id string) (ModelCapabilities, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	m, ok := r.models[id]
+	if !ok {
+		return ModelCapabilities{}, false
+	}
+	return cloneCapabilities(m), true
+}
+
+// IDs returns the IDs of all registered models in lexical order.
+func (r *ModelRegistry) IDs() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	ids := make([]string, 0, len(r.models))
+	for id := range r.models {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// RegisterAPIModels adds the models returned by ListAllModels that are not registered yet.
+// Their capabilities are copied from template, since the API only reports model IDs.
+// It returns the IDs of the added models.
+func (r *ModelRegistry) RegisterAPIModels(models *APIModels, template ModelCapabilities) []string {
+	if models == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var added []string
+	for _, model := range models.Data {
+		if _, ok := r.models[model.ID]; ok || model.ID == "" {
+			continue
+		}
+		m := cloneCapabilities(template)
+		m.ID = model.ID
+		r.models[model.ID] = m
+		added = append(added, model.ID)
+	}
+	return added
+}
+
+// cloneCapabilities copies the slices and pointers of m so the registry does not share them with callers.
+func cloneCapabilities(m ModelCapabilities) ModelCapabilities {
+	m.IgnoredParams = slices.Clone(m.IgnoredParams)
+	if m.Pricing != nil {
+		pricing := *m.Pricing
+		m.Pricing = &pricing
+	}
+	return m
+}
+
+// WithModelRegistry sets the registry the client consults for model capabilities and prices.
+func WithModelRegistry(registry *ModelRegistry) Option {
+	return func(c *Client) error {
+		if registry == nil {
+			return fmt.Errorf("model registry cannot be nil")
+		}
+		c.Models = registry
+		return nil
+	}
+}
+
+// modelRegistry returns the client's registry, or the default registry if none is set.
+func (c *Client) modelRegistry() *ModelRegistry {
+	if c.Models != nil {
+		return c.Models
+	}
+	return DefaultModelRegistry()
+}
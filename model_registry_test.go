@@ -0,0 +1,80 @@
+package deepseek_test
+
+import (
+	"testing"
+
+	"github.com/cohesion-org/deepseek-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDefaultModelRegistry(t *testing.T) {
+	registry := deepseek.DefaultModelRegistry()
+
+	chat, ok := registry.Lookup(deepseek.DeepSeekChat)
+	require.True(t, ok)
+	assert.True(t, chat.FIM)
+	assert.True(t, chat.LogProbs)
+	assert.False(t, chat.Ignores(deepseek.ParamTemperature))
+	require.NotNil(t, chat.Pricing)
+	assert.Less(t, chat.Pricing.InputCacheHit, chat.Pricing.InputCacheMiss)
+
+	reasoner, ok := registry.Lookup(deepseek.DeepSeekReasoner)
+	require.True(t, ok)
+	assert.False(t, reasoner.FIM)
+	assert.True(t, reasoner.Ignores(deepseek.ParamTemperature))
+	assert.True(t, reasoner.Ignores(deepseek.ParamTopP))
+
+	for _, id := range []string{deepseek.AzureDeepSeekR1, deepseek.OpenRouterDeepSeekR1, deepseek.OpenRouterDeepSeekR1DistillQwen32B} {
+		caps, ok := registry.Lookup(id)
+		require.True(t, ok, id)
+		assert.Positive(t, caps.ContextLength, id)
+	}
+
+	_, ok = registry.Lookup("unknown")
+	assert.False(t, ok)
+}
+
+func TestModelRegistryOverrides(t *testing.T) {
+	registry := deepseek.NewModelRegistry(deepseek.ModelCapabilities{ID: "base", ContextLength: 1000, IgnoredParams: []string{deepseek.ParamTopP}})
+
+	caps, ok := registry.Lookup("base")
+	require.True(t, ok)
+	caps.IgnoredParams[0] = deepseek.ParamTemperature
+	caps, _ = registry.Lookup("base")
+	assert.Equal(t, []string{deepseek.ParamTopP}, caps.IgnoredParams, "lookups return copies")
+
+	require.NoError(t, registry.Register(deepseek.ModelCapabilities{ID: "base", ContextLength: 2000}))
+	caps, _ = registry.Lookup("base")
+	assert.Equal(t, 2000, caps.ContextLength)
+	require.Error(t, registry.Register(deepseek.ModelCapabilities{}))
+	require.Error(t, registry.Register(deepseek.ModelCapabilities{ID: "bad", ContextLength: -1}))
+
+	added := registry.RegisterAPIModels(&deepseek.APIModels{Data: []deepseek.Model{{ID: "base"}, {ID: "new-model"}}},
+		deepseek.ModelCapabilities{ContextLength: 64_000, Tools: true})
+	assert.Equal(t, []string{"new-model"}, added)
+	caps, ok = registry.Lookup("new-model")
+	require.True(t, ok)
+	assert.Equal(t, "new-model", caps.ID)
+	assert.True(t, caps.Tools)
+	assert.Equal(t, []string{"base", "new-model"}, registry.IDs())
+
+	registry.Remove("base")
+	assert.Equal(t, []string{"new-model"}, registry.IDs())
+
+	manager := deepseek.NewContextManager(nil)
+	manager.Models = registry
+	budget, err := manager.Budget("new-model", 4000)
+	require.NoError(t, err)
+	assert.Equal(t, 60_000, budget)
+}
+
+func TestWithModelRegistry(t *testing.T) {
+	registry := deepseek.NewModelRegistry()
+	client, err := deepseek.NewClientWithOptions("token", deepseek.WithModelRegistry(registry))
+	require.NoError(t, err)
+	assert.Same(t, registry, client.Models)
+
+	_, err = deepseek.NewClientWithOptions("token", deepseek.WithModelRegistry(nil))
+	require.Error(t, err)
+}
@@ -41,9 +41,33 @@ type APIModels struct {
 }
 
 // ListAllModels sends a request to the API to get all available models.
+// With a provider set by WithProvider, the models are listed by the provider's OpenAI-compatible models endpoint.
 func ListAllModels(c *Client, ctx context.Context) (*APIModels, error) {
+	result, err := c.runMiddleware(ctx, &Call{Operation: OperationListModels},
+		func(ctx context.Context, call *Call) (*Result, error) {
+			models, err := listAllModels(c, ctx)
+			if err != nil {
+				return nil, err
+			}
+			return &Result{Models: models}, nil
+		})
+	if err != nil {
+		return nil, err
+	}
+	if result.Models == nil {
+		return nil, ErrUnexpectedResponseFormat
+	}
+	return result.Models, nil
+}
+
+// listAllModels sends the models request without running the client's middleware.
+func listAllModels(c *Client, ctx context.Context) (*APIModels, error) {
+	baseURL := "https://api.deepseek.com/"
+	if c.Profile != nil {
+		baseURL = c.BaseURL
+	}
 	req, err := utils.NewRequestBuilder(c.AuthToken).
-		SetBaseURL("https://api.deepseek.com/").
+		SetBaseURL(baseURL).
 		SetPath("models").
 		BuildGet(ctx)
 
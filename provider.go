@@ -0,0 +1,255 @@
+package deepseek
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ErrUnsupportedByProvider is returned by DeepSeek-only features, such as FIM completion and GetBalance,
+// when the client is configured for another provider with WithProvider.
+var ErrUnsupportedByProvider = errors.New("unsupported by provider")
+
+// ProviderProfile describes how to reach an OpenAI-compatible provider and how it differs from the DeepSeek API.
+type ProviderProfile struct {
+	Name           string            // Name of the provider, e.g. "openrouter".
+	BaseURL        string            // Base URL of the API, ending with a slash.
+	Path           string            // Path of the chat completion endpoint, relative to BaseURL.
+	AuthHeader     string            // Header carrying the API key. Defaults to "Authorization".
+	AuthScheme     string            // Scheme written before the key in AuthHeader, e.g. "Bearer". Empty sends the bare key.
+	Query          url.Values        // Query parameters added to every request, e.g. Azure's api-version.
+	Headers        map[string]string // Headers added to every request. Client.ExtraHeaders take precedence.
+	ReasoningField string            // Name of the reasoning field of sent messages. Defaults to "reasoning_content".
+	DropFields     []string          // Body fields the provider rejects, such as the deepseek-go "json" field.
+}
+
+// Provider returns the profile of an OpenAI-compatible provider. See WithProvider.
+type Provider interface {
+	Profile() (ProviderProfile, error)
+}
+
+// WithProvider configures the client for a provider: it sets the base URL, the path, the auth scheme,
+// the required headers and the provider's quirks. Options applied after it, such as WithBaseURL, still
+// override the profile. The auth scheme, headers and query are only sent to URLs under the profile's or
+// the client's base URL, and DeepSeek-only features return ErrUnsupportedByProvider.
+func WithProvider(provider Provider) Option {
+	return func(c *Client) error {
+		if provider == nil {
+			return fmt.Errorf("provider cannot be nil")
+		}
+		profile, err := provider.Profile()
+		if err != nil {
+			return fmt.Errorf("invalid provider: %w", err)
+		}
+		if profile.BaseURL == "" {
+			return fmt.Errorf("provider %q has no base URL", profile.Name)
+		}
+		c.BaseURL = profile.BaseURL
+		c.Path = profile.Path
+		if c.Path == "" {
+			c.Path = "chat/completions"
+		}
+		c.Profile = &profile
+		return nil
+	}
+}
+
+// OpenRouter is the profile of https://openrouter.ai. Referer and Title identify the app on the
+// OpenRouter rankings; they are sent as the HTTP-Referer and X-Title headers if set.
+type OpenRouter struct {
+	Referer string
+	Title   string
+}
+
+// Profile returns the OpenRouter profile.
+func (p OpenRouter) Profile() (ProviderProfile, error) {
+	headers := make(map[string]string)
+	if p.Referer != "" {
+		headers["HTTP-Referer"] = p.Referer
+	}
+	if p.Title != "" {
+		headers["X-Title"] = p.Title
+	}
+	return ProviderProfile{
+		Name:           "openrouter",
+		BaseURL:        "https://openrouter.ai/api/v1/",
+		AuthScheme:     "Bearer",
+		Headers:        headers,
+		ReasoningField: "reasoning",
+		DropFields:     []string{"json"},
+	}, nil
+}
+
+// Azure is the profile of Azure AI Foundry and Azure OpenAI. Endpoint is the resource endpoint, such as
+// https://my-resource.services.ai.azure.com. With a Deployment, requests go to the deployment's endpoint;
+// without one, to the Foundry model inference endpoint with the model named in the request.
+type Azure struct {
+	Endpoint   string
+	Deployment string
+	APIVersion string // Defaults to AzureDefaultAPIVersion.
+}
+
+// AzureDefaultAPIVersion is the api-version sent when Azure.APIVersion is empty.
+const AzureDefaultAPIVersion = "2024-05-01-preview"
+
+// Profile returns the Azure profile.
+func (p Azure) Profile() (ProviderProfile, error) {
+	endpoint := strings.TrimSuffix(p.Endpoint, "/")
+	if endpoint == "" {
+		return ProviderProfile{}, fmt.Errorf("azure endpoint cannot be empty")
+	}
+	version := p.APIVersion
+	if version == "" {
+		version = AzureDefaultAPIVersion
+	}
+	baseURL := endpoint + "/models/"
+	if p.Deployment != "" {
+		baseURL = endpoint + "/openai/deployments/" + url.PathEscape(p.Deployment) + "/"
+	}
+	return ProviderProfile{
+		Name:       "azure",
+		BaseURL:    baseURL,
+		AuthHeader: "api-key",
+		Query:      url.Values{"api-version": {version}},
+		DropFields: []string{"json", "enable_thinking"},
+	}, nil
+}
+
+// SiliconFlow is the profile of https://siliconflow.cn.
+type SiliconFlow struct{}
+
+// Profile returns the SiliconFlow profile.
+func (SiliconFlow) Profile() (ProviderProfile, error) {
+	return ProviderProfile{
+		Name:       "siliconflow",
+		BaseURL:    "https://api.siliconflow.cn/v1/",
+		AuthScheme: "Bearer",
+		DropFields: []string{"json"},
+	}, nil
+}
+
+// VLLM is the profile of a vLLM OpenAI-compatible server. BaseURL defaults to http://localhost:8000/v1/.
+type VLLM struct {
+	BaseURL string
+}
+
+// Profile returns the vLLM profile.
+func (p VLLM) Profile() (ProviderProfile, error) {
+	return ProviderProfile{
+		Name:       "vllm",
+		BaseURL:    withTrailingSlash(p.BaseURL, "http://localhost:8000/v1/"),
+		AuthScheme: "Bearer",
+		DropFields: []string{"json"},
+	}, nil
+}
+
+// OllamaOpenAI is the profile of Ollama's OpenAI-compatible endpoint. BaseURL defaults to
+// http://localhost:11434/v1/. CreateOllamaChatCompletion uses Ollama's native API instead.
+type OllamaOpenAI struct {
+	BaseURL string
+}
+
+// Profile returns the Ollama profile.
+func (p OllamaOpenAI) Profile() (ProviderProfile, error) {
+	return ProviderProfile{
+		Name:       "ollama",
+		BaseURL:    withTrailingSlash(p.BaseURL, "http://localhost:11434/v1/"),
+		AuthScheme: "Bearer",
+		DropFields: []string{"json"},
+	}, nil
+}
+
+// withTrailingSlash returns baseURL ending with a slash, or fallback if baseURL is empty.
+func withTrailingSlash(baseURL, fallback string) string {
+	if baseURL == "" {
+		return fallback
+	}
+	return strings.TrimSuffix(baseURL, "/") + "/"
+}
+
+// profileFor returns the client's profile if req goes to the provider, that is to a URL under the profile's
+// base URL or the client's base URL that replaced it. Otherwise it returns nil.
+func (c *Client) profileFor(req *http.Request) *ProviderProfile {
+	p := c.Profile
+	if p == nil {
+		return nil
+	}
+	target := req.URL.String()
+	if strings.HasPrefix(target, p.BaseURL) || (c.BaseURL != "" && strings.HasPrefix(target, c.BaseURL)) {
+		return p
+	}
+	return nil
+}
+
+// requireDeepSeek returns ErrUnsupportedByProvider if the client is configured for another provider.
+func (c *Client) requireDeepSeek(feature string) error {
+	if c.Profile == nil {
+		return nil
+	}
+	return fmt.Errorf("%w: %s is only available on the DeepSeek API, not on %s", ErrUnsupportedByProvider, feature, c.Profile.Name)
+}
+
+// prepareRequest applies the auth scheme, headers and query parameters of the client's profile to req.
+func (c *Client) prepareRequest(req *http.Request) {
+	p := c.profileFor(req)
+	if p == nil {
+		return
+	}
+	if (p.AuthHeader != "" && p.AuthHeader != "Authorization") || p.AuthScheme != "Bearer" {
+		c.setAuthToken(req, c.AuthToken)
+	}
+	for key, value := range p.Headers {
+		req.Header.Set(key, value)
+	}
+	if len(p.Query) > 0 {
+		query := req.URL.Query()
+		for key, values := range p.Query {
+			if !query.Has(key) {
+				query[key] = values
+			}
+		}
+		req.URL.RawQuery = query.Encode()
+	}
+}
+
+// setAuthToken sets token as the API key of req, in the header and with the scheme of the client's profile.
+func (c *Client) setAuthToken(req *http.Request, token string) {
+	header, scheme := "Authorization", "Bearer"
+	if p := c.profileFor(req); p != nil {
+		scheme = p.AuthScheme
+		if p.AuthHeader != "" {
+			header = p.AuthHeader
+		}
+	}
+	req.Header.Del("Authorization")
+	req.Header.Set(header, strings.TrimSpace(scheme+" "+token))
+}
+
+// adaptBody drops the body fields the client's profile rejects and renames the reasoning field of messages.
+func (p *ProviderProfile) adaptBody(fields map[string]json.RawMessage) error {
+	for _, field := range p.DropFields {
+		delete(fields, field)
+	}
+	if p.ReasoningField == "" || p.ReasoningField == "reasoning_content" || fields["messages"] == nil {
+		return nil
+	}
+	var messages []map[string]json.RawMessage
+	if err := json.Unmarshal(fields["messages"], &messages); err != nil {
+		return err
+	}
+	for _, m := range messages {
+		if reasoning, ok := m["reasoning_content"]; ok {
+			delete(m, "reasoning_content")
+			m[p.ReasoningField] = reasoning
+		}
+	}
+	raw, err := json.Marshal(messages)
+	if err != nil {
+		return err
+	}
+	fields["messages"] = raw
+	return nil
+}
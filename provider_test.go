@@ -0,0 +1,180 @@
+package deepseek_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/cohesion-org/deepseek-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// providerRequest is a request received by a fake provider.
+type providerRequest struct {
+	path   string
+	query  string
+	header http.Header
+	body   map[string]any
+}
+
+// fakeProvider returns a server answering chat completions with a reasoning field, and the last request it received.
+func fakeProvider(t *testing.T) (*httptest.Server, *providerRequest) {
+	t.Helper()
+	received := &providerRequest{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received.path = r.URL.Path
+		received.query = r.URL.RawQuery
+		received.header = r.Header.Clone()
+		received.body = nil
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&received.body))
+		w.Write([]byte(`{"id":"1","object":"chat.completion","model":"m","choices":[{"index":0,` +
+			`"message":{"role":"assistant","content":"hi","reasoning":"thought"},"finish_reason":"stop"}]}`))
+	}))
+	t.Cleanup(ts.Close)
+	return ts, received
+}
+
+// prefixRequest is a request with JSON mode and a prefix message carrying reasoning content.
+func prefixRequest(model string) *deepseek.ChatCompletionRequest {
+	return &deepseek.ChatCompletionRequest{
+		Model:    model,
+		JSONMode: true,
+		Messages: []deepseek.ChatCompletionMessage{
+			{Role: deepseek.ChatMessageRoleUser, Content: "hi"},
+			{Role: deepseek.ChatMessageRoleAssistant, Content: "{", ReasoningContent: "so far", Prefix: true},
+		},
+	}
+}
+
+func TestProviderOpenRouter(t *testing.T) {
+	ts, received := fakeProvider(t)
+	client, err := deepseek.NewClientWithOptions("key",
+		deepseek.WithProvider(deepseek.OpenRouter{Referer: "https://example.com", Title: "app"}))
+	require.NoError(t, err)
+	assert.Equal(t, "https://openrouter.ai/api/v1/", client.BaseURL)
+	client.BaseURL = ts.URL + "/"
+
+	resp, err := client.CreateChatCompletion(context.Background(), prefixRequest(deepseek.OpenRouterDeepSeekR1))
+	require.NoError(t, err)
+	assert.Equal(t, "thought", resp.Choices[0].Message.ReasoningContent)
+
+	assert.Equal(t, "/chat/completions", received.path)
+	assert.Equal(t, "Bearer key", received.header.Get("Authorization"))
+	assert.Equal(t, "https://example.com", received.header.Get("HTTP-Referer"))
+	assert.Equal(t, "app", received.header.Get("X-Title"))
+	assert.NotContains(t, received.body, "json")
+	prefix := received.body["messages"].([]any)[1].(map[string]any)
+	assert.Equal(t, "so far", prefix["reasoning"])
+	assert.NotContains(t, prefix, "reasoning_content")
+}
+
+func TestProviderAzure(t *testing.T) {
+	ts, received := fakeProvider(t)
+
+	client, err := deepseek.NewClientWithOptions("key", deepseek.WithProvider(deepseek.Azure{Endpoint: ts.URL + "/"}))
+	require.NoError(t, err)
+	_, err = client.CreateChatCompletion(context.Background(), prefixRequest(deepseek.AzureDeepSeekR1))
+	require.NoError(t, err)
+	assert.Equal(t, "/models/chat/completions", received.path)
+	assert.Equal(t, "api-version="+deepseek.AzureDefaultAPIVersion, received.query)
+	assert.Equal(t, "key", received.header.Get("api-key"))
+	assert.Empty(t, received.header.Get("Authorization"))
+	assert.NotContains(t, received.body, "json")
+	assert.Equal(t, "so far", received.body["messages"].([]any)[1].(map[string]any)["reasoning_content"])
+
+	client, err = deepseek.NewClientWithOptions("key",
+		deepseek.WithProvider(deepseek.Azure{Endpoint: ts.URL, Deployment: "my-r1", APIVersion: "2025-01-01"}),
+		deepseek.WithExtraHeaders(map[string]string{"api-key": "override"}))
+	require.NoError(t, err)
+	_, err = client.CreateChatCompletion(context.Background(), prefixRequest(deepseek.AzureDeepSeekR1))
+	require.NoError(t, err)
+	assert.Equal(t, "/openai/deployments/my-r1/chat/completions", received.path)
+	assert.Equal(t, "api-version=2025-01-01", received.query)
+	assert.Equal(t, "override", received.header.Get("api-key"), "extra headers win over the profile")
+
+	_, err = deepseek.NewClientWithOptions("key", deepseek.WithProvider(deepseek.Azure{}))
+	require.Error(t, err)
+}
+
+func TestProviderSiliconFlow(t *testing.T) {
+	ts, received := fakeProvider(t)
+	client, err := deepseek.NewClientWithOptions("key", deepseek.WithProvider(deepseek.SiliconFlow{}))
+	require.NoError(t, err)
+	assert.Equal(t, "https://api.siliconflow.cn/v1/", client.BaseURL)
+	client.BaseURL = ts.URL + "/"
+
+	_, err = client.CreateChatCompletion(context.Background(), prefixRequest("deepseek-ai/DeepSeek-R1"))
+	require.NoError(t, err)
+	assert.Equal(t, "/chat/completions", received.path)
+	assert.Equal(t, "Bearer key", received.header.Get("Authorization"))
+	assert.NotContains(t, received.body, "json")
+}
+
+func TestProviderLocalServers(t *testing.T) {
+	ts, received := fakeProvider(t)
+	for _, provider := range []deepseek.Provider{deepseek.VLLM{BaseURL: ts.URL + "/v1"}, deepseek.OllamaOpenAI{BaseURL: ts.URL + "/v1/"}} {
+		client, err := deepseek.NewClientWithOptions("key", deepseek.WithProvider(provider))
+		require.NoError(t, err)
+		request := prefixRequest("qwen3")
+		request.ExtraBody = map[string]any{"guided_json": map[string]any{"type": "object"}}
+		_, err = client.CreateChatCompletion(context.Background(), request)
+		require.NoError(t, err)
+		assert.Equal(t, "/v1/chat/completions", received.path)
+		assert.Equal(t, "Bearer key", received.header.Get("Authorization"))
+		assert.NotContains(t, received.body, "json")
+		assert.Equal(t, map[string]any{"type": "object"}, received.body["guided_json"])
+	}
+
+	client, err := deepseek.NewClientWithOptions("key", deepseek.WithProvider(deepseek.VLLM{}))
+	require.NoError(t, err)
+	assert.Equal(t, "http://localhost:8000/v1/", client.BaseURL)
+	client, err = deepseek.NewClientWithOptions("key", deepseek.WithProvider(deepseek.OllamaOpenAI{}))
+	require.NoError(t, err)
+	assert.Equal(t, "http://localhost:11434/v1/", client.BaseURL)
+}
+
+// recordingDoer answers every request with an empty model list and records the requests it received.
+type recordingDoer struct {
+	requests []*http.Request
+}
+
+func (d *recordingDoer) Do(req *http.Request) (*http.Response, error) {
+	d.requests = append(d.requests, req)
+	return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(`{"object":"list","data":[]}`)), Header: http.Header{}}, nil
+}
+
+func TestProviderDeepSeekOnlyEndpoints(t *testing.T) {
+	doer := &recordingDoer{}
+	client, err := deepseek.NewClientWithOptions("key",
+		deepseek.WithProvider(deepseek.Azure{Endpoint: "https://example.services.ai.azure.com"}),
+		deepseek.WithHTTPClient(doer))
+	require.NoError(t, err)
+
+	_, err = deepseek.GetBalance(client, context.Background())
+	assert.ErrorIs(t, err, deepseek.ErrUnsupportedByProvider)
+	_, err = client.CreateFIMCompletion(context.Background(), &deepseek.FIMCompletionRequest{Model: deepseek.DeepSeekChat, Prompt: "func"})
+	assert.ErrorIs(t, err, deepseek.ErrUnsupportedByProvider)
+	_, err = client.CreateFIMStreamCompletion(context.Background(), &deepseek.FIMStreamCompletionRequest{Model: deepseek.DeepSeekChat, Prompt: "func"})
+	assert.ErrorIs(t, err, deepseek.ErrUnsupportedByProvider)
+	assert.Empty(t, doer.requests, "DeepSeek-only endpoints are not sent to the provider")
+
+	_, err = deepseek.ListAllModels(client, context.Background())
+	require.NoError(t, err)
+	require.Len(t, doer.requests, 1)
+	assert.Equal(t, "https://example.services.ai.azure.com/models/models?api-version="+deepseek.AzureDefaultAPIVersion, doer.requests[0].URL.String())
+	assert.Equal(t, "key", doer.requests[0].Header.Get("api-key"))
+
+	req, err := http.NewRequest(http.MethodGet, "https://api.deepseek.com/user/balance", nil)
+	require.NoError(t, err)
+	resp, err := deepseek.HandleNormalRequest(*client, req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	sent := doer.requests[1]
+	assert.Empty(t, sent.Header.Get("api-key"), "the profile's auth header is not sent to DeepSeek")
+	assert.Empty(t, sent.URL.RawQuery, "the profile's query is not sent to DeepSeek")
+}
@@ -0,0 +1,560 @@
+package deepseek
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+)
+
+// ChatMessage is a chat message whose content is either text or a list of multimodal parts.
+type ChatMessage struct {
+	Role             string        `json:"role"`                        // The role of the message sender, e.g. "user", "assistant", "system", "tool".
+	Content          string        `json:"-"`                           // Text content. Sent when Parts is empty.
+	Parts            []ContentItem `json:"-"`                           // Multimodal content, such as text and images. Sent instead of Content if not empty.
+	Prefix           bool          `json:"prefix,omitempty"`            // Whether the message is the prefix for Chat Prefix Completion [Beta Feature].
+	ReasoningContent string        `json:"reasoning_content,omitempty"` // Reasoning content of a prefix message for the reasoner model.
+	ToolCallID       string        `json:"tool_call_id,omitempty"`      // Tool call that this message is responding to.
+	ToolCalls        []ToolCall    `json:"tool_calls,omitempty"`        // Tool calls made by the assistant.
+}
+
+// chatMessageJSON is the wire form of a ChatMessage.
+type chatMessageJSON struct {
+	chatMessageAlias
+	Content json.RawMessage `json:"content"`
+}
+
+// chatMessageAlias is ChatMessage without its JSON methods.
+type chatMessageAlias ChatMessage
+
+// MarshalJSON sends the content as a string, or as an array of parts if the message has any.
+func (m ChatMessage) MarshalJSON() ([]byte, error) {
+	var content any = m.Content
+	if len(m.Parts) > 0 {
+		content = m.Parts
+	}
+	raw, err := json.Marshal(content)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(chatMessageJSON{chatMessageAlias: chatMessageAlias(m), Content: raw})
+}
+
+// UnmarshalJSON accepts the content as a string or as an array of parts.
+func (m *ChatMessage) UnmarshalJSON(data []byte) error {
+	var wire chatMessageJSON
+	if err := json.Unmarshal(data, &wire); err != nil {
+		return err
+	}
+	*m = ChatMessage(wire.chatMessageAlias)
+	if len(wire.Content) == 0 || string(wire.Content) == "null" {
+		return nil
+	}
+	if wire.Content[0] == '[' {
+		return json.Unmarshal(wire.Content, &m.Parts)
+	}
+	return json.Unmarshal(wire.Content, &m.Content)
+}
+
+// hasImage reports whether the message has an image part.
+func (m ChatMessage) hasImage() bool {
+	return slices.ContainsFunc(m.Parts, func(item ContentItem) bool { return item.Type == "image_url" })
+}
+
+// ChatRequest is a chat completion request covering text, image and streaming calls.
+// ChatCompletionRequest, StreamChatCompletionRequest and their image variants are converted to it before
+// they are sent, so all of them produce the same request body.
+type ChatRequest struct {
+	Model            string          `json:"model"`                       // The ID of the model to use (required).
+	Messages         []ChatMessage   `json:"messages"`                    // A list of messages comprising the conversation (required).
+	FrequencyPenalty float32         `json:"frequency_penalty,omitempty"` // Penalty for new tokens based on their frequency in the text so far, >= -2 and <= 2.
+	MaxTokens        int             `json:"max_tokens,omitempty"`        // The maximum number of tokens to generate.
+	PresencePenalty  float32         `json:"presence_penalty,omitempty"`  // Penalty for new tokens based on their presence in the text so far, >= -2 and <= 2.
+	Temperature      float32         `json:"temperature,omitempty"`       // The sampling temperature, between 0 and 2.
+	TopP             float32         `json:"top_p,omitempty"`             // The nucleus sampling parameter, between 0 and 1.
+	ResponseFormat   *ResponseFormat `json:"response_format,omitempty"`   // The desired response format.
+	Stop             []string        `json:"stop,omitempty"`              // Sequences where the model stops generating further tokens.
+	Tools            []Tool          `json:"tools,omitempty"`             // Tools the model may call.
+	ToolChoice       interface{}     `json:"tool_choice,omitempty"`       // Controls which (if any) tool is called by the model.
+	LogProbs         bool            `json:"logprobs,omitempty"`          // Whether to return log probabilities of the output tokens.
+	TopLogProbs      int             `json:"top_logprobs,omitempty"`      // The number of most likely tokens to return log probabilities for, <= 20.
+	JSONMode         bool            `json:"json,omitempty"`              // [deepseek-go feature] Enable JSON mode.
+	EnableThinking   bool            `json:"enable_thinking,omitempty"`   // Enable thinking (for qwen3 api).
+	Stream           bool            `json:"stream,omitempty"`            // Set by the streaming methods.
+	StreamOptions    *StreamOptions  `json:"stream_options,omitempty"`    // Options of a streaming call. Not sent if nil.
+	ExtraBody        map[string]any  `json:"-"`                           // Provider-specific fields merged into the request body. See WithExtraBody.
+}
+
+// hasParts reports whether any message has multimodal content.
+func (r *ChatRequest) hasParts() bool {
+	return slices.ContainsFunc(r.Messages, func(m ChatMessage) bool { return len(m.Parts) > 0 })
+}
+
+// The conversions between ChatRequest and the legacy request types use unkeyed composite literals, so a field
+// added to either side fails to compile until it is converted here.
+
+// ToChatRequest converts the request to a ChatRequest.
+func (r *ChatCompletionRequest) ToChatRequest() *ChatRequest {
+	return &ChatRequest{
+		r.Model,
+		textToChatMessages(r.Messages),
+		r.FrequencyPenalty,
+		r.MaxTokens,
+		r.PresencePenalty,
+		r.Temperature,
+		r.TopP,
+		r.ResponseFormat,
+		r.Stop,
+		r.Tools,
+		r.ToolChoice,
+		r.LogProbs,
+		r.TopLogProbs,
+		r.JSONMode,
+		r.EnableThinking,
+		false,
+		nil,
+		r.ExtraBody,
+	}
+}
+
+// ToChatRequest converts the request to a ChatRequest.
+func (r *StreamChatCompletionRequest) ToChatRequest() *ChatRequest {
+	options := r.StreamOptions
+	return &ChatRequest{
+		r.Model,
+		textToChatMessages(r.Messages),
+		r.FrequencyPenalty,
+		r.MaxTokens,
+		r.PresencePenalty,
+		r.Temperature,
+		r.TopP,
+		r.ResponseFormat,
+		r.Stop,
+		r.Tools,
+		r.ToolChoice,
+		r.LogProbs,
+		r.TopLogProbs,
+		r.JSONMode,
+		r.EnableThinking,
+		r.Stream,
+		&options,
+		r.ExtraBody,
+	}
+}
+
+// ToChatRequest converts the request to a ChatRequest.
+func (r *ChatCompletionRequestWithImage) ToChatRequest() *ChatRequest {
+	return &ChatRequest{
+		r.Model,
+		imageToChatMessages(r.Messages),
+		r.FrequencyPenalty,
+		r.MaxTokens,
+		r.PresencePenalty,
+		r.Temperature,
+		r.TopP,
+		r.ResponseFormat,
+		r.Stop,
+		r.Tools,
+		r.ToolChoice,
+		r.LogProbs,
+		r.TopLogProbs,
+		r.JSONMode,
+		r.EnableThinking,
+		false,
+		nil,
+		r.ExtraBody,
+	}
+}
+
+// ToChatRequest converts the request to a ChatRequest.
+func (r *StreamChatCompletionRequestWithImage) ToChatRequest() *ChatRequest {
+	options := r.StreamOptions
+	return &ChatRequest{
+		r.Model,
+		imageToChatMessages(r.Messages),
+		r.FrequencyPenalty,
+		r.MaxTokens,
+		r.PresencePenalty,
+		r.Temperature,
+		r.TopP,
+		r.ResponseFormat,
+		r.Stop,
+		r.Tools,
+		r.ToolChoice,
+		r.LogProbs,
+		r.TopLogProbs,
+		r.JSONMode,
+		r.EnableThinking,
+		r.Stream,
+		&options,
+		r.ExtraBody,
+	}
+}
+
+// chatCompletionRequest converts a request without multimodal content to a ChatCompletionRequest.
+func (r *ChatRequest) chatCompletionRequest() *ChatCompletionRequest {
+	return &ChatCompletionRequest{
+		r.Model,
+		chatToTextMessages(r.Messages),
+		r.FrequencyPenalty,
+		r.MaxTokens,
+		r.PresencePenalty,
+		r.Temperature,
+		r.TopP,
+		r.ResponseFormat,
+		r.Stop,
+		r.Tools,
+		r.ToolChoice,
+		r.LogProbs,
+		r.TopLogProbs,
+		r.JSONMode,
+		r.EnableThinking,
+		r.ExtraBody,
+	}
+}
+
+// streamChatCompletionRequest converts a request without multimodal content to a StreamChatCompletionRequest.
+func (r *ChatRequest) streamChatCompletionRequest() *StreamChatCompletionRequest {
+	return &StreamChatCompletionRequest{
+		true,
+		r.streamOptions(),
+		r.Model,
+		chatToTextMessages(r.Messages),
+		r.FrequencyPenalty,
+		r.MaxTokens,
+		r.PresencePenalty,
+		r.Temperature,
+		r.TopP,
+		r.ResponseFormat,
+		r.Stop,
+		r.Tools,
+		r.ToolChoice,
+		r.LogProbs,
+		r.TopLogProbs,
+		r.JSONMode,
+		r.EnableThinking,
+		r.ExtraBody,
+	}
+}
+
+// chatCompletionRequestWithImage converts the request to a ChatCompletionRequestWithImage.
+func (r *ChatRequest) chatCompletionRequestWithImage() *ChatCompletionRequestWithImage {
+	return &ChatCompletionRequestWithImage{
+		r.Model,
+		chatToImageMessages(r.Messages),
+		r.FrequencyPenalty,
+		r.MaxTokens,
+		r.PresencePenalty,
+		r.Temperature,
+		r.TopP,
+		r.ResponseFormat,
+		r.Stop,
+		r.Tools,
+		r.ToolChoice,
+		r.LogProbs,
+		r.TopLogProbs,
+		r.JSONMode,
+		r.EnableThinking,
+		r.ExtraBody,
+	}
+}
+
+// streamChatCompletionRequestWithImage converts the request to a StreamChatCompletionRequestWithImage.
+func (r *ChatRequest) streamChatCompletionRequestWithImage() *StreamChatCompletionRequestWithImage {
+	return &StreamChatCompletionRequestWithImage{
+		true,
+		r.streamOptions(),
+		r.Model,
+		chatToImageMessages(r.Messages),
+		r.FrequencyPenalty,
+		r.MaxTokens,
+		r.PresencePenalty,
+		r.Temperature,
+		r.TopP,
+		r.ResponseFormat,
+		r.Stop,
+		r.Tools,
+		r.ToolChoice,
+		r.LogProbs,
+		r.TopLogProbs,
+		r.JSONMode,
+		r.EnableThinking,
+		r.ExtraBody,
+	}
+}
+
+// streamOptions returns the stream options of the request, the zero value if it has none.
+func (r *ChatRequest) streamOptions() StreamOptions {
+	if r.StreamOptions == nil {
+		return StreamOptions{}
+	}
+	return *r.StreamOptions
+}
+
+// textToChatMessages converts text messages to ChatMessages.
+func textToChatMessages(messages []ChatCompletionMessage) []ChatMessage {
+	out := make([]ChatMessage, len(messages))
+	for i, m := range messages {
+		out[i] = ChatMessage{
+			Role:             m.Role,
+			Content:          m.Content,
+			Prefix:           m.Prefix,
+			ReasoningContent: m.ReasoningContent,
+			ToolCallID:       m.ToolCallID,
+			ToolCalls:        m.ToolCalls,
+		}
+	}
+	return out
+}
+
+// imageToChatMessages converts image messages to ChatMessages.
+func imageToChatMessages(messages []ChatCompletionMessageWithImage) []ChatMessage {
+	out := make([]ChatMessage, len(messages))
+	for i, m := range messages {
+		out[i] = ChatMessage{
+			Role:             m.Role,
+			Prefix:           m.Prefix,
+			ReasoningContent: m.ReasoningContent,
+			ToolCallID:       m.ToolCallID,
+			ToolCalls:        m.ToolCalls,
+		}
+		out[i].Content, out[i].Parts = splitContent(m.Content)
+	}
+	return out
+}
+
+// splitContent converts the content of an image message to text or parts. Content of other types is
+// converted through its JSON form, and formatted as text if that is neither a string nor an array of parts.
+func splitContent(content any) (string, []ContentItem) {
+	switch c := content.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return c, nil
+	case []ContentItem:
+		return "", c
+	}
+	var m ChatMessage
+	if data, err := json.Marshal(map[string]any{"content": content}); err == nil && json.Unmarshal(data, &m) == nil {
+		return m.Content, m.Parts
+	}
+	return fmt.Sprint(content), nil
+}
+
+// chatToTextMessages converts ChatMessages without parts to text messages.
+func chatToTextMessages(messages []ChatMessage) []ChatCompletionMessage {
+	out := make([]ChatCompletionMessage, len(messages))
+	for i, m := range messages {
+		out[i] = ChatCompletionMessage{
+			Role:             m.Role,
+			Content:          m.Content,
+			Prefix:           m.Prefix,
+			ReasoningContent: m.ReasoningContent,
+			ToolCallID:       m.ToolCallID,
+			ToolCalls:        m.ToolCalls,
+		}
+	}
+	return out
+}
+
+// chatToImageMessages converts ChatMessages to image messages.
+func chatToImageMessages(messages []ChatMessage) []ChatCompletionMessageWithImage {
+	out := make([]ChatCompletionMessageWithImage, len(messages))
+	for i, m := range messages {
+		var content any = m.Content
+		if len(m.Parts) > 0 {
+			content = m.Parts
+		}
+		out[i] = ChatCompletionMessageWithImage{
+			Role:             m.Role,
+			Content:          content,
+			Prefix:           m.Prefix,
+			ReasoningContent: m.ReasoningContent,
+			ToolCallID:       m.ToolCallID,
+			ToolCalls:        m.ToolCalls,
+		}
+	}
+	return out
+}
+
+// ChatRequestBuilder builds a ChatRequest step by step.
+type ChatRequestBuilder struct {
+	request ChatRequest
+}
+
+// NewChatRequestBuilder starts a request to model.
+func NewChatRequestBuilder(model string) *ChatRequestBuilder {
+	return &ChatRequestBuilder{request: ChatRequest{Model: model}}
+}
+
+// AddMessage appends a message.
+func (b *ChatRequestBuilder) AddMessage(message ChatMessage) *ChatRequestBuilder {
+	b.request.Messages = append(b.request.Messages, message)
+	return b
+}
+
+// AddSystemMessage appends a system message.
+func (b *ChatRequestBuilder) AddSystemMessage(content string) *ChatRequestBuilder {
+	return b.AddMessage(ChatMessage{Role: ChatMessageRoleSystem, Content: content})
+}
+
+// AddUserMessage appends a user message.
+func (b *ChatRequestBuilder) AddUserMessage(content string) *ChatRequestBuilder {
+	return b.AddMessage(ChatMessage{Role: ChatMessageRoleUser, Content: content})
+}
+
+// AddAssistantMessage appends an assistant message.
+func (b *ChatRequestBuilder) AddAssistantMessage(content string) *ChatRequestBuilder {
+	return b.AddMessage(ChatMessage{Role: ChatMessageRoleAssistant, Content: content})
+}
+
+// AddImageMessage appends a message made of a text part followed by one image part per URL.
+// The URLs can be web URLs or data URLs as returned by ImageToBase64.
+func (b *ChatRequestBuilder) AddImageMessage(role, text string, imageURLs ...string) *ChatRequestBuilder {
+	parts := []ContentItem{{Type: "text", Text: text}}
+	for _, url := range imageURLs {
+		parts = append(parts, ContentItem{Type: "image_url", Image: &ImageContent{URL: url}})
+	}
+	return b.AddMessage(ChatMessage{Role: role, Parts: parts})
+}
+
+// AddToolResult appends the result of a tool call.
+func (b *ChatRequestBuilder) AddToolResult(toolCallID, content string) *ChatRequestBuilder {
+	return b.AddMessage(ChatMessage{Role: ChatMessageRoleTool, ToolCallID: toolCallID, Content: content})
+}
+
+// AddPrefix appends an assistant message the model continues from (Chat Prefix Completion [Beta Feature]).
+func (b *ChatRequestBuilder) AddPrefix(content string) *ChatRequestBuilder {
+	return b.AddMessage(ChatMessage{Role: ChatMessageRoleAssistant, Content: content, Prefix: true})
+}
+
+// SetTemperature sets the sampling temperature.
+func (b *ChatRequestBuilder) SetTemperature(temperature float32) *ChatRequestBuilder {
+	b.request.Temperature = temperature
+	return b
+}
+
+// SetTopP sets the nucleus sampling parameter.
+func (b *ChatRequestBuilder) SetTopP(topP float32) *ChatRequestBuilder {
+	b.request.TopP = topP
+	return b
+}
+
+// SetPresencePenalty sets the presence penalty.
+func (b *ChatRequestBuilder) SetPresencePenalty(penalty float32) *ChatRequestBuilder {
+	b.request.PresencePenalty = penalty
+	return b
+}
+
+// SetFrequencyPenalty sets the frequency penalty.
+func (b *ChatRequestBuilder) SetFrequencyPenalty(penalty float32) *ChatRequestBuilder {
+	b.request.FrequencyPenalty = penalty
+	return b
+}
+
+// SetMaxTokens sets the maximum number of tokens to generate.
+func (b *ChatRequestBuilder) SetMaxTokens(maxTokens int) *ChatRequestBuilder {
+	b.request.MaxTokens = maxTokens
+	return b
+}
+
+// SetStop sets the stop sequences.
+func (b *ChatRequestBuilder) SetStop(stop ...string) *ChatRequestBuilder {
+	b.request.Stop = stop
+	return b
+}
+
+// SetTools sets the tools the model may call.
+func (b *ChatRequestBuilder) SetTools(tools ...Tool) *ChatRequestBuilder {
+	b.request.Tools = tools
+	return b
+}
+
+// SetToolChoice sets which tool the model calls: "none", "auto", "required" or a ToolChoice.
+func (b *ChatRequestBuilder) SetToolChoice(choice interface{}) *ChatRequestBuilder {
+	b.request.ToolChoice = choice
+	return b
+}
+
+// SetResponseFormat sets the response format, "text" or "json_object".
+func (b *ChatRequestBuilder) SetResponseFormat(format string) *ChatRequestBuilder {
+	b.request.ResponseFormat = &ResponseFormat{Type: format}
+	return b
+}
+
+// SetJSONMode enables the [deepseek-go feature] JSON mode.
+func (b *ChatRequestBuilder) SetJSONMode(enabled bool) *ChatRequestBuilder {
+	b.request.JSONMode = enabled
+	return b
+}
+
+// SetLogProbs requests the log probabilities of the output tokens and of the top most likely tokens.
+func (b *ChatRequestBuilder) SetLogProbs(top int) *ChatRequestBuilder {
+	b.request.LogProbs = true
+	b.request.TopLogProbs = top
+	return b
+}
+
+// SetThinking enables thinking (for qwen3 api).
+func (b *ChatRequestBuilder) SetThinking(enabled bool) *ChatRequestBuilder {
+	b.request.EnableThinking = enabled
+	return b
+}
+
+// SetExtraBody sets a provider-specific field of the request body, overriding the typed fields.
+func (b *ChatRequestBuilder) SetExtraBody(key string, value any) *ChatRequestBuilder {
+	if b.request.ExtraBody == nil {
+		b.request.ExtraBody = make(map[string]any)
+	}
+	b.request.ExtraBody[key] = value
+	return b
+}
+
+// SetIncludeUsage sets whether a stream reports the usage in its last chunk.
+func (b *ChatRequestBuilder) SetIncludeUsage(include bool) *ChatRequestBuilder {
+	b.request.StreamOptions = &StreamOptions{IncludeUsage: include}
+	return b
+}
+
+// Build returns the request. The builder can keep being used; later changes do not affect the returned request.
+func (b *ChatRequestBuilder) Build() *ChatRequest {
+	request := b.request
+	request.Messages = slices.Clone(request.Messages)
+	request.Stop = slices.Clone(request.Stop)
+	request.Tools = slices.Clone(request.Tools)
+	request.ExtraBody = maps.Clone(request.ExtraBody)
+	if request.ResponseFormat != nil {
+		format := *request.ResponseFormat
+		request.ResponseFormat = &format
+	}
+	if request.StreamOptions != nil {
+		options := *request.StreamOptions
+		request.StreamOptions = &options
+	}
+	return &request
+}
+
+// CreateChat sends a ChatRequest and returns the generated response. Requests without multimodal content
+// go through CreateChatCompletion, others through CreateChatCompletionWithImage.
+func (c *Client) CreateChat(ctx context.Context, request *ChatRequest) (*ChatCompletionResponse, error) {
+	if request == nil {
+		return nil, fmt.Errorf("request cannot be nil")
+	}
+	if request.hasParts() {
+		return c.CreateChatCompletionWithImage(ctx, request.chatCompletionRequestWithImage())
+	}
+	return c.CreateChatCompletion(ctx, request.chatCompletionRequest())
+}
+
+// CreateChatStream sends a ChatRequest with stream = true and returns the delta. Requests without multimodal
+// content go through CreateChatCompletionStream, others through CreateChatCompletionStreamWithImage.
+func (c *Client) CreateChatStream(ctx context.Context, request *ChatRequest) (ChatCompletionStream, error) {
+	if request == nil {
+		return nil, fmt.Errorf("request cannot be nil")
+	}
+	if request.hasParts() {
+		return c.CreateChatCompletionStreamWithImage(ctx, request.streamChatCompletionRequestWithImage())
+	}
+	return c.CreateChatCompletionStream(ctx, request.streamChatCompletionRequest())
+}
@@ -72,13 +72,31 @@ func HandleNormalRequest(c Client, req *http.Request) (*http.Response, error) {
 
 // handleRequest sends the HTTP request using the provided HTTP client.
 // If no client is provided, it uses the default HTTP client.
+// The client's provider profile, credential provider and extra headers are applied before sending,
+// and every attempt is sent with a key of the client's key pool, if it has one.
+// Failed requests are retried when the client has a RetryPolicy.
 func (c *Client) handleRequest(req *http.Request) (*http.Response, error) {
 	client := c.HTTPClient
 	if client == nil {
 		client = http.DefaultClient
 	}
+	if c.Log != nil {
+		client = &loggingDoer{HTTPDoer: client, client: c}
+	}
+	if c.Keys != nil {
+		client = &keyPoolDoer{HTTPDoer: client, client: c}
+	}
+	c.prepareRequest(req)
+	if c.Credentials != nil && c.Keys == nil {
+		token, err := c.Credentials.Credential(req.Context())
+		if err != nil {
+			return nil, fmt.Errorf("error getting credential: %w", err)
+		}
+		c.setAuthToken(req, token)
+	}
+	c.setExtraHeaders(req)
 
-	resp, err := client.Do(req)
+	resp, err := c.doWithRetry(client, req)
 	if err != nil {
 		return nil, fmt.Errorf("error sending request: %w", err)
 	}
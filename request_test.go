@@ -0,0 +1,176 @@
+package deepseek_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/cohesion-org/deepseek-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// bodyRecorder returns a server answering chat completions and streams, and the bodies it received.
+func bodyRecorder(t *testing.T) (*httptest.Server, *[]map[string]any) {
+	t.Helper()
+	var bodies []map[string]any
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		bodies = append(bodies, body)
+		if body["stream"] == true {
+			fmt.Fprint(w, "data: {\"id\":\"s\",\"choices\":[{\"index\":0,\"delta\":{\"content\":\"hi\"}}]}\n\n")
+			fmt.Fprint(w, "data: [DONE]\n\n")
+			return
+		}
+		w.Write([]byte(retryChatResponse))
+	}))
+	t.Cleanup(ts.Close)
+	return ts, &bodies
+}
+
+func TestChatRequestBuilder(t *testing.T) {
+	builder := deepseek.NewChatRequestBuilder(deepseek.DeepSeekChat).
+		AddSystemMessage("be brief").
+		AddUserMessage("weather?").
+		AddMessage(deepseek.ChatMessage{Role: deepseek.ChatMessageRoleAssistant, ToolCalls: []deepseek.ToolCall{cityCall("call_1", "Paris")}}).
+		AddToolResult("call_1", "sunny").
+		AddPrefix("It is").
+		SetTemperature(0.5).
+		SetMaxTokens(100).
+		SetToolChoice("auto").
+		SetLogProbs(3).
+		SetIncludeUsage(true)
+	request := builder.Build()
+	require.NoError(t, request.Validate())
+	assert.Len(t, request.Messages, 5)
+
+	builder.AddUserMessage("more")
+	assert.Len(t, request.Messages, 5, "built requests do not change with the builder")
+
+	data, err := json.Marshal(request)
+	require.NoError(t, err)
+	var body map[string]any
+	require.NoError(t, json.Unmarshal(data, &body))
+	assert.Equal(t, "auto", body["tool_choice"])
+	assert.Equal(t, map[string]any{"include_usage": true}, body["stream_options"])
+	messages := body["messages"].([]any)
+	assert.Equal(t, "", messages[2].(map[string]any)["content"], "tool call messages keep an empty content")
+	assert.Equal(t, true, messages[4].(map[string]any)["prefix"])
+
+	image := deepseek.NewChatRequestBuilder("vision").
+		AddImageMessage(deepseek.ChatMessageRoleUser, "what is this?", "https://example.com/a.png").
+		Build()
+	data, err = json.Marshal(image.Messages[0])
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"role":"user","content":[{"type":"text","text":"what is this?"},`+
+		`{"type":"image_url","image_url":{"url":"https://example.com/a.png"}}]}`, string(data))
+
+	var decoded deepseek.ChatMessage
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, image.Messages[0], decoded)
+	require.NoError(t, json.Unmarshal([]byte(`{"role":"user","content":"hi"}`), &decoded))
+	assert.Equal(t, deepseek.ChatMessage{Role: deepseek.ChatMessageRoleUser, Content: "hi"}, decoded)
+}
+
+func TestClientCreateChat(t *testing.T) {
+	ts, bodies := bodyRecorder(t)
+	client, err := deepseek.NewClientWithOptions("token", deepseek.WithBaseURL(ts.URL+"/"))
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	_, err = client.CreateChat(ctx, deepseek.NewChatRequestBuilder(deepseek.DeepSeekChat).AddUserMessage("hi").SetThinking(true).Build())
+	require.NoError(t, err)
+	stream, err := client.CreateChatStream(ctx, deepseek.NewChatRequestBuilder(deepseek.DeepSeekChat).
+		AddImageMessage(deepseek.ChatMessageRoleUser, "what is this?", "https://example.com/a.png").
+		SetToolChoice("none").
+		Build())
+	require.NoError(t, err)
+	_, err = deepseek.CollectStream(stream)
+	require.NoError(t, err)
+
+	require.Len(t, *bodies, 2)
+	assert.Equal(t, true, (*bodies)[0]["enable_thinking"])
+	assert.Equal(t, "hi", (*bodies)[0]["messages"].([]any)[0].(map[string]any)["content"])
+	assert.NotContains(t, (*bodies)[0], "stream")
+
+	assert.Equal(t, true, (*bodies)[1]["stream"])
+	assert.Equal(t, "none", (*bodies)[1]["tool_choice"])
+	assert.IsType(t, []any{}, (*bodies)[1]["messages"].([]any)[0].(map[string]any)["content"])
+}
+
+func TestLegacyRequestsShareTheRequestBody(t *testing.T) {
+	ts, bodies := bodyRecorder(t)
+	client, err := deepseek.NewClientWithOptions("token", deepseek.WithBaseURL(ts.URL+"/"))
+	require.NoError(t, err)
+	ctx := context.Background()
+	messages := []deepseek.ChatCompletionMessage{{Role: deepseek.ChatMessageRoleUser, Content: "hi"}}
+
+	_, err = client.CreateChatCompletion(ctx, &deepseek.ChatCompletionRequest{
+		Model: deepseek.DeepSeekChat, Messages: messages, ToolChoice: "auto",
+	})
+	require.NoError(t, err)
+	stream, err := client.CreateChatCompletionStream(ctx, &deepseek.StreamChatCompletionRequest{
+		Model: deepseek.DeepSeekChat, Messages: messages, ToolChoice: "auto",
+	})
+	require.NoError(t, err)
+	_, err = deepseek.CollectStream(stream)
+	require.NoError(t, err)
+
+	stream, err = client.CreateChatStream(ctx, deepseek.NewChatRequestBuilder(deepseek.DeepSeekChat).
+		AddUserMessage("hi").
+		SetIncludeUsage(false).
+		Build())
+	require.NoError(t, err)
+	_, err = deepseek.CollectStream(stream)
+	require.NoError(t, err)
+
+	require.Len(t, *bodies, 3)
+	assert.Equal(t, map[string]any{"include_usage": false}, (*bodies)[1]["stream_options"])
+	assert.Equal(t, map[string]any{"include_usage": false}, (*bodies)[2]["stream_options"], "an explicit false is sent unchanged")
+	delete((*bodies)[1], "stream")
+	delete((*bodies)[1], "stream_options")
+	assert.Equal(t, (*bodies)[0], (*bodies)[1])
+}
+
+func TestLegacyRequestsMatchChatRequest(t *testing.T) {
+	chat := reflect.TypeFor[deepseek.ChatRequest]()
+	for _, legacy := range []reflect.Type{
+		reflect.TypeFor[deepseek.ChatCompletionRequest](),
+		reflect.TypeFor[deepseek.StreamChatCompletionRequest](),
+		reflect.TypeFor[deepseek.ChatCompletionRequestWithImage](),
+		reflect.TypeFor[deepseek.StreamChatCompletionRequestWithImage](),
+	} {
+		for i := 0; i < legacy.NumField(); i++ {
+			field := legacy.Field(i)
+			if field.Name == "Messages" || field.Name == "StreamOptions" {
+				continue // converted explicitly
+			}
+			match, ok := chat.FieldByName(field.Name)
+			if assert.True(t, ok, "%s.%s is missing from ChatRequest", legacy.Name(), field.Name) {
+				assert.Equal(t, field.Type, match.Type, "%s.%s", legacy.Name(), field.Name)
+			}
+		}
+	}
+
+	request := deepseek.NewChatRequestBuilder(deepseek.DeepSeekChat).
+		AddUserMessage("hi").
+		SetTemperature(0.5).
+		SetStop("end").
+		SetExtraBody("top_k", 40).
+		SetIncludeUsage(true).
+		Build()
+	stream := &deepseek.StreamChatCompletionRequest{
+		Model:         deepseek.DeepSeekChat,
+		Messages:      []deepseek.ChatCompletionMessage{{Role: deepseek.ChatMessageRoleUser, Content: "hi"}},
+		Temperature:   0.5,
+		Stop:          []string{"end"},
+		ExtraBody:     map[string]any{"top_k": 40},
+		StreamOptions: deepseek.StreamOptions{IncludeUsage: true},
+	}
+	assert.Equal(t, request, stream.ToChatRequest())
+}
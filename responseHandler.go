@@ -17,6 +17,7 @@ type ChatCompletionResponse struct {
 	Choices           []Choice `json:"choices"`                      // List of completion choices generated by the model.
 	Usage             Usage    `json:"usage"`                        // Token usage statistics.
 	SystemFingerprint *string  `json:"system_fingerprint,omitempty"` // Fingerprint of the system configuration.
+	Backend           string   `json:"-"`                            // Name of the backend that served the response, set by FailoverClient.
 }
 
 // Choice represents a completion choice generated by the model.
@@ -97,6 +98,8 @@ type Usage struct {
 	TotalTokens           int `json:"total_tokens"`             // Total number of tokens used.
 	PromptCacheHitTokens  int `json:"prompt_cache_hit_tokens"`  // Number of tokens served from cache.
 	PromptCacheMissTokens int `json:"prompt_cache_miss_tokens"` // Number of tokens not served from cache.
+
+	CompletionTokensDetails CompletionTokensDetails `json:"completion_tokens_details"` // Breakdown of the completion tokens.
 }
 
 // HandleChatCompletionResponse parses the response from the chat completion endpoint.
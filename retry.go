@@ -0,0 +1,188 @@
+package deepseek
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy configures how the client retries failed requests.
+type RetryPolicy struct {
+	MaxAttempts          int           // Total number of attempts including the first one. Values <= 1 disable retries.
+	InitialBackoff       time.Duration // Backoff before the first retry.
+	MaxBackoff           time.Duration // Upper bound for a single backoff, including one taken from Retry-After.
+	Multiplier           float64       // Factor the backoff grows by after every attempt. Defaults to 2.
+	Jitter               float64       // Fraction of the backoff (0 to 1) that is randomized.
+	RetryableStatusCodes []int         // HTTP status codes that are retried. Transport errors are always retried.
+}
+
+// DefaultRetryPolicy returns a retry policy with 3 attempts that retries 429, 500, 502, 503 and 504.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: 500 * time.Millisecond,
+		MaxBackoff:     30 * time.Second,
+		Multiplier:     2,
+		Jitter:         0.5,
+		RetryableStatusCodes: []int{
+			http.StatusTooManyRequests,
+			http.StatusInternalServerError,
+			http.StatusBadGateway,
+			http.StatusServiceUnavailable,
+			http.StatusGatewayTimeout,
+		},
+	}
+}
+
+// WithRetryPolicy enables automatic retries for every request sent by the client,
+// including the initial connection of streaming calls.
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(c *Client) error {
+		if policy.MaxAttempts < 0 {
+			return fmt.Errorf("max attempts must not be negative")
+		}
+		if policy.InitialBackoff < 0 || policy.MaxBackoff < 0 {
+			return fmt.Errorf("backoff must be a positive duration")
+		}
+		if policy.Jitter < 0 || policy.Jitter > 1 {
+			return fmt.Errorf("jitter must be between 0 and 1")
+		}
+		c.RetryPolicy = &policy
+		return nil
+	}
+}
+
+// isRetryableStatus reports whether the status code is in the retryable set.
+func (p *RetryPolicy) isRetryableStatus(code int) bool {
+	for _, c := range p.RetryableStatusCodes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+// backoff returns how long to wait before the given retry (1 for the first retry).
+func (p *RetryPolicy) backoff(retry int) time.Duration {
+	multiplier := p.Multiplier
+	if multiplier <= 0 {
+		multiplier = 2
+	}
+	d := float64(p.InitialBackoff) * math.Pow(multiplier, float64(retry-1))
+	if p.MaxBackoff > 0 && d > float64(p.MaxBackoff) {
+		d = float64(p.MaxBackoff)
+	}
+	if p.Jitter > 0 {
+		d -= d * p.Jitter * rand.Float64()
+	}
+	return time.Duration(d)
+}
+
+// parseRetryAfter parses a Retry-After header given either in seconds or as an HTTP date.
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		d := t.Sub(now)
+		if d < 0 {
+			d = 0
+		}
+		return d, true
+	}
+	return 0, false
+}
+
+// doWithRetry sends the request, retrying transport errors and retryable status codes according to the policy.
+// ErrNoAPIKeyAvailable is not retried: the keys stay benched far longer than the backoff.
+// Retries of a call that waited for the client's limiter wait for its request and token rates again.
+// The request body is replayed through req.GetBody, so requests without it are sent only once.
+func (c *Client) doWithRetry(client HTTPDoer, req *http.Request) (*http.Response, error) {
+	policy := c.RetryPolicy
+	if policy == nil || policy.MaxAttempts <= 1 || (req.Body != nil && req.Body != http.NoBody && req.GetBody == nil) {
+		return client.Do(req)
+	}
+
+	ctx := req.Context()
+	for attempt := 1; ; attempt++ {
+		attemptReq := req
+		if attempt > 1 {
+			attemptReq = req.Clone(ctx)
+			if req.GetBody != nil {
+				body, err := req.GetBody()
+				if err != nil {
+					return nil, fmt.Errorf("error replaying request body: %w", err)
+				}
+				attemptReq.Body = body
+			}
+		}
+
+		resp, err := client.Do(attemptReq)
+		if attempt >= policy.MaxAttempts || ctx.Err() != nil {
+			return resp, err
+		}
+		if err == nil && !policy.isRetryableStatus(resp.StatusCode) {
+			return resp, nil
+		}
+		if errors.Is(err, ErrNoAPIKeyAvailable) {
+			return resp, err
+		}
+
+		wait := policy.backoff(attempt)
+		if resp != nil {
+			if retryAfter, ok := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()); ok {
+				wait = retryAfter
+				if policy.MaxBackoff > 0 && wait > policy.MaxBackoff {
+					wait = policy.MaxBackoff
+				}
+			}
+		}
+
+		// Give the caller the last response rather than sleeping past the deadline.
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < wait {
+			return resp, err
+		}
+
+		c.logRetry(ctx, attempt, wait, resp, err)
+		if resp != nil {
+			_, _ = io.Copy(io.Discard, resp.Body)
+			_ = resp.Body.Close()
+		}
+
+		if err := sleepContext(ctx, wait); err != nil {
+			return nil, err
+		}
+		if tokens, ok := ctx.Value(limiterTokensKey{}).(int); ok {
+			if _, err := c.Limiter.waitRate(ctx, tokens); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+// sleepContext waits for d or until ctx is done, whichever comes first.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,175 @@
+package deepseek_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cohesion-org/deepseek-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const retryChatResponse = `{"id":"1","object":"chat.completion","model":"deepseek-chat","choices":[{"index":0,"message":{"role":"assistant","content":"hi"},"finish_reason":"stop"}]}`
+
+func fastRetryPolicy(attempts int) deepseek.RetryPolicy {
+	policy := deepseek.DefaultRetryPolicy()
+	policy.MaxAttempts = attempts
+	policy.InitialBackoff = time.Millisecond
+	policy.MaxBackoff = 10 * time.Millisecond
+	return policy
+}
+
+func TestRetryPolicy(t *testing.T) {
+	t.Run("retries retryable status and replays body", func(t *testing.T) {
+		var calls int32
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			assert.Contains(t, string(body), "deepseek-chat")
+			if atomic.AddInt32(&calls, 1) < 3 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			w.Write([]byte(retryChatResponse))
+		}))
+		defer ts.Close()
+
+		client, err := deepseek.NewClientWithOptions("token",
+			deepseek.WithBaseURL(ts.URL+"/"),
+			deepseek.WithRetryPolicy(fastRetryPolicy(3)),
+		)
+		require.NoError(t, err)
+
+		resp, err := client.CreateChatCompletion(context.Background(), &deepseek.ChatCompletionRequest{Model: deepseek.DeepSeekChat})
+		require.NoError(t, err)
+		assert.Equal(t, "hi", resp.Choices[0].Message.Content)
+		assert.Equal(t, int32(3), atomic.LoadInt32(&calls))
+	})
+
+	t.Run("returns last error after max attempts", func(t *testing.T) {
+		var calls int32
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&calls, 1)
+			w.WriteHeader(http.StatusTooManyRequests)
+		}))
+		defer ts.Close()
+
+		client, err := deepseek.NewClientWithOptions("token",
+			deepseek.WithBaseURL(ts.URL+"/"),
+			deepseek.WithRetryPolicy(fastRetryPolicy(2)),
+		)
+		require.NoError(t, err)
+
+		_, err = client.CreateChatCompletion(context.Background(), &deepseek.ChatCompletionRequest{})
+		var apiErr *deepseek.APIError
+		require.ErrorAs(t, err, &apiErr)
+		assert.Equal(t, http.StatusTooManyRequests, apiErr.StatusCode)
+		assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+	})
+
+	t.Run("does not retry other status codes", func(t *testing.T) {
+		var calls int32
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&calls, 1)
+			w.WriteHeader(http.StatusBadRequest)
+		}))
+		defer ts.Close()
+
+		client, err := deepseek.NewClientWithOptions("token",
+			deepseek.WithBaseURL(ts.URL+"/"),
+			deepseek.WithRetryPolicy(fastRetryPolicy(3)),
+		)
+		require.NoError(t, err)
+
+		_, err = client.CreateChatCompletion(context.Background(), &deepseek.ChatCompletionRequest{})
+		require.Error(t, err)
+		assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+	})
+
+	t.Run("honors Retry-After", func(t *testing.T) {
+		var calls int32
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if atomic.AddInt32(&calls, 1) == 1 {
+				w.Header().Set("Retry-After", "1")
+				w.WriteHeader(http.StatusTooManyRequests)
+				return
+			}
+			w.Write([]byte(retryChatResponse))
+		}))
+		defer ts.Close()
+
+		policy := fastRetryPolicy(2)
+		policy.MaxBackoff = 2 * time.Second
+		client, err := deepseek.NewClientWithOptions("token",
+			deepseek.WithBaseURL(ts.URL+"/"),
+			deepseek.WithRetryPolicy(policy),
+		)
+		require.NoError(t, err)
+
+		start := time.Now()
+		_, err = client.CreateChatCompletion(context.Background(), &deepseek.ChatCompletionRequest{})
+		require.NoError(t, err)
+		assert.GreaterOrEqual(t, time.Since(start), time.Second)
+	})
+
+	t.Run("stops when the deadline is shorter than the backoff", func(t *testing.T) {
+		var calls int32
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&calls, 1)
+			w.Header().Set("Retry-After", "10")
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+		defer ts.Close()
+
+		policy := fastRetryPolicy(5)
+		policy.MaxBackoff = time.Minute
+		client, err := deepseek.NewClientWithOptions("token",
+			deepseek.WithBaseURL(ts.URL+"/"),
+			deepseek.WithTimeout(time.Second),
+			deepseek.WithRetryPolicy(policy),
+		)
+		require.NoError(t, err)
+
+		_, err = client.CreateChatCompletion(context.Background(), &deepseek.ChatCompletionRequest{})
+		require.Error(t, err)
+		assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+	})
+
+	t.Run("covers streaming connections", func(t *testing.T) {
+		var calls int32
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if atomic.AddInt32(&calls, 1) == 1 {
+				w.WriteHeader(http.StatusBadGateway)
+				return
+			}
+			w.Write([]byte("data: {\"id\":\"1\",\"choices\":[{\"index\":0,\"delta\":{\"content\":\"hi\"}}]}\n\ndata: [DONE]\n\n"))
+		}))
+		defer ts.Close()
+
+		client, err := deepseek.NewClientWithOptions("token",
+			deepseek.WithBaseURL(ts.URL+"/"),
+			deepseek.WithRetryPolicy(fastRetryPolicy(2)),
+		)
+		require.NoError(t, err)
+
+		stream, err := client.CreateChatCompletionStream(context.Background(), &deepseek.StreamChatCompletionRequest{})
+		require.NoError(t, err)
+		defer stream.Close()
+
+		chunk, err := stream.Recv()
+		require.NoError(t, err)
+		assert.Equal(t, "hi", chunk.Choices[0].Delta.Content)
+		assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+	})
+
+	t.Run("rejects invalid policy", func(t *testing.T) {
+		policy := deepseek.DefaultRetryPolicy()
+		policy.Jitter = 2
+		_, err := deepseek.NewClientWithOptions("token", deepseek.WithRetryPolicy(policy))
+		require.Error(t, err)
+	})
+}
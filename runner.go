@@ -0,0 +1,216 @@
+package deepseek
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrMaxIterations is returned by ToolRunner when the model still requests tool calls after the maximum number of iterations.
+var ErrMaxIterations = errors.New("tool runner reached the maximum number of iterations")
+
+// ToolRunner calls the model, executes the tool calls it requests with a ToolRegistry and sends the results back
+// until the model answers without tool calls.
+type ToolRunner struct {
+	Client        *Client       // Client used to call the model (required).
+	Registry      *ToolRegistry // Registry the tool calls are dispatched to (required).
+	MaxIterations int           // Maximum number of model calls per run. Defaults to 10.
+	Parallel      bool          // Run the tool calls of one turn concurrently.
+	ToolTimeout   time.Duration // Timeout for a single tool call. Zero means no timeout.
+}
+
+// ToolResult is the outcome of a single tool call.
+type ToolResult struct {
+	Call     ToolCall      // The tool call requested by the model.
+	Content  string        // Content sent back to the model. Errors are encoded with ToolErrorContent.
+	Err      error         // Error returned by the tool, if any.
+	Duration time.Duration // Time the tool took.
+}
+
+// RunStep is one model call of a run and the tool calls it triggered.
+type RunStep struct {
+	Response    *ChatCompletionResponse // The model response.
+	ToolResults []ToolResult            // Results of the tool calls requested in the response, in request order.
+}
+
+// RunResult is the transcript of a run.
+type RunResult struct {
+	Messages []ChatCompletionMessage // Full message history, including assistant and tool messages.
+	Steps    []RunStep               // Every model call in order.
+	Response *ChatCompletionResponse // The final response without tool calls. Nil if the run did not finish.
+}
+
+// NewToolRunner creates a ToolRunner with the default settings.
+func NewToolRunner(client *Client, registry *ToolRegistry) *ToolRunner {
+	return &ToolRunner{Client: client, Registry: registry, MaxIterations: 10}
+}
+
+// Run executes the tool loop with the blocking chat completion API. If the request has no tools,
+// the registry's tools are used. The request itself is not modified.
+// On error the transcript collected so far is returned together with the error.
+func (r *ToolRunner) Run(ctx context.Context, request *ChatCompletionRequest) (*RunResult, error) {
+	if request == nil {
+		return nil, fmt.Errorf("request cannot be nil")
+	}
+	req := *request
+	if len(req.Tools) == 0 {
+		req.Tools = r.Registry.Tools()
+	}
+	return r.run(ctx, req.Model, request.Messages, func(ctx context.Context, messages []ChatCompletionMessage) (*ChatCompletionResponse, error) {
+		req.Messages = messages
+		return r.Client.CreateChatCompletion(ctx, &req)
+	})
+}
+
+// RunStream executes the tool loop with the streaming chat completion API. Every chunk of every model call
+// is passed to onChunk, which may be nil. Returning an error from onChunk stops the run.
+func (r *ToolRunner) RunStream(ctx context.Context, request *StreamChatCompletionRequest, onChunk ChatStreamHook) (*RunResult, error) {
+	if request == nil {
+		return nil, fmt.Errorf("request cannot be nil")
+	}
+	req := *request
+	if len(req.Tools) == 0 {
+		req.Tools = r.Registry.Tools()
+	}
+	return r.run(ctx, req.Model, request.Messages, func(ctx context.Context, messages []ChatCompletionMessage) (*ChatCompletionResponse, error) {
+		req.Messages = messages
+		stream, err := r.Client.CreateChatCompletionStream(ctx, &req)
+		if err != nil {
+			return nil, err
+		}
+		acc := NewChatCompletionAccumulator()
+		for chunk, err := range StreamAll(stream) {
+			if err != nil {
+				return nil, err
+			}
+			if onChunk != nil {
+				if err := onChunk(chunk); err != nil {
+					return nil, err
+				}
+			}
+			acc.Add(chunk)
+		}
+		return acc.Response(), nil
+	})
+}
+
+// run is the loop shared by Run and RunStream. The messages passed to send are prepared for the model,
+// while the transcript keeps them as received.
+func (r *ToolRunner) run(
+	ctx context.Context,
+	model string,
+	initial []ChatCompletionMessage,
+	send func(ctx context.Context, messages []ChatCompletionMessage) (*ChatCompletionResponse, error),
+) (*RunResult, error) {
+	if r.Client == nil || r.Registry == nil {
+		return nil, fmt.Errorf("tool runner needs a client and a registry")
+	}
+	maxIterations := r.MaxIterations
+	if maxIterations <= 0 {
+		maxIterations = 10
+	}
+
+	capabilities, _ := r.Client.modelRegistry().Lookup(model)
+
+	result := &RunResult{Messages: append([]ChatCompletionMessage(nil), initial...)}
+	for i := 0; i < maxIterations; i++ {
+		resp, err := send(ctx, prepareMessages(capabilities, result.Messages))
+		if err != nil {
+			return result, err
+		}
+		if len(resp.Choices) == 0 {
+			return result, fmt.Errorf("no choices in response")
+		}
+		msg := resp.Choices[0].Message
+		result.Messages = append(result.Messages, ChatCompletionMessage{
+			Role:             ChatMessageRoleAssistant,
+			Content:          msg.Content,
+			ReasoningContent: msg.ReasoningContent,
+			ToolCalls:        msg.ToolCalls,
+		})
+
+		step := RunStep{Response: resp}
+		if len(msg.ToolCalls) == 0 {
+			result.Steps = append(result.Steps, step)
+			result.Response = resp
+			return result, nil
+		}
+
+		step.ToolResults = r.executeAll(ctx, msg.ToolCalls)
+		result.Steps = append(result.Steps, step)
+		for _, tr := range step.ToolResults {
+			result.Messages = append(result.Messages, ChatCompletionMessage{
+				Role:       ChatMessageRoleTool,
+				Content:    tr.Content,
+				ToolCallID: tr.Call.ID,
+			})
+		}
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+	}
+	return result, ErrMaxIterations
+}
+
+// executeAll runs the tool calls of one turn, concurrently if the runner is parallel.
+func (r *ToolRunner) executeAll(ctx context.Context, calls []ToolCall) []ToolResult {
+	results := make([]ToolResult, len(calls))
+	if !r.Parallel {
+		for i, call := range calls {
+			results[i] = r.execute(ctx, call)
+		}
+		return results
+	}
+
+	var wg sync.WaitGroup
+	for i, call := range calls {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = r.execute(ctx, call)
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+// execute runs a single tool call with the tool timeout and turns panics into errors.
+func (r *ToolRunner) execute(ctx context.Context, call ToolCall) ToolResult {
+	start := time.Now()
+	if r.ToolTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.ToolTimeout)
+		defer cancel()
+	}
+
+	type outcome struct {
+		content string
+		err     error
+	}
+	done := make(chan outcome, 1)
+	go func() {
+		defer func() {
+			if p := recover(); p != nil {
+				done <- outcome{err: fmt.Errorf("tool %q panicked: %v", call.Function.Name, p)}
+			}
+		}()
+		content, err := r.Registry.Call(ctx, call)
+		done <- outcome{content: content, err: err}
+	}()
+
+	var out outcome
+	select {
+	case out = <-done:
+	case <-ctx.Done():
+		// The tool ignored its context; report the timeout and let it finish in the background.
+		out = outcome{err: fmt.Errorf("tool %q: %w", call.Function.Name, ctx.Err())}
+	}
+
+	result := ToolResult{Call: call, Content: out.content, Err: out.err, Duration: time.Since(start)}
+	if out.err != nil {
+		result.Content = ToolErrorContent(out.err)
+	}
+	return result
+}
@@ -0,0 +1,216 @@
+package deepseek_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cohesion-org/deepseek-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type cityArgs struct {
+	City string `json:"city"`
+}
+
+// toolCallingServer asks for the given tool calls until every call has a tool message, then answers with the tool results.
+func toolCallingServer(t *testing.T, stream bool, calls ...deepseek.ToolCall) *httptest.Server {
+	t.Helper()
+	ts := httptest.NewServer(toolCallingHandler(t, stream, calls...))
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+// toolCallingHandler is the handler of toolCallingServer. Its tool call turns come with reasoning content.
+func toolCallingHandler(t *testing.T, stream bool, calls ...deepseek.ToolCall) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var body deepseek.ChatCompletionRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.NotEmpty(t, body.Tools)
+
+		var results []string
+		for _, msg := range body.Messages {
+			if msg.Role == deepseek.ChatMessageRoleTool {
+				results = append(results, msg.Content)
+			}
+		}
+
+		message := deepseek.Message{Role: deepseek.ChatMessageRoleAssistant}
+		finish := "stop"
+		if len(results) < len(calls) {
+			message.ToolCalls = calls
+			message.ReasoningContent = "need the weather"
+			finish = "tool_calls"
+		} else {
+			message.Content = strings.Join(results, "|")
+		}
+
+		if stream {
+			delta, _ := json.Marshal(map[string]any{
+				"id":      "run",
+				"choices": []any{map[string]any{"index": 0, "delta": message, "finish_reason": finish}},
+			})
+			fmt.Fprintf(w, "data: %s\n\ndata: [DONE]\n\n", delta)
+			return
+		}
+		json.NewEncoder(w).Encode(deepseek.ChatCompletionResponse{
+			ID:      "run",
+			Choices: []deepseek.Choice{{Message: message, FinishReason: finish}},
+		})
+	}
+}
+
+func cityCall(id, city string) deepseek.ToolCall {
+	return deepseek.ToolCall{ID: id, Type: "function", Function: deepseek.ToolCallFunction{
+		Name: "lookup", Arguments: fmt.Sprintf(`{"city":%q}`, city),
+	}}
+}
+
+func newRunner(t *testing.T, ts *httptest.Server, fn func(ctx context.Context, args cityArgs) (string, error)) *deepseek.ToolRunner {
+	t.Helper()
+	client, err := deepseek.NewClientWithOptions("token", deepseek.WithBaseURL(ts.URL+"/"))
+	require.NoError(t, err)
+	registry := deepseek.NewToolRegistry()
+	require.NoError(t, deepseek.RegisterTool(registry, "lookup", "Look up a city", fn))
+	return deepseek.NewToolRunner(client, registry)
+}
+
+func userRequest() *deepseek.ChatCompletionRequest {
+	return &deepseek.ChatCompletionRequest{
+		Model:    deepseek.DeepSeekChat,
+		Messages: []deepseek.ChatCompletionMessage{{Role: deepseek.ChatMessageRoleUser, Content: "weather?"}},
+	}
+}
+
+func TestToolRunner(t *testing.T) {
+	t.Run("runs tools until the model answers", func(t *testing.T) {
+		ts := toolCallingServer(t, false, cityCall("a", "Paris"), cityCall("b", "Rome"))
+		runner := newRunner(t, ts, func(ctx context.Context, args cityArgs) (string, error) {
+			return "sunny in " + args.City, nil
+		})
+
+		request := userRequest()
+		result, err := runner.Run(context.Background(), request)
+		require.NoError(t, err)
+		assert.Len(t, request.Messages, 1, "the caller's request must not be modified")
+
+		require.Len(t, result.Steps, 2)
+		require.Len(t, result.Steps[0].ToolResults, 2)
+		assert.Equal(t, "sunny in Paris", result.Steps[0].ToolResults[0].Content)
+		assert.Equal(t, "sunny in Paris|sunny in Rome", result.Response.Choices[0].Message.Content)
+
+		roles := make([]string, 0, len(result.Messages))
+		for _, msg := range result.Messages {
+			roles = append(roles, msg.Role)
+		}
+		assert.Equal(t, []string{"user", "assistant", "tool", "tool", "assistant"}, roles)
+		assert.Equal(t, "b", result.Messages[3].ToolCallID)
+	})
+
+	t.Run("max iterations", func(t *testing.T) {
+		ts := toolCallingServer(t, false, cityCall("a", "Paris"), cityCall("b", "Rome"), cityCall("c", "Oslo"))
+		runner := newRunner(t, ts, func(ctx context.Context, args cityArgs) (string, error) { return "ok", nil })
+		runner.MaxIterations = 1
+
+		result, err := runner.Run(context.Background(), userRequest())
+		require.ErrorIs(t, err, deepseek.ErrMaxIterations)
+		assert.Len(t, result.Steps, 1)
+		assert.Nil(t, result.Response)
+	})
+
+	t.Run("parallel execution", func(t *testing.T) {
+		ts := toolCallingServer(t, false, cityCall("a", "Paris"), cityCall("b", "Rome"))
+		var running, peak int32
+		runner := newRunner(t, ts, func(ctx context.Context, args cityArgs) (string, error) {
+			n := atomic.AddInt32(&running, 1)
+			defer atomic.AddInt32(&running, -1)
+			for {
+				p := atomic.LoadInt32(&peak)
+				if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+					break
+				}
+			}
+			time.Sleep(50 * time.Millisecond)
+			return args.City, nil
+		})
+		runner.Parallel = true
+
+		result, err := runner.Run(context.Background(), userRequest())
+		require.NoError(t, err)
+		assert.Equal(t, int32(2), atomic.LoadInt32(&peak))
+		assert.Equal(t, "Paris|Rome", result.Response.Choices[0].Message.Content)
+	})
+
+	t.Run("timeouts and panics are reported to the model", func(t *testing.T) {
+		ts := toolCallingServer(t, false, cityCall("a", "slow"), cityCall("b", "panic"))
+		runner := newRunner(t, ts, func(ctx context.Context, args cityArgs) (string, error) {
+			switch args.City {
+			case "slow":
+				time.Sleep(time.Second)
+				return "too late", nil
+			default:
+				panic("boom")
+			}
+		})
+		runner.ToolTimeout = 20 * time.Millisecond
+
+		result, err := runner.Run(context.Background(), userRequest())
+		require.NoError(t, err)
+		results := result.Steps[0].ToolResults
+		require.ErrorIs(t, results[0].Err, context.DeadlineExceeded)
+		assert.Contains(t, results[0].Content, "deadline exceeded")
+		require.Error(t, results[1].Err)
+		assert.Contains(t, results[1].Content, "panicked: boom")
+	})
+
+	t.Run("streaming", func(t *testing.T) {
+		ts := toolCallingServer(t, true, cityCall("a", "Paris"))
+		runner := newRunner(t, ts, func(ctx context.Context, args cityArgs) (string, error) {
+			return "sunny", nil
+		})
+
+		var chunks int
+		result, err := runner.RunStream(context.Background(), &deepseek.StreamChatCompletionRequest{
+			Model:    deepseek.DeepSeekChat,
+			Messages: userRequest().Messages,
+		}, func(chunk *deepseek.StreamChatCompletionResponse) error {
+			chunks++
+			return nil
+		})
+		require.NoError(t, err)
+		assert.Equal(t, 2, chunks)
+		assert.Len(t, result.Steps, 2)
+		assert.Equal(t, "sunny", result.Response.Choices[0].Message.Content)
+	})
+
+	t.Run("keeps reasoning in the transcript", func(t *testing.T) {
+		var sent []string
+		handler := toolCallingHandler(t, false, cityCall("a", "Paris"))
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, err := io.ReadAll(r.Body)
+			require.NoError(t, err)
+			sent = append(sent, string(body))
+			r.Body = io.NopCloser(bytes.NewReader(body))
+			handler(w, r)
+		}))
+		t.Cleanup(ts.Close)
+		runner := newRunner(t, ts, func(ctx context.Context, args cityArgs) (string, error) { return "sunny", nil })
+
+		request := userRequest()
+		request.Model = deepseek.DeepSeekReasoner
+		result, err := runner.Run(context.Background(), request)
+		require.NoError(t, err)
+		assert.Equal(t, "need the weather", result.Messages[1].ReasoningContent)
+		require.Len(t, sent, 2)
+		assert.NotContains(t, sent[1], "reasoning_content", "the reasoner rejects reasoning of earlier turns")
+	})
+}
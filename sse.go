@@ -0,0 +1,193 @@
+package deepseek
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// StreamEvent holds the server-sent event metadata of a streamed chunk.
+type StreamEvent struct {
+	Event string        // Event type from the "event:" field. Empty means the default "message" type.
+	ID    string        // Last event ID seen on the stream, from the "id:" field.
+	Retry time.Duration // Reconnection time requested by the server with the "retry:" field, if any.
+}
+
+// sseDecoder reads server-sent events as described in the WHATWG HTML specification.
+type sseDecoder struct {
+	reader      *bufio.Reader
+	lastEventID string
+	retry       time.Duration
+	skipLF      bool   // The last line ended with "\r", so a "\n" right after it belongs to the same terminator.
+	onLine      func() // Called for every line read, including comments and blank lines. May be nil.
+}
+
+// newSSEDecoder creates a decoder reading from r.
+func newSSEDecoder(r io.Reader) *sseDecoder {
+	return &sseDecoder{reader: bufio.NewReader(r)}
+}
+
+// readLine reads a line terminated by "\n", "\r\n" or "\r", without the terminator.
+// A "\r" ends the line at once, without waiting for the next byte to see whether it is a "\n".
+func (d *sseDecoder) readLine() (string, error) {
+	var line bytes.Buffer
+	for {
+		b, err := d.reader.ReadByte()
+		if err != nil {
+			if err == io.EOF && line.Len() > 0 {
+				return line.String(), nil
+			}
+			return "", err
+		}
+		skipLF := d.skipLF
+		d.skipLF = false
+		switch b {
+		case '\n':
+			if skipLF && line.Len() == 0 {
+				continue
+			}
+			return line.String(), nil
+		case '\r':
+			d.skipLF = true
+			return line.String(), nil
+		default:
+			line.WriteByte(b)
+		}
+	}
+}
+
+// next returns the next event and its data. Comments and events without data are skipped.
+// Unlike the specification, an event still pending when the stream ends is dispatched instead of discarded,
+// because some providers close the connection without a trailing blank line.
+func (d *sseDecoder) next() (*StreamEvent, string, error) {
+	var (
+		eventType string
+		data      strings.Builder
+		hasData   bool
+	)
+	dispatch := func() *StreamEvent {
+		return &StreamEvent{Event: eventType, ID: d.lastEventID, Retry: d.retry}
+	}
+
+	for {
+		line, err := d.readLine()
+		if err != nil {
+			if err == io.EOF && hasData {
+				return dispatch(), data.String(), nil
+			}
+			return nil, "", err
+		}
+		if d.onLine != nil {
+			d.onLine()
+		}
+
+		if line == "" {
+			if hasData {
+				return dispatch(), data.String(), nil
+			}
+			eventType = ""
+			continue
+		}
+		if strings.HasPrefix(line, ":") {
+			continue // comment, e.g. ": keep-alive"
+		}
+
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "data":
+			if hasData {
+				data.WriteByte('\n')
+			}
+			data.WriteString(value)
+			hasData = true
+		case "event":
+			eventType = value
+		case "id":
+			if !strings.ContainsRune(value, 0) {
+				d.lastEventID = value
+			}
+		case "retry":
+			if ms, err := strconv.Atoi(value); err == nil && ms >= 0 {
+				d.retry = time.Duration(ms) * time.Millisecond
+			}
+		}
+	}
+}
+
+// decode reads the next event and unmarshals its data into target.
+// It returns io.EOF at the end of the stream or on a "[DONE]" event, and an *APIError for in-band error events.
+func (d *sseDecoder) decode(statusCode int, target any) (*StreamEvent, error) {
+	event, data, err := d.next()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, io.EOF
+		}
+		return nil, fmt.Errorf("error reading stream: %w", err)
+	}
+	if strings.TrimSpace(data) == "[DONE]" {
+		return nil, io.EOF
+	}
+	if err := json.Unmarshal([]byte(data), target); err != nil {
+		if event.Event == "error" {
+			return event, &APIError{StatusCode: statusCode, Message: data, ResponseBody: data}
+		}
+		return event, fmt.Errorf("unmarshal error: %w, raw data: %s", err, data)
+	}
+	if carrier, ok := target.(streamErrorCarrier); ok {
+		if streamErr := carrier.streamError(); streamErr != nil {
+			return event, streamErr.apiError(statusCode, data)
+		}
+	}
+	if event.Event == "error" {
+		return event, &APIError{StatusCode: statusCode, Message: data, ResponseBody: data}
+	}
+	return event, nil
+}
+
+// StreamError is an in-band error sent in a stream chunk by providers like OpenRouter.
+// Recv returns it as an *APIError.
+type StreamError struct {
+	Code    json.RawMessage `json:"code,omitempty"`
+	Message string          `json:"message"`
+}
+
+// UnmarshalJSON accepts both an error object and a bare error message.
+func (e *StreamError) UnmarshalJSON(data []byte) error {
+	var message string
+	if err := json.Unmarshal(data, &message); err == nil {
+		*e = StreamError{Message: message}
+		return nil
+	}
+	type alias StreamError
+	return json.Unmarshal(data, (*alias)(e))
+}
+
+// streamErrorCarrier is implemented by the chunk types that can carry an in-band error.
+type streamErrorCarrier interface {
+	streamError() *StreamError
+}
+
+// apiError converts the in-band error of a chunk with the given data to an *APIError.
+func (e *StreamError) apiError(statusCode int, data string) *APIError {
+	apiErr := &APIError{
+		StatusCode:   statusCode,
+		Message:      e.Message,
+		ResponseBody: data,
+	}
+	if code, err := strconv.Atoi(strings.Trim(string(e.Code), `"`)); err == nil {
+		apiErr.APICode = code
+	} else if len(e.Code) > 0 && apiErr.Message == "" {
+		apiErr.Message = strings.Trim(string(e.Code), `"`)
+	}
+	if apiErr.Message == "" {
+		apiErr.Message = "stream error"
+	}
+	return apiErr
+}
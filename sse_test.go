@@ -0,0 +1,124 @@
+package deepseek_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/cohesion-org/deepseek-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newSSEStream(t *testing.T, body string) deepseek.ChatCompletionStream {
+	t.Helper()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(ts.Close)
+
+	client, err := deepseek.NewClientWithOptions("token", deepseek.WithBaseURL(ts.URL+"/"))
+	require.NoError(t, err)
+	stream, err := client.CreateChatCompletionStream(context.Background(), &deepseek.StreamChatCompletionRequest{})
+	require.NoError(t, err)
+	t.Cleanup(func() { stream.Close() })
+	return stream
+}
+
+func TestSSEDecoding(t *testing.T) {
+	t.Run("comments, metadata and missing space", func(t *testing.T) {
+		stream := newSSEStream(t, ": keep-alive\n\n"+
+			"retry: 1500\n"+
+			"id: 7\n"+
+			"event: message\n"+
+			"data:{\"id\":\"a\",\"choices\":[{\"index\":0,\"delta\":{\"content\":\"hi\"}}]}\n\n"+
+			"data: [DONE]\n\n")
+
+		chunk, err := stream.Recv()
+		require.NoError(t, err)
+		assert.Equal(t, "hi", chunk.Choices[0].Delta.Content)
+		require.NotNil(t, chunk.Event)
+		assert.Equal(t, "message", chunk.Event.Event)
+		assert.Equal(t, "7", chunk.Event.ID)
+		assert.Equal(t, 1500*time.Millisecond, chunk.Event.Retry)
+
+		_, err = stream.Recv()
+		assert.ErrorIs(t, err, io.EOF)
+	})
+
+	t.Run("multi-line data and CRLF", func(t *testing.T) {
+		stream := newSSEStream(t, "data: {\"id\":\"a\",\r\ndata: \"choices\":[{\"index\":0,\"delta\":{\"content\":\"x\"}}]}\r\n\r\n")
+
+		chunk, err := stream.Recv()
+		require.NoError(t, err)
+		assert.Equal(t, "x", chunk.Choices[0].Delta.Content)
+
+		_, err = stream.Recv()
+		assert.ErrorIs(t, err, io.EOF)
+	})
+
+	t.Run("CR line endings do not wait for the next byte", func(t *testing.T) {
+		done := make(chan struct{})
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("data: {\"id\":\"a\",\"choices\":[]}\r\r"))
+			w.(http.Flusher).Flush()
+			<-done
+		}))
+		defer ts.Close()
+		defer close(done)
+		client, err := deepseek.NewClientWithOptions("token", deepseek.WithBaseURL(ts.URL+"/"))
+		require.NoError(t, err)
+		stream, err := client.CreateChatCompletionStream(context.Background(), &deepseek.StreamChatCompletionRequest{})
+		require.NoError(t, err)
+		defer stream.Close()
+
+		received := make(chan string, 1)
+		go func() {
+			if chunk, err := stream.Recv(); err == nil {
+				received <- chunk.ID
+			}
+		}()
+		select {
+		case id := <-received:
+			assert.Equal(t, "a", id)
+		case <-time.After(time.Second):
+			t.Fatal("the event was not dispatched before the next byte arrived")
+		}
+	})
+
+	t.Run("event pending at end of stream", func(t *testing.T) {
+		stream := newSSEStream(t, "data: {\"id\":\"a\",\"choices\":[]}")
+
+		chunk, err := stream.Recv()
+		require.NoError(t, err)
+		assert.Equal(t, "a", chunk.ID)
+	})
+
+	t.Run("in-band error", func(t *testing.T) {
+		stream := newSSEStream(t, "data: {\"id\":\"a\",\"choices\":[{\"index\":0,\"delta\":{\"content\":\"hi\"}}]}\n\n"+
+			"data: {\"error\":{\"code\":502,\"message\":\"Provider disconnected\"}}\n\n")
+
+		_, err := stream.Recv()
+		require.NoError(t, err)
+
+		_, err = stream.Recv()
+		var apiErr *deepseek.APIError
+		require.True(t, errors.As(err, &apiErr))
+		assert.Equal(t, 502, apiErr.APICode)
+		assert.Equal(t, "Provider disconnected", apiErr.Message)
+	})
+
+	t.Run("error event type", func(t *testing.T) {
+		stream := newSSEStream(t, "event: error\ndata: overloaded\n\n")
+
+		_, err := stream.Recv()
+		var apiErr *deepseek.APIError
+		require.True(t, errors.As(err, &apiErr))
+		assert.Equal(t, "overloaded", apiErr.Message)
+	})
+}
@@ -0,0 +1,145 @@
+package deepseek
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// ErrConversationNotFound is returned by a ConversationStore when no conversation has the requested ID.
+var ErrConversationNotFound = errors.New("conversation not found")
+
+// ConversationState is the persistent form of a Conversation.
+type ConversationState struct {
+	ID           string                  `json:"id"`                    // ID of the conversation.
+	Template     ChatCompletionRequest   `json:"template"`              // Model and request parameters. Its Messages are unused.
+	ExtraBody    map[string]any          `json:"extra_body,omitempty"`  // The template's ExtraBody, which its JSON form leaves out.
+	Compactor    *Compactor              `json:"compactor,omitempty"`   // The compactor set with SetCompactor, without its Counter.
+	Messages     []ChatCompletionMessage `json:"messages"`              // The history, including tool calls and reasoning content.
+	Usage        Usage                   `json:"usage"`                 // Tokens used by the conversation so far.
+	Compactions  []CompactionRecord      `json:"compactions,omitempty"` // Compactions of the history.
+	SummaryIndex int                     `json:"summary_index"`         // Index of the latest compaction summary, or -1.
+	UpdatedAt    time.Time               `json:"updated_at"`            // When the state was taken.
+}
+
+// ConversationStore persists conversations so they can be resumed by ID, for example after a restart.
+// Implementations must be safe for concurrent use.
+type ConversationStore interface {
+	// Save creates or replaces the conversation with the state's ID.
+	Save(ctx context.Context, state *ConversationState) error
+	// Load returns the conversation with the given ID, or ErrConversationNotFound.
+	Load(ctx context.Context, id string) (*ConversationState, error)
+	// List returns the IDs of all stored conversations in lexical order.
+	List(ctx context.Context) ([]string, error)
+	// Delete removes the conversation with the given ID, or returns ErrConversationNotFound.
+	Delete(ctx context.Context, id string) error
+}
+
+// State returns a snapshot of the conversation that can be saved in a ConversationStore.
+func (c *Conversation) State() *ConversationState {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	state := &ConversationState{
+		ID:           c.id,
+		Template:     c.template,
+		ExtraBody:    maps.Clone(c.template.ExtraBody),
+		Messages:     append([]ChatCompletionMessage(nil), c.messages...),
+		Usage:        c.usage,
+		Compactions:  append([]CompactionRecord(nil), c.compactions...),
+		SummaryIndex: c.summaryIndex,
+		UpdatedAt:    time.Now(),
+	}
+	if c.compactor != nil {
+		compactor := *c.compactor
+		state.Compactor = &compactor
+	}
+	return state
+}
+
+// Save stores a snapshot of the conversation in store.
+func (c *Conversation) Save(ctx context.Context, store ConversationStore) error {
+	if store == nil {
+		return fmt.Errorf("store cannot be nil")
+	}
+	return store.Save(ctx, c.State())
+}
+
+// ConversationFromState restores a conversation that sends its requests with client.
+// A restored compactor counts tokens with EstimateRequestTokens; set it again to use another counter.
+func ConversationFromState(client *Client, state *ConversationState) (*Conversation, error) {
+	if state == nil {
+		return nil, fmt.Errorf("state cannot be nil")
+	}
+	if err := validateConversationID(state.ID); err != nil {
+		return nil, err
+	}
+	summaryIndex := state.SummaryIndex
+	if summaryIndex < 0 || summaryIndex >= len(state.Messages) {
+		summaryIndex = -1
+	}
+	template := state.Template
+	template.Messages = nil
+	template.ExtraBody = maps.Clone(state.ExtraBody)
+	var compactor *Compactor
+	if state.Compactor != nil {
+		restored := *state.Compactor
+		compactor = &restored
+	}
+	return &Conversation{
+		id:           state.ID,
+		client:       client,
+		template:     template,
+		messages:     append([]ChatCompletionMessage(nil), state.Messages...),
+		compactor:    compactor,
+		compactions:  append([]CompactionRecord(nil), state.Compactions...),
+		summaryIndex: summaryIndex,
+		usage:        state.Usage,
+	}, nil
+}
+
+// ResumeConversation loads the conversation with the given ID from store.
+func ResumeConversation(ctx context.Context, client *Client, store ConversationStore, id string) (*Conversation, error) {
+	if store == nil {
+		return nil, fmt.Errorf("store cannot be nil")
+	}
+	state, err := store.Load(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return ConversationFromState(client, state)
+}
+
+// validateConversationID checks that an ID can be used as a key and a file name.
+func validateConversationID(id string) error {
+	if id == "" {
+		return fmt.Errorf("conversation ID cannot be empty")
+	}
+	if len(id) > 200 || id == "." || id == ".." || strings.ContainsAny(id, `/\:*?"<>|`) || strings.ContainsFunc(id, isControl) {
+		return fmt.Errorf("invalid conversation ID %q", id)
+	}
+	return nil
+}
+
+// syncDir flushes the entries of dir to disk, so a file renamed into it survives a crash.
+// Windows cannot sync directories and persists renames without it.
+func syncDir(dir string) error {
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+// isControl reports whether r is an ASCII control character.
+func isControl(r rune) bool {
+	return r < 0x20 || r == 0x7f
+}
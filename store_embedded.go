@@ -0,0 +1,337 @@
+package deepseek
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// Record operations of the embedded store's log.
+const (
+	embeddedOpPut    byte = 1
+	embeddedOpDelete byte = 2
+)
+
+// embeddedHeaderSize is the size of a record header: payload length and CRC-32 checksum.
+const embeddedHeaderSize = 8
+
+// embeddedCompactMin is the amount of garbage in bytes that must accumulate before the log is compacted.
+const embeddedCompactMin = 1 << 20
+
+// EmbeddedStore is a ConversationStore kept in a single file without any external service.
+// The file is an append-only log of checksummed records that is replayed on open; a record torn by a crash
+// is discarded. Space taken by replaced and deleted conversations is reclaimed automatically.
+// The file must only be opened by one EmbeddedStore at a time.
+type EmbeddedStore struct {
+	mu      sync.RWMutex
+	path    string
+	file    *os.File
+	size    int64                  // Length of the valid log.
+	index   map[string]embeddedRef // Location of the latest value of every key.
+	garbage int64                  // Bytes taken by records that are no longer live.
+}
+
+// embeddedRef locates a value in the log.
+type embeddedRef struct {
+	offset int64 // Offset of the value.
+	length int   // Length of the value.
+	record int64 // Length of the whole record including its header.
+}
+
+// OpenEmbeddedStore opens or creates the store in the file at path.
+func OpenEmbeddedStore(path string) (*EmbeddedStore, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("opening store: %w", err)
+	}
+	s := &EmbeddedStore{path: path, file: file}
+	if err := s.replay(); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// Close closes the store file.
+func (s *EmbeddedStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Close()
+	s.file = nil
+	return err
+}
+
+// Save appends the conversation to the log.
+func (s *EmbeddedStore) Save(ctx context.Context, state *ConversationState) error {
+	if state == nil {
+		return fmt.Errorf("state cannot be nil")
+	}
+	if err := validateConversationID(state.ID); err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	data, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("encoding conversation: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.file == nil {
+		return fmt.Errorf("store is closed")
+	}
+	if err := s.append(embeddedOpPut, state.ID, data); err != nil {
+		return fmt.Errorf("saving conversation: %w", err)
+	}
+	s.maybeCompact()
+	return nil
+}
+
+// Load reads the latest state of the conversation with the given ID.
+func (s *EmbeddedStore) Load(ctx context.Context, id string) (*ConversationState, error) {
+	if err := validateConversationID(id); err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.file == nil {
+		return nil, fmt.Errorf("store is closed")
+	}
+	ref, ok := s.index[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrConversationNotFound, id)
+	}
+	data := make([]byte, ref.length)
+	if _, err := s.file.ReadAt(data, ref.offset); err != nil {
+		return nil, fmt.Errorf("loading conversation: %w", err)
+	}
+	var state ConversationState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("decoding conversation %s: %w", id, err)
+	}
+	return &state, nil
+}
+
+// List returns the IDs of all stored conversations.
+func (s *EmbeddedStore) List(ctx context.Context) ([]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.file == nil {
+		return nil, fmt.Errorf("store is closed")
+	}
+	ids := make([]string, 0, len(s.index))
+	for id := range s.index {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+// Delete appends a deletion record for the conversation.
+func (s *EmbeddedStore) Delete(ctx context.Context, id string) error {
+	if err := validateConversationID(id); err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.file == nil {
+		return fmt.Errorf("store is closed")
+	}
+	if _, ok := s.index[id]; !ok {
+		return fmt.Errorf("%w: %s", ErrConversationNotFound, id)
+	}
+	if err := s.append(embeddedOpDelete, id, nil); err != nil {
+		return fmt.Errorf("deleting conversation: %w", err)
+	}
+	s.maybeCompact()
+	return nil
+}
+
+// Compact rewrites the log so that it only contains live conversations.
+func (s *EmbeddedStore) Compact() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.file == nil {
+		return fmt.Errorf("store is closed")
+	}
+	return s.compact()
+}
+
+// encodeEmbeddedRecord encodes a record: a header with the payload length and checksum, followed by the
+// payload made of the operation, the key length, the key and the value.
+func encodeEmbeddedRecord(op byte, key string, value []byte) []byte {
+	payload := 3 + len(key) + len(value)
+	buf := make([]byte, embeddedHeaderSize+payload)
+	p := buf[embeddedHeaderSize:]
+	p[0] = op
+	binary.BigEndian.PutUint16(p[1:3], uint16(len(key)))
+	copy(p[3:], key)
+	copy(p[3+len(key):], value)
+	binary.BigEndian.PutUint32(buf[0:4], uint32(payload))
+	binary.BigEndian.PutUint32(buf[4:8], crc32.ChecksumIEEE(p))
+	return buf
+}
+
+// append writes a record at the end of the log and updates the index. The caller must hold mu.
+func (s *EmbeddedStore) append(op byte, key string, value []byte) error {
+	record := encodeEmbeddedRecord(op, key, value)
+	if _, err := s.file.WriteAt(record, s.size); err != nil {
+		return err
+	}
+	if err := s.file.Sync(); err != nil {
+		return err
+	}
+	s.apply(op, key, s.size, len(value), int64(len(record)))
+	s.size += int64(len(record))
+	return nil
+}
+
+// apply updates the index for a record at offset. The caller must hold mu.
+func (s *EmbeddedStore) apply(op byte, key string, offset int64, valueLength int, recordLength int64) {
+	if old, ok := s.index[key]; ok {
+		s.garbage += old.record
+	}
+	switch op {
+	case embeddedOpPut:
+		s.index[key] = embeddedRef{
+			offset: offset + embeddedHeaderSize + 3 + int64(len(key)),
+			length: valueLength,
+			record: recordLength,
+		}
+	case embeddedOpDelete:
+		delete(s.index, key)
+		s.garbage += recordLength
+	}
+}
+
+// replay rebuilds the index from the log and cuts off a torn record at its end.
+func (s *EmbeddedStore) replay() error {
+	s.index = make(map[string]embeddedRef)
+	s.size, s.garbage = 0, 0
+
+	info, err := s.file.Stat()
+	if err != nil {
+		return fmt.Errorf("opening store: %w", err)
+	}
+	reader := io.NewSectionReader(s.file, 0, info.Size())
+	header := make([]byte, embeddedHeaderSize)
+	for {
+		if _, err := io.ReadFull(reader, header); err != nil {
+			break
+		}
+		length := binary.BigEndian.Uint32(header[0:4])
+		if length < 3 || int64(length) > info.Size()-s.size-embeddedHeaderSize {
+			break
+		}
+		payload := make([]byte, length)
+		if _, err := io.ReadFull(reader, payload); err != nil {
+			break
+		}
+		if crc32.ChecksumIEEE(payload) != binary.BigEndian.Uint32(header[4:8]) {
+			break
+		}
+		op := payload[0]
+		keyLength := int(binary.BigEndian.Uint16(payload[1:3]))
+		if (op != embeddedOpPut && op != embeddedOpDelete) || 3+keyLength > len(payload) {
+			break
+		}
+		key := string(payload[3 : 3+keyLength])
+		recordLength := int64(embeddedHeaderSize + length)
+		s.apply(op, key, s.size, len(payload)-3-keyLength, recordLength)
+		s.size += recordLength
+	}
+
+	if s.size < info.Size() {
+		if err := s.file.Truncate(s.size); err != nil {
+			return fmt.Errorf("repairing store: %w", err)
+		}
+	}
+	return nil
+}
+
+// maybeCompact compacts the log when enough garbage has accumulated. The caller must hold mu.
+// A failed compaction leaves the log as it was and is tried again on the next write, so its error is dropped:
+// the write that triggered it has already succeeded.
+func (s *EmbeddedStore) maybeCompact() {
+	if s.garbage < embeddedCompactMin || s.garbage < s.size/2 {
+		return
+	}
+	_ = s.compact()
+}
+
+// compact writes the live records to a new file and replaces the log with it. The caller must hold mu.
+func (s *EmbeddedStore) compact() error {
+	tmpPath := s.path + ".compact"
+	tmp, err := os.OpenFile(tmpPath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return fmt.Errorf("compacting store: %w", err)
+	}
+	cleanup := func(err error) error {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return fmt.Errorf("compacting store: %w", err)
+	}
+
+	keys := make([]string, 0, len(s.index))
+	for key := range s.index {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	index := make(map[string]embeddedRef, len(keys))
+	var size int64
+	for _, key := range keys {
+		ref := s.index[key]
+		value := make([]byte, ref.length)
+		if _, err := s.file.ReadAt(value, ref.offset); err != nil {
+			return cleanup(err)
+		}
+		record := encodeEmbeddedRecord(embeddedOpPut, key, value)
+		if _, err := tmp.WriteAt(record, size); err != nil {
+			return cleanup(err)
+		}
+		index[key] = embeddedRef{
+			offset: size + embeddedHeaderSize + 3 + int64(len(key)),
+			length: ref.length,
+			record: int64(len(record)),
+		}
+		size += int64(len(record))
+	}
+	if err := tmp.Sync(); err != nil {
+		return cleanup(err)
+	}
+	if err := os.Rename(tmpPath, s.path); err != nil {
+		return cleanup(err)
+	}
+
+	s.file.Close()
+	s.file = tmp
+	s.index = index
+	s.size = size
+	s.garbage = 0
+	if err := syncDir(filepath.Dir(s.path)); err != nil {
+		return fmt.Errorf("compacting store: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+package deepseek
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// JSONFileStore is a ConversationStore that keeps every conversation in its own JSON file in a directory.
+// Files are replaced atomically, so a crash never leaves a partially written conversation behind.
+type JSONFileStore struct {
+	mu  sync.RWMutex
+	dir string
+}
+
+// NewJSONFileStore creates a store in dir. The directory is created if it does not exist.
+func NewJSONFileStore(dir string) (*JSONFileStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("creating store directory: %w", err)
+	}
+	return &JSONFileStore{dir: dir}, nil
+}
+
+// Save writes the conversation to <dir>/<id>.json.
+func (s *JSONFileStore) Save(ctx context.Context, state *ConversationState) error {
+	if state == nil {
+		return fmt.Errorf("state cannot be nil")
+	}
+	if err := validateConversationID(state.ID); err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding conversation: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tmp, err := os.CreateTemp(s.dir, state.ID+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("saving conversation: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("saving conversation: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("saving conversation: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("saving conversation: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path(state.ID)); err != nil {
+		return fmt.Errorf("saving conversation: %w", err)
+	}
+	if err := syncDir(s.dir); err != nil {
+		return fmt.Errorf("saving conversation: %w", err)
+	}
+	return nil
+}
+
+// Load reads the conversation with the given ID.
+func (s *JSONFileStore) Load(ctx context.Context, id string) (*ConversationState, error) {
+	if err := validateConversationID(id); err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	s.mu.RLock()
+	data, err := os.ReadFile(s.path(id))
+	s.mu.RUnlock()
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", ErrConversationNotFound, id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("loading conversation: %w", err)
+	}
+	var state ConversationState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("decoding conversation %s: %w", id, err)
+	}
+	return &state, nil
+}
+
+// List returns the IDs of all conversations in the directory.
+func (s *JSONFileStore) List(ctx context.Context) ([]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	s.mu.RLock()
+	entries, err := os.ReadDir(s.dir)
+	s.mu.RUnlock()
+	if err != nil {
+		return nil, fmt.Errorf("listing conversations: %w", err)
+	}
+	var ids []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".json") {
+			continue
+		}
+		ids = append(ids, strings.TrimSuffix(name, ".json"))
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+// Delete removes the conversation file.
+func (s *JSONFileStore) Delete(ctx context.Context, id string) error {
+	if err := validateConversationID(id); err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	err := os.Remove(s.path(id))
+	if errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("%w: %s", ErrConversationNotFound, id)
+	}
+	if err != nil {
+		return fmt.Errorf("deleting conversation: %w", err)
+	}
+	return nil
+}
+
+// path returns the file name of a conversation.
+func (s *JSONFileStore) path(id string) string {
+	return filepath.Join(s.dir, id+".json")
+}
@@ -0,0 +1,161 @@
+package deepseek_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cohesion-org/deepseek-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testState(id string) *deepseek.ConversationState {
+	return &deepseek.ConversationState{
+		ID:       id,
+		Template: deepseek.ChatCompletionRequest{Model: deepseek.DeepSeekReasoner, MaxTokens: 100},
+		Messages: []deepseek.ChatCompletionMessage{
+			{Role: deepseek.ChatMessageRoleUser, Content: "weather?"},
+			{Role: deepseek.ChatMessageRoleAssistant, ReasoningContent: "need a tool", ToolCalls: []deepseek.ToolCall{cityCall("a", "Paris")}},
+			{Role: deepseek.ChatMessageRoleTool, ToolCallID: "a", Content: "sunny"},
+		},
+		Usage:        deepseek.Usage{PromptTokens: 10, CompletionTokens: 5, TotalTokens: 15},
+		SummaryIndex: -1,
+	}
+}
+
+func TestConversationStores(t *testing.T) {
+	stores := map[string]func(t *testing.T) deepseek.ConversationStore{
+		"json files": func(t *testing.T) deepseek.ConversationStore {
+			store, err := deepseek.NewJSONFileStore(filepath.Join(t.TempDir(), "conversations"))
+			require.NoError(t, err)
+			return store
+		},
+		"embedded": func(t *testing.T) deepseek.ConversationStore {
+			store, err := deepseek.OpenEmbeddedStore(filepath.Join(t.TempDir(), "conversations.db"))
+			require.NoError(t, err)
+			t.Cleanup(func() { store.Close() })
+			return store
+		},
+	}
+
+	for name, newStore := range stores {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			store := newStore(t)
+
+			ids, err := store.List(ctx)
+			require.NoError(t, err)
+			assert.Empty(t, ids)
+
+			_, err = store.Load(ctx, "missing")
+			require.ErrorIs(t, err, deepseek.ErrConversationNotFound)
+
+			require.NoError(t, store.Save(ctx, testState("b")))
+			require.NoError(t, store.Save(ctx, testState("a")))
+			updated := testState("a")
+			updated.Messages = append(updated.Messages, deepseek.ChatCompletionMessage{Role: deepseek.ChatMessageRoleAssistant, Content: "sunny"})
+			require.NoError(t, store.Save(ctx, updated))
+
+			loaded, err := store.Load(ctx, "a")
+			require.NoError(t, err)
+			assert.Equal(t, updated.Messages, loaded.Messages)
+			assert.Equal(t, updated.Usage, loaded.Usage)
+			assert.Equal(t, deepseek.DeepSeekReasoner, loaded.Template.Model)
+
+			ids, err = store.List(ctx)
+			require.NoError(t, err)
+			assert.Equal(t, []string{"a", "b"}, ids)
+
+			require.NoError(t, store.Delete(ctx, "b"))
+			require.ErrorIs(t, store.Delete(ctx, "b"), deepseek.ErrConversationNotFound)
+			ids, err = store.List(ctx)
+			require.NoError(t, err)
+			assert.Equal(t, []string{"a"}, ids)
+
+			require.Error(t, store.Save(ctx, testState("../escape")))
+			require.Error(t, store.Save(ctx, testState("")))
+		})
+	}
+}
+
+func TestEmbeddedStoreReopen(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "conversations.db")
+
+	store, err := deepseek.OpenEmbeddedStore(path)
+	require.NoError(t, err)
+	require.NoError(t, store.Save(ctx, testState("a")))
+	require.NoError(t, store.Save(ctx, testState("b")))
+	require.NoError(t, store.Delete(ctx, "b"))
+	require.NoError(t, store.Close())
+
+	// Simulate a crash in the middle of a write.
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	require.NoError(t, err)
+	_, err = file.Write([]byte{0, 0, 1, 0, 1, 2})
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+
+	store, err = deepseek.OpenEmbeddedStore(path)
+	require.NoError(t, err)
+	defer store.Close()
+	ids, err := store.List(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a"}, ids)
+
+	require.NoError(t, store.Save(ctx, testState("c")))
+	before, err := os.Stat(path)
+	require.NoError(t, err)
+	require.NoError(t, store.Compact())
+	after, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Less(t, after.Size(), before.Size())
+
+	loaded, err := store.Load(ctx, "a")
+	require.NoError(t, err)
+	assert.Equal(t, testState("a").Messages, loaded.Messages)
+	loaded, err = store.Load(ctx, "c")
+	require.NoError(t, err)
+	assert.Equal(t, "c", loaded.ID)
+}
+
+func TestResumeConversation(t *testing.T) {
+	var requests []deepseek.ChatCompletionRequest
+	ts := echoServer(t, &requests)
+	client, err := deepseek.NewClientWithOptions("token", deepseek.WithBaseURL(ts.URL+"/"))
+	require.NoError(t, err)
+	store, err := deepseek.NewJSONFileStore(t.TempDir())
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	conv := deepseek.NewConversation(client, deepseek.ChatCompletionRequest{
+		Model:       deepseek.DeepSeekReasoner,
+		Temperature: 0.5,
+		ExtraBody:   map[string]any{"top_k": float64(40)},
+	})
+	conv.SetCompactor(deepseek.NewCompactor(50_000, 3))
+	_, err = conv.Send(ctx, "hello")
+	require.NoError(t, err)
+	require.NoError(t, conv.Save(ctx, store))
+
+	resumed, err := deepseek.ResumeConversation(ctx, client, store, conv.ID())
+	require.NoError(t, err)
+	assert.Equal(t, conv.ID(), resumed.ID())
+	assert.Equal(t, conv.Messages(), resumed.Messages())
+	assert.Equal(t, "thinking", resumed.Messages()[1].ReasoningContent)
+	assert.Equal(t, 3, resumed.Usage().TotalTokens)
+	state := resumed.State()
+	assert.Equal(t, map[string]any{"top_k": float64(40)}, state.ExtraBody, "extra body fields survive the JSON round trip")
+	assert.Equal(t, deepseek.NewCompactor(50_000, 3), state.Compactor)
+
+	_, err = resumed.Send(ctx, "again")
+	require.NoError(t, err)
+	assert.Equal(t, float32(0.5), requests[1].Temperature)
+	assert.Len(t, requests[1].Messages, 3)
+	assert.Equal(t, 6, resumed.Usage().TotalTokens)
+
+	_, err = deepseek.ResumeConversation(ctx, client, store, "missing")
+	require.ErrorIs(t, err, deepseek.ErrConversationNotFound)
+}
@@ -0,0 +1,157 @@
+package deepseek
+
+import (
+	"context"
+	"errors"
+	"io"
+	"iter"
+	"sync"
+)
+
+// StreamAll returns an iterator over the chunks of a chat completion stream.
+// The iterator ends at io.EOF, yields any other error once and then ends. The stream is closed when
+// the loop finishes, including when it breaks early.
+//
+//	for chunk, err := range deepseek.StreamAll(stream) {
+//		if err != nil {
+//			return err
+//		}
+//		fmt.Print(chunk.Choices[0].Delta.Content)
+//	}
+func StreamAll(stream ChatCompletionStream) iter.Seq2[*StreamChatCompletionResponse, error] {
+	return func(yield func(*StreamChatCompletionResponse, error) bool) {
+		defer stream.Close()
+		for {
+			chunk, err := stream.Recv()
+			if errors.Is(err, io.EOF) {
+				return
+			}
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+			if !yield(chunk, nil) {
+				return
+			}
+		}
+	}
+}
+
+// FIMStreamAll returns an iterator over the chunks of a FIM completion stream.
+// It behaves like StreamAll.
+func FIMStreamAll(stream FIMChatCompletionStream) iter.Seq2[*FIMStreamCompletionResponse, error] {
+	return func(yield func(*FIMStreamCompletionResponse, error) bool) {
+		defer stream.FIMClose()
+		for {
+			chunk, err := stream.FIMRecv()
+			if errors.Is(err, io.EOF) {
+				return
+			}
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+			if !yield(chunk, nil) {
+				return
+			}
+		}
+	}
+}
+
+// StreamResult is a chunk or an error delivered by StreamChan.
+type StreamResult struct {
+	Response *StreamChatCompletionResponse // The received chunk, nil if Err is set.
+	Err      error                         // The stream error. io.EOF is never delivered.
+}
+
+// FIMStreamResult is a chunk or an error delivered by FIMStreamChan.
+type FIMStreamResult struct {
+	Response *FIMStreamCompletionResponse // The received chunk, nil if Err is set.
+	Err      error                        // The stream error. io.EOF is never delivered.
+}
+
+// streamCanceler is implemented by the streams of this package and their wrappers. cancelStream cancels the
+// request of the stream, which makes a Recv in progress return, and reports whether it could. Unlike Close,
+// it is safe to call from any goroutine.
+type streamCanceler interface {
+	cancelStream() bool
+}
+
+// cancelStream cancels the request of stream and reports whether it could.
+func cancelStream(stream any) bool {
+	s, ok := stream.(streamCanceler)
+	return ok && s.cancelStream()
+}
+
+// onceClosedChatStream closes the wrapped stream only once.
+type onceClosedChatStream struct {
+	ChatCompletionStream
+	close func() error
+}
+
+// Close closes the wrapped stream if it is not closed yet.
+func (s *onceClosedChatStream) Close() error {
+	return s.close()
+}
+
+// onceClosedFIMStream closes the wrapped stream only once.
+type onceClosedFIMStream struct {
+	FIMChatCompletionStream
+	close func() error
+}
+
+// FIMClose closes the wrapped stream if it is not closed yet.
+func (s *onceClosedFIMStream) FIMClose() error {
+	return s.close()
+}
+
+// StreamChan reads the stream in a goroutine and delivers its chunks on the returned channel for use in select loops.
+// The channel is closed when the stream ends, fails or ctx is done, and the stream is closed once with it.
+// When ctx is done, the request of a stream created by this package is cancelled so that the reading goroutine
+// returns from Recv and closes the stream. Other streams are closed when ctx is done, possibly while Recv is
+// in progress, so their Close must be safe to call concurrently with Recv.
+func StreamChan(ctx context.Context, stream ChatCompletionStream) <-chan StreamResult {
+	ch := make(chan StreamResult)
+	closeOnce := sync.OnceValue(stream.Close)
+	stop := context.AfterFunc(ctx, func() {
+		if !cancelStream(stream) {
+			closeOnce()
+		}
+	})
+	go func() {
+		defer close(ch)
+		defer stop()
+		for chunk, err := range StreamAll(&onceClosedChatStream{stream, closeOnce}) {
+			select {
+			case ch <- StreamResult{Response: chunk, Err: err}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch
+}
+
+// FIMStreamChan reads the stream in a goroutine and delivers its chunks on the returned channel.
+// It behaves like StreamChan.
+func FIMStreamChan(ctx context.Context, stream FIMChatCompletionStream) <-chan FIMStreamResult {
+	ch := make(chan FIMStreamResult)
+	closeOnce := sync.OnceValue(stream.FIMClose)
+	stop := context.AfterFunc(ctx, func() {
+		if !cancelStream(stream) {
+			closeOnce()
+		}
+	})
+	go func() {
+		defer close(ch)
+		defer stop()
+		for chunk, err := range FIMStreamAll(&onceClosedFIMStream{stream, closeOnce}) {
+			select {
+			case ch <- FIMStreamResult{Response: chunk, Err: err}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch
+}
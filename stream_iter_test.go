@@ -0,0 +1,151 @@
+package deepseek_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cohesion-org/deepseek-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// sliceStream replays fixed chunks, then returns err (io.EOF if nil).
+type sliceStream struct {
+	chunks []string
+	err    error
+	block  chan struct{} // if set, Recv blocks on it once the chunks are exhausted
+	closed atomic.Bool
+	closes atomic.Int32
+}
+
+func (s *sliceStream) Recv() (*deepseek.StreamChatCompletionResponse, error) {
+	if len(s.chunks) == 0 {
+		if s.block != nil {
+			<-s.block
+			return nil, errors.New("stream closed")
+		}
+		if s.err != nil {
+			return nil, s.err
+		}
+		return nil, io.EOF
+	}
+	content := s.chunks[0]
+	s.chunks = s.chunks[1:]
+	return &deepseek.StreamChatCompletionResponse{
+		Choices: []deepseek.StreamChoices{{Delta: deepseek.StreamDelta{Content: content}}},
+	}, nil
+}
+
+func (s *sliceStream) Close() error {
+	s.closes.Add(1)
+	if s.closed.CompareAndSwap(false, true) && s.block != nil {
+		close(s.block)
+	}
+	return nil
+}
+
+func TestStreamAll(t *testing.T) {
+	t.Run("iterates until EOF", func(t *testing.T) {
+		stream := &sliceStream{chunks: []string{"a", "b", "c"}}
+		var got string
+		for chunk, err := range deepseek.StreamAll(stream) {
+			require.NoError(t, err)
+			got += chunk.Choices[0].Delta.Content
+		}
+		assert.Equal(t, "abc", got)
+		assert.True(t, stream.closed.Load())
+	})
+
+	t.Run("yields errors once", func(t *testing.T) {
+		stream := &sliceStream{chunks: []string{"a"}, err: errors.New("boom")}
+		var errs []error
+		for _, err := range deepseek.StreamAll(stream) {
+			if err != nil {
+				errs = append(errs, err)
+			}
+		}
+		require.Len(t, errs, 1)
+		assert.EqualError(t, errs[0], "boom")
+		assert.True(t, stream.closed.Load())
+	})
+
+	t.Run("closes on early break", func(t *testing.T) {
+		stream := &sliceStream{chunks: []string{"a", "b", "c"}}
+		for range deepseek.StreamAll(stream) {
+			break
+		}
+		assert.True(t, stream.closed.Load())
+	})
+}
+
+func TestStreamChan(t *testing.T) {
+	t.Run("delivers chunks and closes", func(t *testing.T) {
+		stream := &sliceStream{chunks: []string{"a", "b"}}
+		var got string
+		for result := range deepseek.StreamChan(context.Background(), stream) {
+			require.NoError(t, result.Err)
+			got += result.Response.Choices[0].Delta.Content
+		}
+		assert.Equal(t, "ab", got)
+		assert.True(t, stream.closed.Load())
+	})
+
+	t.Run("stops when the context is done", func(t *testing.T) {
+		stream := &sliceStream{chunks: []string{"a"}, block: make(chan struct{})}
+		ctx, cancel := context.WithCancel(context.Background())
+		ch := deepseek.StreamChan(ctx, stream)
+
+		first := <-ch
+		require.NoError(t, first.Err)
+		cancel()
+
+		select {
+		case _, ok := <-ch:
+			for ok {
+				_, ok = <-ch
+			}
+		case <-time.After(time.Second):
+			t.Fatal("channel was not closed after cancel")
+		}
+		assert.Equal(t, int32(1), stream.closes.Load())
+	})
+
+	t.Run("cancels a client stream during Recv", func(t *testing.T) {
+		ts := stallingServer(t, timeoutChunk)
+		hook := func(*deepseek.StreamChatCompletionResponse) error { return nil }
+		client, err := deepseek.NewClientWithOptions("token",
+			deepseek.WithBaseURL(ts.URL+"/"),
+			deepseek.WithLogger(slog.New(slog.NewTextHandler(io.Discard, nil))),
+			deepseek.WithUsageLedger(deepseek.NewUsageLedger()),
+			deepseek.WithMiddleware(deepseek.StreamHookMiddleware(hook, nil)),
+		)
+		require.NoError(t, err)
+		stream, err := client.CreateChatCompletionStream(context.Background(), &deepseek.StreamChatCompletionRequest{})
+		require.NoError(t, err)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		ch := deepseek.StreamChan(ctx, stream)
+		first := <-ch
+		require.NoError(t, first.Err)
+		// The reader is now blocked in Recv waiting for the stalled server.
+		time.Sleep(20 * time.Millisecond)
+		cancel()
+
+		done := make(chan struct{})
+		go func() {
+			for range ch {
+			}
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-time.After(2 * time.Second):
+			t.Fatal("channel was not closed after cancel")
+		}
+	})
+}
@@ -0,0 +1,146 @@
+package deepseek
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrStreamFirstTokenTimeout is returned when a stream does not deliver its first chunk within the first-token timeout.
+	ErrStreamFirstTokenTimeout = errors.New("stream first token timeout")
+	// ErrStreamIdleTimeout is returned when a stream stops delivering chunks for longer than the idle timeout.
+	ErrStreamIdleTimeout = errors.New("stream idle timeout")
+)
+
+// WithStreamFirstTokenTimeout limits how long a stream may take from the first call to Recv to its first chunk.
+// Time spent before the stream is first read does not count.
+func WithStreamFirstTokenTimeout(d time.Duration) Option {
+	return func(c *Client) error {
+		if d < 0 {
+			return fmt.Errorf("first token timeout must be a positive duration")
+		}
+		c.StreamFirstTokenTimeout = d
+		return nil
+	}
+}
+
+// WithStreamIdleTimeout limits how long a stream may go without receiving a line once it has started.
+// Keep-alive comments sent by the server count as activity.
+func WithStreamIdleTimeout(d time.Duration) Option {
+	return func(c *Client) error {
+		if d < 0 {
+			return fmt.Errorf("idle timeout must be a positive duration")
+		}
+		c.StreamIdleTimeout = d
+		return nil
+	}
+}
+
+// streamWatchdog cancels a stream's request when it stalls. A nil watchdog does nothing.
+type streamWatchdog struct {
+	mu         sync.Mutex
+	cancel     context.CancelFunc // Cancels the request context of the stream.
+	firstToken time.Duration
+	idle       time.Duration
+	timer      *time.Timer
+	started    bool  // Whether the first chunk has been received.
+	expired    error // Set once a timeout fired.
+}
+
+// newStreamWatchdog creates a watchdog. It returns nil if both timeouts are disabled.
+func newStreamWatchdog(cancel context.CancelFunc, firstToken, idle time.Duration) *streamWatchdog {
+	if firstToken <= 0 && idle <= 0 {
+		return nil
+	}
+	return &streamWatchdog{cancel: cancel, firstToken: firstToken, idle: idle}
+}
+
+// arm starts a timer that cancels the request with reason when it fires. The caller must hold mu.
+func (w *streamWatchdog) arm(d time.Duration, reason error) {
+	w.timer = time.AfterFunc(d, func() {
+		w.mu.Lock()
+		if w.expired == nil {
+			w.expired = reason
+		}
+		w.mu.Unlock()
+		w.cancel()
+	})
+}
+
+// beforeRead is called before every blocking read. It arms the first-token timer until the stream has started,
+// and the idle timer afterwards.
+func (w *streamWatchdog) beforeRead() {
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	switch {
+	case w.expired != nil:
+	case !w.started && w.firstToken > 0:
+		w.arm(w.firstToken, fmt.Errorf("%w: no data after %s", ErrStreamFirstTokenTimeout, w.firstToken))
+	case w.started && w.idle > 0:
+		w.arm(w.idle, fmt.Errorf("%w: no data for %s", ErrStreamIdleTimeout, w.idle))
+	}
+}
+
+// afterRead stops the running timer. A successful read marks the stream as started.
+func (w *streamWatchdog) afterRead(ok bool) {
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.stopTimer()
+	if ok {
+		w.started = true
+	}
+}
+
+// lineReceived restarts the idle timer while a read is waiting for the rest of an event.
+func (w *streamWatchdog) lineReceived() {
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.started && w.timer != nil && w.expired == nil {
+		w.stopTimer()
+		w.arm(w.idle, fmt.Errorf("%w: no data for %s", ErrStreamIdleTimeout, w.idle))
+	}
+}
+
+// stopTimer stops the current timer. The caller must hold mu.
+func (w *streamWatchdog) stopTimer() {
+	if w.timer != nil {
+		w.timer.Stop()
+		w.timer = nil
+	}
+}
+
+// wrap replaces err with the timeout error if a timeout caused it.
+func (w *streamWatchdog) wrap(err error) error {
+	if w == nil || err == nil || errors.Is(err, io.EOF) {
+		return err
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.expired != nil {
+		return w.expired
+	}
+	return err
+}
+
+// stop disables the watchdog.
+func (w *streamWatchdog) stop() {
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.stopTimer()
+}
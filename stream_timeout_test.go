@@ -0,0 +1,198 @@
+package deepseek_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cohesion-org/deepseek-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const timeoutChunk = "data: {\"id\":\"1\",\"choices\":[{\"index\":0,\"delta\":{\"content\":\"hi\"}}]}\n\n"
+
+// stallingServer writes the given chunks, then blocks until the client goes away.
+func stallingServer(t *testing.T, chunks ...string) *httptest.Server {
+	t.Helper()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		for _, chunk := range chunks {
+			w.Write([]byte(chunk))
+		}
+		w.(http.Flusher).Flush()
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func TestStreamTimeouts(t *testing.T) {
+	t.Run("first token timeout", func(t *testing.T) {
+		ts := stallingServer(t)
+		client, err := deepseek.NewClientWithOptions("token",
+			deepseek.WithBaseURL(ts.URL+"/"),
+			deepseek.WithStreamFirstTokenTimeout(50*time.Millisecond),
+		)
+		require.NoError(t, err)
+
+		stream, err := client.CreateChatCompletionStream(context.Background(), &deepseek.StreamChatCompletionRequest{})
+		require.NoError(t, err)
+		defer stream.Close()
+
+		start := time.Now()
+		_, err = stream.Recv()
+		assert.True(t, errors.Is(err, deepseek.ErrStreamFirstTokenTimeout), "got %v", err)
+		assert.Less(t, time.Since(start), 2*time.Second)
+	})
+
+	t.Run("idle timeout", func(t *testing.T) {
+		ts := stallingServer(t, timeoutChunk)
+		client, err := deepseek.NewClientWithOptions("token",
+			deepseek.WithBaseURL(ts.URL+"/"),
+			deepseek.WithStreamFirstTokenTimeout(time.Second),
+			deepseek.WithStreamIdleTimeout(50*time.Millisecond),
+		)
+		require.NoError(t, err)
+
+		stream, err := client.CreateChatCompletionStream(context.Background(), &deepseek.StreamChatCompletionRequest{})
+		require.NoError(t, err)
+		defer stream.Close()
+
+		chunk, err := stream.Recv()
+		require.NoError(t, err)
+		assert.Equal(t, "hi", chunk.Choices[0].Delta.Content)
+
+		_, err = stream.Recv()
+		assert.True(t, errors.Is(err, deepseek.ErrStreamIdleTimeout), "got %v", err)
+		assert.False(t, errors.Is(err, deepseek.ErrStreamFirstTokenTimeout))
+	})
+
+	t.Run("keep-alive comments reset the idle timer", func(t *testing.T) {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(timeoutChunk))
+			w.(http.Flusher).Flush()
+			for i := 0; i < 6; i++ {
+				time.Sleep(25 * time.Millisecond)
+				w.Write([]byte(": keep-alive\n"))
+				w.(http.Flusher).Flush()
+			}
+			w.Write([]byte(timeoutChunk + "data: [DONE]\n\n"))
+		}))
+		defer ts.Close()
+
+		client, err := deepseek.NewClientWithOptions("token",
+			deepseek.WithBaseURL(ts.URL+"/"),
+			deepseek.WithStreamIdleTimeout(100*time.Millisecond),
+		)
+		require.NoError(t, err)
+
+		stream, err := client.CreateChatCompletionStream(context.Background(), &deepseek.StreamChatCompletionRequest{})
+		require.NoError(t, err)
+		defer stream.Close()
+
+		for i := 0; i < 2; i++ {
+			_, err := stream.Recv()
+			require.NoError(t, err, "chunk %d", i)
+		}
+	})
+
+	t.Run("retry backoff does not count against the first token timeout", func(t *testing.T) {
+		var calls atomic.Int32
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if calls.Add(1) == 1 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			w.Write([]byte(timeoutChunk))
+		}))
+		defer ts.Close()
+
+		policy := deepseek.DefaultRetryPolicy()
+		policy.InitialBackoff = 200 * time.Millisecond
+		policy.Jitter = 0
+		client, err := deepseek.NewClientWithOptions("token",
+			deepseek.WithBaseURL(ts.URL+"/"),
+			deepseek.WithRetryPolicy(policy),
+			deepseek.WithStreamFirstTokenTimeout(100*time.Millisecond),
+		)
+		require.NoError(t, err)
+
+		stream, err := client.CreateChatCompletionStream(context.Background(), &deepseek.StreamChatCompletionRequest{})
+		require.NoError(t, err)
+		defer stream.Close()
+		_, err = stream.Recv()
+		require.NoError(t, err)
+		assert.Equal(t, int32(2), calls.Load())
+	})
+
+	t.Run("first token timer starts at the first Recv", func(t *testing.T) {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+			w.(http.Flusher).Flush()
+			time.Sleep(300 * time.Millisecond)
+			w.Write([]byte(timeoutChunk))
+		}))
+		defer ts.Close()
+
+		client, err := deepseek.NewClientWithOptions("token",
+			deepseek.WithBaseURL(ts.URL+"/"),
+			deepseek.WithStreamFirstTokenTimeout(200*time.Millisecond),
+		)
+		require.NoError(t, err)
+
+		stream, err := client.CreateChatCompletionStream(context.Background(), &deepseek.StreamChatCompletionRequest{})
+		require.NoError(t, err)
+		defer stream.Close()
+
+		// The chunk arrives after the timeout counted from the headers, but well within it counted from Recv.
+		time.Sleep(250 * time.Millisecond)
+		_, err = stream.Recv()
+		require.NoError(t, err)
+	})
+
+	t.Run("healthy stream is not interrupted", func(t *testing.T) {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			for i := 0; i < 3; i++ {
+				w.Write([]byte(timeoutChunk))
+				w.(http.Flusher).Flush()
+				time.Sleep(20 * time.Millisecond)
+			}
+			w.Write([]byte("data: [DONE]\n\n"))
+		}))
+		defer ts.Close()
+
+		client, err := deepseek.NewClientWithOptions("token",
+			deepseek.WithBaseURL(ts.URL+"/"),
+			deepseek.WithStreamFirstTokenTimeout(200*time.Millisecond),
+			deepseek.WithStreamIdleTimeout(200*time.Millisecond),
+		)
+		require.NoError(t, err)
+
+		stream, err := client.CreateChatCompletionStream(context.Background(), &deepseek.StreamChatCompletionRequest{})
+		require.NoError(t, err)
+		defer stream.Close()
+
+		for i := 0; i < 3; i++ {
+			_, err := stream.Recv()
+			require.NoError(t, err)
+		}
+		_, err = stream.Recv()
+		assert.ErrorIs(t, err, io.EOF)
+	})
+
+	t.Run("rejects negative timeouts", func(t *testing.T) {
+		_, err := deepseek.NewClientWithOptions("token", deepseek.WithStreamIdleTimeout(-time.Second))
+		require.Error(t, err)
+		_, err = deepseek.NewClientWithOptions("token", deepseek.WithStreamFirstTokenTimeout(-time.Second))
+		require.Error(t, err)
+	})
+}
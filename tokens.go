@@ -1,6 +1,7 @@
 package deepseek
 
 import (
+	"encoding/json"
 	"unicode"
 )
 
@@ -44,6 +45,12 @@ func EstimateTokensFromMessages(messages *ChatCompletionRequest) *TokenEstimate
 
 		// Add tokens for content
 		totalTokens += EstimateTokenCount(msg.Content).EstimatedTokens
+
+		// Add tokens for the tool calls requested by assistant messages
+		for _, call := range msg.ToolCalls {
+			totalTokens += EstimateTokenCount(call.Function.Name).EstimatedTokens
+			totalTokens += EstimateTokenCount(call.Function.Arguments).EstimatedTokens
+		}
 	}
 
 	for _, tool := range messages.Tools {
@@ -56,7 +63,11 @@ func EstimateTokensFromMessages(messages *ChatCompletionRequest) *TokenEstimate
 			totalTokens += EstimateTokenCount(tool.Function.Parameters.Type).EstimatedTokens
 			for key, value := range tool.Function.Parameters.Properties {
 				totalTokens += EstimateTokenCount(key).EstimatedTokens
-				totalTokens += EstimateTokenCount(value.(string)).EstimatedTokens
+				if s, ok := value.(string); ok {
+					totalTokens += EstimateTokenCount(s).EstimatedTokens
+				} else if raw, err := json.Marshal(value); err == nil {
+					totalTokens += EstimateTokenCount(string(raw)).EstimatedTokens
+				}
 			}
 			for _, req := range tool.Function.Parameters.Required {
 				totalTokens += EstimateTokenCount(req).EstimatedTokens
@@ -0,0 +1,386 @@
+package deepseek
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ToolRegistry holds Go functions the model can call and generates their Tool definitions.
+// It is safe for concurrent use.
+type ToolRegistry struct {
+	mu    sync.RWMutex
+	tools map[string]*registeredTool
+	order []string
+}
+
+// registeredTool is a tool definition together with its handler.
+type registeredTool struct {
+	tool     Tool
+	required []string
+	call     func(ctx context.Context, arguments string) (string, error)
+}
+
+// ToolArgumentsError is returned when the arguments of a tool call cannot be decoded.
+// Its message is meant to be sent back to the model so it can correct the call.
+type ToolArgumentsError struct {
+	Tool string // Name of the tool.
+	Err  error  // Underlying decode error.
+}
+
+// Error returns a string representation of the error.
+func (e *ToolArgumentsError) Error() string {
+	return fmt.Sprintf("invalid arguments for tool %q: %v", e.Tool, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *ToolArgumentsError) Unwrap() error {
+	return e.Err
+}
+
+// NewToolRegistry creates an empty ToolRegistry.
+func NewToolRegistry() *ToolRegistry {
+	return &ToolRegistry{tools: make(map[string]*registeredTool)}
+}
+
+// RegisterTool registers fn as a tool. Args must be a struct; its JSON Schema is derived from the struct fields.
+// A string result is sent to the model as is, any other result is encoded as JSON.
+//
+// Supported struct tags besides json:
+//   - description:"..." describes the field.
+//   - enum:"a,b,c" restricts the allowed values.
+//   - required:"true" or required:"false" overrides whether the field is required. By default fields are
+//     required unless they are pointers or tagged omitempty.
+//   - min:"1" and max:"10" set minimum/maximum for numbers, minLength/maxLength for strings and minItems/maxItems for arrays.
+func RegisterTool[Args, Result any](r *ToolRegistry, name, description string, fn func(ctx context.Context, args Args) (Result, error)) error {
+	if name == "" {
+		return fmt.Errorf("tool name cannot be empty")
+	}
+	if fn == nil {
+		return fmt.Errorf("tool function cannot be nil")
+	}
+
+	argsType := reflect.TypeFor[Args]()
+	for argsType.Kind() == reflect.Pointer {
+		argsType = argsType.Elem()
+	}
+	if argsType.Kind() != reflect.Struct {
+		return fmt.Errorf("tool %q: arguments must be a struct, got %s", name, argsType)
+	}
+	schema, err := jsonSchemaFor(argsType, map[reflect.Type]bool{})
+	if err != nil {
+		return fmt.Errorf("tool %q: %w", name, err)
+	}
+	properties, _ := schema["properties"].(map[string]any)
+	required, _ := schema["required"].([]string)
+
+	tool := &registeredTool{
+		tool: Tool{
+			Type: "function",
+			Function: Function{
+				Name:        name,
+				Description: description,
+				Parameters: &FunctionParameters{
+					Type:       "object",
+					Properties: properties,
+					Required:   required,
+				},
+			},
+		},
+		required: required,
+	}
+	tool.call = func(ctx context.Context, arguments string) (string, error) {
+		var args Args
+		if err := decodeToolArguments(arguments, tool.required, &args); err != nil {
+			return "", &ToolArgumentsError{Tool: name, Err: err}
+		}
+		result, err := fn(ctx, args)
+		if err != nil {
+			return "", err
+		}
+		if s, ok := any(result).(string); ok {
+			return s, nil
+		}
+		encoded, err := json.Marshal(result)
+		if err != nil {
+			return "", fmt.Errorf("error encoding result of tool %q: %w", name, err)
+		}
+		return string(encoded), nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, exists := r.tools[name]; exists {
+		return fmt.Errorf("tool %q is already registered", name)
+	}
+	r.tools[name] = tool
+	r.order = append(r.order, name)
+	return nil
+}
+
+// decodeToolArguments decodes the JSON arguments of a tool call, rejecting unknown and missing required fields.
+func decodeToolArguments(arguments string, required []string, target any) error {
+	if strings.TrimSpace(arguments) == "" {
+		arguments = "{}"
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(arguments), &fields); err != nil {
+		return fmt.Errorf("arguments must be a JSON object: %w", err)
+	}
+	for _, name := range required {
+		if _, ok := fields[name]; !ok {
+			return fmt.Errorf("missing required field %q", name)
+		}
+	}
+	decoder := json.NewDecoder(bytes.NewReader([]byte(arguments)))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(target); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Tools returns the Tool definitions of all registered tools in registration order.
+func (r *ToolRegistry) Tools() []Tool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	tools := make([]Tool, 0, len(r.order))
+	for _, name := range r.order {
+		tools = append(tools, r.tools[name].tool)
+	}
+	return tools
+}
+
+// Has reports whether a tool with the given name is registered.
+func (r *ToolRegistry) Has(name string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	_, ok := r.tools[name]
+	return ok
+}
+
+// Call runs the tool requested by the tool call and returns its result.
+// Errors decoding the arguments are returned as *ToolArgumentsError.
+func (r *ToolRegistry) Call(ctx context.Context, call ToolCall) (string, error) {
+	r.mu.RLock()
+	tool, ok := r.tools[call.Function.Name]
+	r.mu.RUnlock()
+	if !ok {
+		return "", fmt.Errorf("unknown tool %q", call.Function.Name)
+	}
+	return tool.call(ctx, call.Function.Arguments)
+}
+
+// Dispatch runs the tool call and returns the tool message to send back to the model.
+// Errors are reported to the model as a JSON object of the form {"error": "..."}.
+func (r *ToolRegistry) Dispatch(ctx context.Context, call ToolCall) ChatCompletionMessage {
+	content, err := r.Call(ctx, call)
+	if err != nil {
+		content = ToolErrorContent(err)
+	}
+	return ChatCompletionMessage{
+		Role:       ChatMessageRoleTool,
+		Content:    content,
+		ToolCallID: call.ID,
+	}
+}
+
+// ToolErrorContent formats an error as tool message content the model can read.
+func ToolErrorContent(err error) string {
+	encoded, _ := json.Marshal(map[string]string{"error": err.Error()})
+	return string(encoded)
+}
+
+var (
+	timeType       = reflect.TypeFor[time.Time]()
+	rawMessageType = reflect.TypeFor[json.RawMessage]()
+)
+
+// jsonSchemaFor builds the JSON Schema of a Go type. seen guards against recursive types.
+func jsonSchemaFor(t reflect.Type, seen map[reflect.Type]bool) (map[string]any, error) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch t {
+	case timeType:
+		return map[string]any{"type": "string", "format": "date-time"}, nil
+	case rawMessageType:
+		return map[string]any{}, nil
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]any{"type": "string"}, nil
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}, nil
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}, nil
+	case reflect.Interface:
+		return map[string]any{}, nil
+	case reflect.Slice, reflect.Array:
+		if t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8 {
+			// encoding/json marshals byte slices as base64 strings.
+			return map[string]any{"type": "string", "contentEncoding": "base64"}, nil
+		}
+		items, err := jsonSchemaFor(t.Elem(), seen)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]any{"type": "array", "items": items}, nil
+	case reflect.Map:
+		if t.Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("unsupported map key type %s", t.Key())
+		}
+		values, err := jsonSchemaFor(t.Elem(), seen)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]any{"type": "object", "additionalProperties": values}, nil
+	case reflect.Struct:
+		if seen[t] {
+			return map[string]any{"type": "object"}, nil
+		}
+		seen[t] = true
+		defer delete(seen, t)
+
+		properties := map[string]any{}
+		var required []string
+		if err := addStructFields(t, properties, &required, seen); err != nil {
+			return nil, err
+		}
+		schema := map[string]any{"type": "object", "properties": properties}
+		if len(required) > 0 {
+			schema["required"] = required
+		}
+		return schema, nil
+	default:
+		return nil, fmt.Errorf("unsupported type %s", t)
+	}
+}
+
+// addStructFields adds the schema of every exported field of t. Embedded structs without a JSON name are flattened.
+func addStructFields(t reflect.Type, properties map[string]any, required *[]string, seen map[reflect.Type]bool) error {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		jsonTag := field.Tag.Get("json")
+		if jsonTag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(jsonTag, ",")
+
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			for embedded.Kind() == reflect.Pointer {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				if err := addStructFields(embedded, properties, required, seen); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		schema, err := jsonSchemaFor(field.Type, seen)
+		if err != nil {
+			return fmt.Errorf("field %s: %w", field.Name, err)
+		}
+		if err := applySchemaTags(schema, field); err != nil {
+			return fmt.Errorf("field %s: %w", field.Name, err)
+		}
+		properties[name] = schema
+
+		isRequired := field.Type.Kind() != reflect.Pointer && !strings.Contains(","+opts+",", ",omitempty,")
+		if tag, ok := field.Tag.Lookup("required"); ok {
+			isRequired, err = strconv.ParseBool(tag)
+			if err != nil {
+				return fmt.Errorf("field %s: invalid required tag %q", field.Name, tag)
+			}
+		}
+		if isRequired {
+			*required = append(*required, name)
+		}
+	}
+	return nil
+}
+
+// applySchemaTags adds the description, enum, min and max tags of a field to its schema.
+func applySchemaTags(schema map[string]any, field reflect.StructField) error {
+	if description := field.Tag.Get("description"); description != "" {
+		schema["description"] = description
+	}
+
+	schemaType, _ := schema["type"].(string)
+	if enum := field.Tag.Get("enum"); enum != "" {
+		var values []any
+		for _, value := range strings.Split(enum, ",") {
+			parsed, err := parseSchemaValue(schemaType, strings.TrimSpace(value))
+			if err != nil {
+				return fmt.Errorf("invalid enum value %q: %w", value, err)
+			}
+			values = append(values, parsed)
+		}
+		schema["enum"] = values
+	}
+
+	for tag, keywords := range map[string][3]string{
+		"min": {"minimum", "minLength", "minItems"},
+		"max": {"maximum", "maxLength", "maxItems"},
+	} {
+		value := field.Tag.Get(tag)
+		if value == "" {
+			continue
+		}
+		var keyword string
+		switch schemaType {
+		case "integer", "number":
+			keyword = keywords[0]
+		case "string":
+			keyword = keywords[1]
+		case "array":
+			keyword = keywords[2]
+		default:
+			return fmt.Errorf("%s tag is not supported for type %q", tag, schemaType)
+		}
+		parsed, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("invalid %s tag %q", tag, value)
+		}
+		if keyword == keywords[0] {
+			schema[keyword] = parsed
+		} else {
+			schema[keyword] = int(parsed)
+		}
+	}
+	return nil
+}
+
+// parseSchemaValue converts an enum value from a struct tag to the JSON type of the field.
+func parseSchemaValue(schemaType, value string) (any, error) {
+	switch schemaType {
+	case "integer":
+		return strconv.ParseInt(value, 10, 64)
+	case "number":
+		return strconv.ParseFloat(value, 64)
+	case "boolean":
+		return strconv.ParseBool(value)
+	default:
+		return value, nil
+	}
+}
@@ -0,0 +1,147 @@
+package deepseek_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/cohesion-org/deepseek-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type weatherArgs struct {
+	City  string   `json:"city" description:"Name of the city" min:"1"`
+	Unit  string   `json:"unit,omitempty" enum:"celsius,fahrenheit"`
+	Days  int      `json:"days" min:"1" max:"7"`
+	Tags  []string `json:"tags,omitempty" max:"3"`
+	Icon  []byte   `json:"icon,omitempty"`
+	Extra *struct {
+		Note string `json:"note"`
+	} `json:"extra"`
+	Ignored string `json:"-"`
+}
+
+type weatherResult struct {
+	City        string `json:"city"`
+	Temperature int    `json:"temperature"`
+}
+
+func newWeatherRegistry(t *testing.T) *deepseek.ToolRegistry {
+	t.Helper()
+	registry := deepseek.NewToolRegistry()
+	err := deepseek.RegisterTool(registry, "get_weather", "Get the weather forecast",
+		func(ctx context.Context, args weatherArgs) (weatherResult, error) {
+			if args.City == "Atlantis" {
+				return weatherResult{}, errors.New("city not found")
+			}
+			return weatherResult{City: args.City, Temperature: 20 + args.Days}, nil
+		})
+	require.NoError(t, err)
+	return registry
+}
+
+func TestToolRegistrySchema(t *testing.T) {
+	registry := newWeatherRegistry(t)
+	tools := registry.Tools()
+	require.Len(t, tools, 1)
+
+	tool := tools[0]
+	assert.Equal(t, "function", tool.Type)
+	assert.Equal(t, "get_weather", tool.Function.Name)
+	require.NotNil(t, tool.Function.Parameters)
+	assert.Equal(t, "object", tool.Function.Parameters.Type)
+	assert.Equal(t, []string{"city", "days"}, tool.Function.Parameters.Required)
+
+	encoded, err := json.Marshal(tool.Function.Parameters.Properties)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"city": {"type": "string", "description": "Name of the city", "minLength": 1},
+		"unit": {"type": "string", "enum": ["celsius", "fahrenheit"]},
+		"days": {"type": "integer", "minimum": 1, "maximum": 7},
+		"tags": {"type": "array", "items": {"type": "string"}, "maxItems": 3},
+		"icon": {"type": "string", "contentEncoding": "base64"},
+		"extra": {"type": "object", "properties": {"note": {"type": "string"}}, "required": ["note"]}
+	}`, string(encoded))
+}
+
+func TestToolRegistryDispatch(t *testing.T) {
+	registry := newWeatherRegistry(t)
+	ctx := context.Background()
+
+	t.Run("success", func(t *testing.T) {
+		msg := registry.Dispatch(ctx, deepseek.ToolCall{
+			ID:       "call_1",
+			Function: deepseek.ToolCallFunction{Name: "get_weather", Arguments: `{"city":"Paris","days":2}`},
+		})
+		assert.Equal(t, deepseek.ChatMessageRoleTool, msg.Role)
+		assert.Equal(t, "call_1", msg.ToolCallID)
+		assert.JSONEq(t, `{"city":"Paris","temperature":22}`, msg.Content)
+	})
+
+	t.Run("missing required field", func(t *testing.T) {
+		_, err := registry.Call(ctx, deepseek.ToolCall{
+			Function: deepseek.ToolCallFunction{Name: "get_weather", Arguments: `{"city":"Paris"}`},
+		})
+		var argsErr *deepseek.ToolArgumentsError
+		require.ErrorAs(t, err, &argsErr)
+		assert.Contains(t, err.Error(), `"days"`)
+	})
+
+	t.Run("invalid arguments are reported to the model", func(t *testing.T) {
+		msg := registry.Dispatch(ctx, deepseek.ToolCall{
+			ID:       "call_2",
+			Function: deepseek.ToolCallFunction{Name: "get_weather", Arguments: `{"city":"Paris","days":"two"}`},
+		})
+		var content map[string]string
+		require.NoError(t, json.Unmarshal([]byte(msg.Content), &content))
+		assert.Contains(t, content["error"], "invalid arguments for tool")
+	})
+
+	t.Run("unknown field", func(t *testing.T) {
+		_, err := registry.Call(ctx, deepseek.ToolCall{
+			Function: deepseek.ToolCallFunction{Name: "get_weather", Arguments: `{"city":"Paris","days":1,"country":"FR"}`},
+		})
+		var argsErr *deepseek.ToolArgumentsError
+		require.ErrorAs(t, err, &argsErr)
+	})
+
+	t.Run("tool error", func(t *testing.T) {
+		msg := registry.Dispatch(ctx, deepseek.ToolCall{
+			Function: deepseek.ToolCallFunction{Name: "get_weather", Arguments: `{"city":"Atlantis","days":1}`},
+		})
+		assert.JSONEq(t, `{"error":"city not found"}`, msg.Content)
+	})
+
+	t.Run("unknown tool", func(t *testing.T) {
+		_, err := registry.Call(ctx, deepseek.ToolCall{Function: deepseek.ToolCallFunction{Name: "nope"}})
+		require.Error(t, err)
+	})
+}
+
+func TestRegisterToolErrors(t *testing.T) {
+	registry := newWeatherRegistry(t)
+
+	err := deepseek.RegisterTool(registry, "get_weather", "duplicate",
+		func(ctx context.Context, args weatherArgs) (string, error) { return "", nil })
+	require.Error(t, err)
+
+	err = deepseek.RegisterTool(registry, "bad", "not a struct",
+		func(ctx context.Context, args string) (string, error) { return "", nil })
+	require.Error(t, err)
+
+	type badTags struct {
+		Flag bool `json:"flag" min:"1"`
+	}
+	err = deepseek.RegisterTool(registry, "bad_tags", "min on bool",
+		func(ctx context.Context, args badTags) (string, error) { return "", nil })
+	require.Error(t, err)
+}
+
+func TestToolRegistryWithEstimate(t *testing.T) {
+	// Generated schemas contain nested maps and must not break token estimation.
+	registry := newWeatherRegistry(t)
+	estimate := deepseek.EstimateTokensFromMessages(&deepseek.ChatCompletionRequest{Tools: registry.Tools()})
+	assert.Greater(t, estimate.EstimatedTokens, 0)
+}
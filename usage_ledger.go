@@ -0,0 +1,369 @@
+package deepseek
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// usageLabelsKey is the context key of the usage labels.
+type usageLabelsKey struct{}
+
+// ContextWithUsageLabels returns a context that attaches labels, such as {"feature": "search"}, to every call
+// recorded in a UsageLedger. Labels already attached to ctx are kept unless they are overwritten.
+func ContextWithUsageLabels(ctx context.Context, labels map[string]string) context.Context {
+	merged := maps.Clone(UsageLabelsFromContext(ctx))
+	if merged == nil {
+		merged = make(map[string]string, len(labels))
+	}
+	maps.Copy(merged, labels)
+	return context.WithValue(ctx, usageLabelsKey{}, merged)
+}
+
+// UsageLabelsFromContext returns the usage labels attached to ctx.
+func UsageLabelsFromContext(ctx context.Context) map[string]string {
+	labels, _ := ctx.Value(usageLabelsKey{}).(map[string]string)
+	return maps.Clone(labels)
+}
+
+// UsageRecord is the usage of a single call.
+type UsageRecord struct {
+	Time      time.Time         `json:"time"`             // When the call finished.
+	Operation Operation         `json:"operation"`        // Kind of call.
+	Model     string            `json:"model"`            // Model of the request.
+	Labels    map[string]string `json:"labels,omitempty"` // Labels attached with ContextWithUsageLabels.
+	Usage     Usage             `json:"usage"`            // Tokens used.
+	Cost      *Cost             `json:"cost,omitempty"`   // Price of the call. Nil if the model has no known pricing.
+}
+
+// UsageLedger records the usage of every call made by a client. It is safe for concurrent use.
+// Records are kept in memory; set MaxRecords or MaxAge to bound the ledger of a long-running process.
+type UsageLedger struct {
+	MaxRecords int           // Maximum number of records kept. The oldest are dropped first. Zero keeps every record.
+	MaxAge     time.Duration // Records older than this are dropped when a call is recorded. Zero keeps every record.
+
+	mu      sync.Mutex
+	records []UsageRecord
+}
+
+// NewUsageLedger creates an empty ledger.
+func NewUsageLedger() *UsageLedger {
+	return &UsageLedger{}
+}
+
+// WithUsageLedger records the usage of every call made by the client in ledger.
+// Streams always ask for their usage with stream_options.include_usage, so it can be recorded.
+func WithUsageLedger(ledger *UsageLedger) Option {
+	return func(c *Client) error {
+		if ledger == nil {
+			return fmt.Errorf("usage ledger cannot be nil")
+		}
+		c.Ledger = ledger
+		return nil
+	}
+}
+
+// Add records a call and drops the records beyond MaxRecords or older than MaxAge.
+func (l *UsageLedger) Add(record UsageRecord) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.records = append(l.records, record)
+
+	// Reslicing drops the records without copying; append moves the rest to a new array once the old one is full.
+	if l.MaxAge > 0 {
+		cutoff := time.Now().Add(-l.MaxAge)
+		n := 0
+		for n < len(l.records) && l.records[n].Time.Before(cutoff) {
+			n++
+		}
+		l.records = l.records[n:]
+	}
+	if l.MaxRecords > 0 && len(l.records) > l.MaxRecords {
+		l.records = l.records[len(l.records)-l.MaxRecords:]
+	}
+}
+
+// Records returns the recorded calls matching filter, oldest first.
+func (l *UsageLedger) Records(filter UsageFilter) []UsageRecord {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var out []UsageRecord
+	for _, r := range l.records {
+		if filter.matches(r) {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+// Reset removes all records.
+func (l *UsageLedger) Reset() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.records = nil
+}
+
+// Report aggregates the records matching filter. If group is not nil, the totals are also split into groups.
+func (l *UsageLedger) Report(filter UsageFilter, group UsageGrouping) *UsageReport {
+	report := &UsageReport{}
+	groups := make(map[string]*UsageTotals)
+	for _, r := range l.Records(filter) {
+		report.Total.add(r)
+		if group == nil {
+			continue
+		}
+		key := group(r)
+		totals, ok := groups[key]
+		if !ok {
+			totals = &UsageTotals{}
+			groups[key] = totals
+		}
+		totals.add(r)
+	}
+	for _, key := range slices.Sorted(maps.Keys(groups)) {
+		report.Groups = append(report.Groups, UsageGroup{Key: key, UsageTotals: *groups[key]})
+	}
+	return report
+}
+
+// UsageFilter selects records of a UsageLedger. Zero fields match every record.
+type UsageFilter struct {
+	From   time.Time         // Only records at or after From.
+	To     time.Time         // Only records before To.
+	Model  string            // Only records of this model.
+	Labels map[string]string // Only records carrying all of these labels.
+}
+
+// matches reports whether r is selected by the filter.
+func (f UsageFilter) matches(r UsageRecord) bool {
+	if !f.From.IsZero() && r.Time.Before(f.From) {
+		return false
+	}
+	if !f.To.IsZero() && !r.Time.Before(f.To) {
+		return false
+	}
+	if f.Model != "" && r.Model != f.Model {
+		return false
+	}
+	for k, v := range f.Labels {
+		if r.Labels[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// UsageGrouping returns the group a record belongs to.
+type UsageGrouping func(record UsageRecord) string
+
+// UsageWindow is the length of a reporting period.
+type UsageWindow int
+
+// Reporting periods. Periods are aligned to UTC.
+const (
+	UsageWindowHour UsageWindow = iota + 1
+	UsageWindowDay
+	UsageWindowMonth
+)
+
+// GroupByModel groups records by model.
+func GroupByModel() UsageGrouping {
+	return func(r UsageRecord) string { return r.Model }
+}
+
+// GroupByLabel groups records by the value of a label. Records without the label form the group "".
+func GroupByLabel(key string) UsageGrouping {
+	return func(r UsageRecord) string { return r.Labels[key] }
+}
+
+// GroupByWindow groups records by period. The keys are formatted as 2006-01-02T15, 2006-01-02 or 2006-01.
+func GroupByWindow(window UsageWindow) UsageGrouping {
+	layout := "2006-01"
+	switch window {
+	case UsageWindowHour:
+		layout = "2006-01-02T15"
+	case UsageWindowDay:
+		layout = "2006-01-02"
+	}
+	return func(r UsageRecord) string { return r.Time.UTC().Format(layout) }
+}
+
+// GroupByAll combines groupings. The keys are joined with "/", for example "2025-01/search".
+func GroupByAll(groupings ...UsageGrouping) UsageGrouping {
+	return func(r UsageRecord) string {
+		keys := make([]string, len(groupings))
+		for i, g := range groupings {
+			keys[i] = g(r)
+		}
+		return strings.Join(keys, "/")
+	}
+}
+
+// UsageTotals is the aggregated usage of a number of calls.
+type UsageTotals struct {
+	Requests         int                `json:"requests"`          // Number of calls.
+	Usage            Usage              `json:"usage"`             // Sum of the tokens used.
+	Cost             map[string]float64 `json:"cost,omitempty"`    // Sum of the prices by currency.
+	UnpricedRequests int                `json:"unpriced_requests"` // Calls whose price is unknown and not part of Cost.
+}
+
+// add adds a record to the totals.
+func (t *UsageTotals) add(r UsageRecord) {
+	t.Requests++
+	t.Usage = addUsage(t.Usage, r.Usage)
+	if r.Cost == nil {
+		t.UnpricedRequests++
+		return
+	}
+	if t.Cost == nil {
+		t.Cost = make(map[string]float64)
+	}
+	t.Cost[r.Cost.Currency] += r.Cost.Total
+}
+
+// UsageGroup is the aggregated usage of one group of a report.
+type UsageGroup struct {
+	Key string `json:"key"` // The group key returned by the UsageGrouping.
+	UsageTotals
+}
+
+// UsageReport is the aggregated usage returned by UsageLedger.Report.
+type UsageReport struct {
+	Total  UsageTotals  `json:"total"`            // Totals of all selected records.
+	Groups []UsageGroup `json:"groups,omitempty"` // Totals by group, sorted by key.
+}
+
+// WriteJSON writes the report as indented JSON.
+func (r *UsageReport) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// WriteCSV writes one row per group and currency, with a header row. Without groups, the total is written
+// as a single group with an empty key.
+func (r *UsageReport) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	header := []string{"group", "requests", "prompt_tokens", "completion_tokens", "reasoning_tokens",
+		"cache_hit_tokens", "cache_miss_tokens", "total_tokens", "unpriced_requests", "currency", "cost"}
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+
+	groups := r.Groups
+	if len(groups) == 0 {
+		groups = []UsageGroup{{UsageTotals: r.Total}}
+	}
+	for _, g := range groups {
+		u := g.Usage
+		row := []string{g.Key, strconv.Itoa(g.Requests), strconv.Itoa(u.PromptTokens), strconv.Itoa(u.CompletionTokens),
+			strconv.Itoa(u.CompletionTokensDetails.ReasoningTokens), strconv.Itoa(u.PromptCacheHitTokens),
+			strconv.Itoa(u.PromptCacheMissTokens), strconv.Itoa(u.TotalTokens), strconv.Itoa(g.UnpricedRequests)}
+		if len(g.Cost) == 0 {
+			if err := cw.Write(append(row, "", "")); err != nil {
+				return err
+			}
+			continue
+		}
+		currencies := make([]string, 0, len(g.Cost))
+		for currency := range g.Cost {
+			currencies = append(currencies, currency)
+		}
+		sort.Strings(currencies)
+		for _, currency := range currencies {
+			cost := strconv.FormatFloat(g.Cost[currency], 'f', 6, 64)
+			if err := cw.Write(append(slices.Clone(row), currency, cost)); err != nil {
+				return err
+			}
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// recordUsage adds a call to the client's ledger, budget guard and key pool, if it has them.
+func (c *Client) recordUsage(ctx context.Context, operation Operation, model string, usage Usage) {
+	if c.Keys != nil {
+		c.Keys.addUsage(ctx, usage)
+	}
+	if c.Ledger == nil && c.Budget == nil {
+		return
+	}
+	cost, err := c.EstimateCost(model, usage)
+	if c.Budget != nil && err == nil {
+		c.Budget.Spend(cost)
+	}
+	if c.Ledger == nil {
+		return
+	}
+	record := UsageRecord{
+		Time:      time.Now(),
+		Operation: operation,
+		Model:     model,
+		Labels:    UsageLabelsFromContext(ctx),
+		Usage:     usage,
+	}
+	if err == nil {
+		record.Cost = &cost
+	}
+	c.Ledger.Add(record)
+}
+
+// tracksUsage reports whether the client records the usage of its calls. Its streams then always ask for
+// the usage with stream_options.include_usage, whatever the request sets.
+func (c *Client) tracksUsage() bool {
+	return c.Ledger != nil || c.Budget != nil || c.Keys != nil
+}
+
+// usageRecordingChatStream records the usage reported in the last chunk of a chat completion stream.
+type usageRecordingChatStream struct {
+	ChatCompletionStream
+	record   func(Usage)
+	recorded bool
+}
+
+// Recv receives the next chunk and records its usage, if it has any.
+func (s *usageRecordingChatStream) Recv() (*StreamChatCompletionResponse, error) {
+	chunk, err := s.ChatCompletionStream.Recv()
+	if err == nil && !s.recorded && chunk.Usage != nil && chunk.Usage.TotalTokens > 0 {
+		s.recorded = true
+		s.record(chunk.Usage.toUsage())
+	}
+	return chunk, err
+}
+
+// cancelStream cancels the request of the wrapped stream.
+func (s *usageRecordingChatStream) cancelStream() bool {
+	return cancelStream(s.ChatCompletionStream)
+}
+
+// usageRecordingFIMStream records the usage reported in the last chunk of a FIM completion stream.
+type usageRecordingFIMStream struct {
+	FIMChatCompletionStream
+	record   func(Usage)
+	recorded bool
+}
+
+// FIMRecv receives the next chunk and records its usage, if it has any.
+func (s *usageRecordingFIMStream) FIMRecv() (*FIMStreamCompletionResponse, error) {
+	chunk, err := s.FIMChatCompletionStream.FIMRecv()
+	if err == nil && !s.recorded && chunk.Usage != nil && chunk.Usage.TotalTokens > 0 {
+		s.recorded = true
+		s.record(chunk.Usage.toUsage())
+	}
+	return chunk, err
+}
+
+// cancelStream cancels the request of the wrapped stream.
+func (s *usageRecordingFIMStream) cancelStream() bool {
+	return cancelStream(s.FIMChatCompletionStream)
+}
@@ -0,0 +1,178 @@
+package deepseek_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cohesion-org/deepseek-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUsageLedgerRecordsCalls(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		if body["stream"] == true {
+			fmt.Fprint(w, "data: {\"id\":\"s\",\"choices\":[{\"index\":0,\"delta\":{\"content\":\"hi\"}}]}\n\n")
+			// The usage is only sent when it is asked for, which the client does whenever it has a ledger.
+			if options, _ := body["stream_options"].(map[string]any); options["include_usage"] != true {
+				fmt.Fprint(w, "data: [DONE]\n\n")
+				return
+			}
+			fmt.Fprint(w, "data: {\"id\":\"s\",\"choices\":[],\"usage\":{\"prompt_tokens\":10,\"completion_tokens\":20,\"total_tokens\":30,"+
+				"\"prompt_cache_hit_tokens\":4,\"prompt_cache_miss_tokens\":6,\"completion_tokens_details\":{\"reasoning_tokens\":15}}}\n\n")
+			fmt.Fprint(w, "data: [DONE]\n\n")
+			return
+		}
+		w.Write([]byte(retryChatResponse))
+	}))
+	defer ts.Close()
+
+	ledger := deepseek.NewUsageLedger()
+	client, err := deepseek.NewClientWithOptions("token", deepseek.WithBaseURL(ts.URL+"/"), deepseek.WithUsageLedger(ledger))
+	require.NoError(t, err)
+
+	ctx := deepseek.ContextWithUsageLabels(context.Background(), map[string]string{"feature": "search"})
+	_, err = client.CreateChatCompletion(ctx, &deepseek.ChatCompletionRequest{
+		Model:    deepseek.DeepSeekChat,
+		Messages: []deepseek.ChatCompletionMessage{{Role: deepseek.ChatMessageRoleUser, Content: "hi"}},
+	})
+	require.NoError(t, err)
+
+	ctx = deepseek.ContextWithUsageLabels(ctx, map[string]string{"user": "42"})
+	stream, err := client.CreateChatCompletionStream(ctx, &deepseek.StreamChatCompletionRequest{
+		Model:    deepseek.DeepSeekReasoner,
+		Messages: []deepseek.ChatCompletionMessage{{Role: deepseek.ChatMessageRoleUser, Content: "hi"}},
+	})
+	require.NoError(t, err)
+	_, err = deepseek.CollectStream(stream)
+	require.NoError(t, err)
+
+	records := ledger.Records(deepseek.UsageFilter{})
+	require.Len(t, records, 2)
+	assert.Equal(t, deepseek.OperationChatCompletion, records[0].Operation)
+	assert.Equal(t, map[string]string{"feature": "search"}, records[0].Labels)
+	require.NotNil(t, records[0].Cost)
+
+	assert.Equal(t, deepseek.OperationChatCompletionStream, records[1].Operation)
+	assert.Equal(t, deepseek.DeepSeekReasoner, records[1].Model)
+	assert.Equal(t, map[string]string{"feature": "search", "user": "42"}, records[1].Labels)
+	assert.Equal(t, 15, records[1].Usage.CompletionTokensDetails.ReasoningTokens)
+	assert.Equal(t, 4, records[1].Usage.PromptCacheHitTokens)
+
+	report := ledger.Report(deepseek.UsageFilter{Labels: map[string]string{"user": "42"}}, nil)
+	assert.Equal(t, 1, report.Total.Requests)
+	assert.Equal(t, 30, report.Total.Usage.TotalTokens)
+
+	image := deepseek.NewImageMessage(deepseek.ChatMessageRoleUser, "describe", "data:image/png;base64,AAAA")
+	_, err = client.CreateChatCompletionWithImage(ctx, &deepseek.ChatCompletionRequestWithImage{
+		Model:    deepseek.DeepSeekChat,
+		Messages: []deepseek.ChatCompletionMessageWithImage{image},
+	})
+	require.NoError(t, err)
+	imageStream, err := client.CreateChatCompletionStreamWithImage(ctx, &deepseek.StreamChatCompletionRequestWithImage{
+		Model:    deepseek.DeepSeekChat,
+		Messages: []deepseek.ChatCompletionMessageWithImage{image},
+	})
+	require.NoError(t, err)
+	_, err = deepseek.CollectStream(imageStream)
+	require.NoError(t, err)
+
+	records = ledger.Records(deepseek.UsageFilter{})
+	require.Len(t, records, 4, "image calls are recorded too")
+	assert.Equal(t, deepseek.OperationChatCompletionWithImage, records[2].Operation)
+	assert.Equal(t, deepseek.OperationChatCompletionStreamWithImage, records[3].Operation)
+	assert.Equal(t, 30, records[3].Usage.TotalTokens)
+}
+
+func TestUsageLedgerRetention(t *testing.T) {
+	ledger := deepseek.NewUsageLedger()
+	ledger.MaxRecords = 2
+	for i := 1; i <= 3; i++ {
+		ledger.Add(deepseek.UsageRecord{Time: time.Now(), Model: fmt.Sprint(i)})
+	}
+	records := ledger.Records(deepseek.UsageFilter{})
+	require.Len(t, records, 2)
+	assert.Equal(t, "2", records[0].Model, "the oldest record is dropped")
+
+	ledger = deepseek.NewUsageLedger()
+	ledger.MaxAge = time.Hour
+	ledger.Add(deepseek.UsageRecord{Time: time.Now().Add(-2 * time.Hour), Model: "old"})
+	ledger.Add(deepseek.UsageRecord{Time: time.Now(), Model: "new"})
+	records = ledger.Records(deepseek.UsageFilter{})
+	require.Len(t, records, 1)
+	assert.Equal(t, "new", records[0].Model)
+}
+
+func TestUsageLedgerReport(t *testing.T) {
+	ledger := deepseek.NewUsageLedger()
+	jan := time.Date(2025, 1, 15, 10, 0, 0, 0, time.UTC)
+	feb := time.Date(2025, 2, 3, 10, 0, 0, 0, time.UTC)
+	usd := func(total float64) *deepseek.Cost { return &deepseek.Cost{Total: total, Currency: "USD"} }
+
+	ledger.Add(deepseek.UsageRecord{Time: jan, Model: "a", Labels: map[string]string{"feature": "search"},
+		Usage: deepseek.Usage{TotalTokens: 10}, Cost: usd(1)})
+	ledger.Add(deepseek.UsageRecord{Time: jan.Add(time.Hour), Model: "b", Labels: map[string]string{"feature": "chat"},
+		Usage: deepseek.Usage{TotalTokens: 20}, Cost: usd(2)})
+	ledger.Add(deepseek.UsageRecord{Time: feb, Model: "a", Labels: map[string]string{"feature": "search"},
+		Usage: deepseek.Usage{TotalTokens: 30}, Cost: usd(3)})
+	ledger.Add(deepseek.UsageRecord{Time: feb, Model: "c", Usage: deepseek.Usage{TotalTokens: 40}})
+
+	report := ledger.Report(deepseek.UsageFilter{}, deepseek.GroupByAll(
+		deepseek.GroupByWindow(deepseek.UsageWindowMonth), deepseek.GroupByLabel("feature")))
+	assert.Equal(t, 4, report.Total.Requests)
+	assert.Equal(t, 100, report.Total.Usage.TotalTokens)
+	assert.InDelta(t, 6.0, report.Total.Cost["USD"], 1e-9)
+	assert.Equal(t, 1, report.Total.UnpricedRequests)
+
+	keys := make([]string, 0, len(report.Groups))
+	for _, g := range report.Groups {
+		keys = append(keys, g.Key)
+	}
+	assert.Equal(t, []string{"2025-01/chat", "2025-01/search", "2025-02/", "2025-02/search"}, keys)
+
+	byModel := ledger.Report(deepseek.UsageFilter{From: feb}, deepseek.GroupByModel())
+	require.Len(t, byModel.Groups, 2)
+	assert.Equal(t, "a", byModel.Groups[0].Key)
+	assert.Equal(t, 30, byModel.Groups[0].Usage.TotalTokens)
+
+	byDay := ledger.Report(deepseek.UsageFilter{To: feb}, deepseek.GroupByWindow(deepseek.UsageWindowDay))
+	require.Len(t, byDay.Groups, 1)
+	assert.Equal(t, "2025-01-15", byDay.Groups[0].Key)
+	assert.Equal(t, 2, byDay.Groups[0].Requests)
+
+	var csvOut bytes.Buffer
+	require.NoError(t, byModel.WriteCSV(&csvOut))
+	assert.Equal(t, strings.Join([]string{
+		"group,requests,prompt_tokens,completion_tokens,reasoning_tokens,cache_hit_tokens,cache_miss_tokens,total_tokens,unpriced_requests,currency,cost",
+		"a,1,0,0,0,0,0,30,0,USD,3.000000",
+		"c,1,0,0,0,0,0,40,1,,",
+		"",
+	}, "\n"), csvOut.String())
+
+	var jsonOut bytes.Buffer
+	require.NoError(t, byModel.WriteJSON(&jsonOut))
+	var decoded deepseek.UsageReport
+	require.NoError(t, json.Unmarshal(jsonOut.Bytes(), &decoded))
+	assert.Equal(t, *byModel, decoded)
+
+	ledger.Reset()
+	assert.Empty(t, ledger.Records(deepseek.UsageFilter{}))
+}
+
+func TestUsageLabelsAreCopied(t *testing.T) {
+	labels := map[string]string{"feature": "search"}
+	ctx := deepseek.ContextWithUsageLabels(context.Background(), labels)
+	labels["feature"] = "changed"
+	deepseek.UsageLabelsFromContext(ctx)["feature"] = "changed"
+	assert.Equal(t, map[string]string{"feature": "search"}, deepseek.UsageLabelsFromContext(ctx))
+	assert.Nil(t, deepseek.UsageLabelsFromContext(context.Background()))
+}
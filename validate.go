@@ -0,0 +1,327 @@
+package deepseek
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// fimMaxTokens is the largest max_tokens accepted by the FIM completion endpoint.
+const fimMaxTokens = 4000
+
+// maxTopLogProbs is the largest top_logprobs (and FIM logprobs) accepted by the API.
+const maxTopLogProbs = 20
+
+// ErrInvalidRequest is matched by the *ValidationError returned when a request fails validation.
+var ErrInvalidRequest = errors.New("invalid request")
+
+// FieldError is a single validation failure.
+type FieldError struct {
+	Field   string // Path of the offending field as in the request body, e.g. "messages[2].tool_call_id".
+	Message string // What is wrong with it.
+}
+
+// Error returns the field and the message.
+func (e FieldError) Error() string {
+	return e.Field + ": " + e.Message
+}
+
+// ValidationError lists every problem found in a request, in the order of the request's fields.
+type ValidationError struct {
+	Errors []FieldError
+}
+
+// Error returns all failures separated by semicolons.
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Errors))
+	for i, fe := range e.Errors {
+		msgs[i] = fe.Error()
+	}
+	return "invalid request: " + strings.Join(msgs, "; ")
+}
+
+// Is reports whether target is ErrInvalidRequest.
+func (e *ValidationError) Is(target error) bool {
+	return target == ErrInvalidRequest
+}
+
+// WithRequestValidation validates every request before it is sent and returns a *ValidationError
+// instead of calling the API when a request is invalid.
+func WithRequestValidation() Option {
+	return func(c *Client) error {
+		c.ValidateRequests = true
+		return nil
+	}
+}
+
+// Validate checks the request against the API's parameter ranges and the capabilities of its model
+// in the default model registry.
+func (r *ChatRequest) Validate() error {
+	return r.ValidateWith(DefaultModelRegistry())
+}
+
+// ValidateWith checks the request like Validate, looking up the model in models.
+func (r *ChatRequest) ValidateWith(models *ModelRegistry) error {
+	v := newRequestValidator(models, r.Model)
+	v.sampling(float64(r.Temperature), float64(r.TopP), float64(r.PresencePenalty), float64(r.FrequencyPenalty))
+	v.maxTokens(r.MaxTokens)
+	v.logProbs(r.LogProbs, r.TopLogProbs)
+	v.tools(r.Tools)
+	v.jsonMode(r.JSONMode || isJSONFormat(r.ResponseFormat))
+	v.messages(r.Messages)
+	return v.err()
+}
+
+// Validate checks the request against the API's parameter ranges and the capabilities of its model
+// in the default model registry.
+func (r *ChatCompletionRequest) Validate() error {
+	return r.ValidateWith(DefaultModelRegistry())
+}
+
+// ValidateWith checks the request like Validate, looking up the model in models.
+func (r *ChatCompletionRequest) ValidateWith(models *ModelRegistry) error {
+	return r.ToChatRequest().ValidateWith(models)
+}
+
+// Validate checks the request against the API's parameter ranges and the capabilities of its model
+// in the default model registry.
+func (r *StreamChatCompletionRequest) Validate() error {
+	return r.ValidateWith(DefaultModelRegistry())
+}
+
+// ValidateWith checks the request like Validate, looking up the model in models.
+func (r *StreamChatCompletionRequest) ValidateWith(models *ModelRegistry) error {
+	return r.ToChatRequest().ValidateWith(models)
+}
+
+// Validate checks the request against the API's parameter ranges and the capabilities of its model
+// in the default model registry.
+func (r *ChatCompletionRequestWithImage) Validate() error {
+	return r.ValidateWith(DefaultModelRegistry())
+}
+
+// ValidateWith checks the request like Validate, looking up the model in models.
+func (r *ChatCompletionRequestWithImage) ValidateWith(models *ModelRegistry) error {
+	return r.ToChatRequest().ValidateWith(models)
+}
+
+// Validate checks the request against the API's parameter ranges and the capabilities of its model
+// in the default model registry.
+func (r *StreamChatCompletionRequestWithImage) Validate() error {
+	return r.ValidateWith(DefaultModelRegistry())
+}
+
+// ValidateWith checks the request like Validate, looking up the model in models.
+func (r *StreamChatCompletionRequestWithImage) ValidateWith(models *ModelRegistry) error {
+	return r.ToChatRequest().ValidateWith(models)
+}
+
+// Validate checks the request against the API's parameter ranges and the capabilities of its model
+// in the default model registry.
+func (r *FIMCompletionRequest) Validate() error {
+	return r.ValidateWith(DefaultModelRegistry())
+}
+
+// ValidateWith checks the request like Validate, looking up the model in models.
+func (r *FIMCompletionRequest) ValidateWith(models *ModelRegistry) error {
+	v := newRequestValidator(models, r.Model)
+	v.fim(r.Prompt, r.MaxTokens, r.Logprobs)
+	v.sampling(r.Temperature, r.TopP, r.PresencePenalty, r.FrequencyPenalty)
+	return v.err()
+}
+
+// Validate checks the request against the API's parameter ranges and the capabilities of its model
+// in the default model registry.
+func (r *FIMStreamCompletionRequest) Validate() error {
+	return r.ValidateWith(DefaultModelRegistry())
+}
+
+// ValidateWith checks the request like Validate, looking up the model in models.
+func (r *FIMStreamCompletionRequest) ValidateWith(models *ModelRegistry) error {
+	v := newRequestValidator(models, r.Model)
+	v.fim(r.Prompt, r.MaxTokens, r.Logprobs)
+	v.sampling(r.Temperature, r.TopP, r.PresencePenalty, r.FrequencyPenalty)
+	return v.err()
+}
+
+// requestValidator collects the failures of one request.
+type requestValidator struct {
+	model  string
+	caps   ModelCapabilities
+	known  bool // Whether the model is in the registry. Capability checks are skipped otherwise.
+	errors []FieldError
+}
+
+// newRequestValidator creates a validator for a request to model.
+func newRequestValidator(models *ModelRegistry, model string) *requestValidator {
+	v := &requestValidator{model: model}
+	if model == "" {
+		v.add("model", "is required")
+	} else if models != nil {
+		v.caps, v.known = models.Lookup(model)
+	}
+	return v
+}
+
+// add records a failure.
+func (v *requestValidator) add(field, format string, args ...any) {
+	v.errors = append(v.errors, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+// unsupported records a failure for a feature the model does not support.
+func (v *requestValidator) unsupported(field, feature string) {
+	if v.known {
+		v.add(field, "model %q does not support %s", v.model, feature)
+	}
+}
+
+// err returns the collected failures as a *ValidationError, or nil if there are none.
+func (v *requestValidator) err() error {
+	if len(v.errors) == 0 {
+		return nil
+	}
+	return &ValidationError{Errors: v.errors}
+}
+
+// sampling checks the ranges of the sampling parameters.
+func (v *requestValidator) sampling(temperature, topP, presencePenalty, frequencyPenalty float64) {
+	v.inRange(ParamTemperature, temperature, 0, 2)
+	v.inRange(ParamTopP, topP, 0, 1)
+	v.inRange(ParamPresencePenalty, presencePenalty, -2, 2)
+	v.inRange(ParamFrequencyPenalty, frequencyPenalty, -2, 2)
+}
+
+// inRange checks that value is within [min, max].
+func (v *requestValidator) inRange(field string, value, min, max float64) {
+	if value < min || value > max {
+		v.add(field, "%g is out of range, must be between %g and %g", value, min, max)
+	}
+}
+
+// maxTokens checks max_tokens against the model's output limit.
+func (v *requestValidator) maxTokens(maxTokens int) {
+	switch {
+	case maxTokens < 0:
+		v.add("max_tokens", "cannot be negative")
+	case v.known && v.caps.MaxOutputTokens > 0 && maxTokens > v.caps.MaxOutputTokens:
+		v.add("max_tokens", "%d exceeds the limit of %d for model %q", maxTokens, v.caps.MaxOutputTokens, v.model)
+	}
+}
+
+// logProbs checks logprobs and top_logprobs.
+func (v *requestValidator) logProbs(logProbs bool, topLogProbs int) {
+	if topLogProbs < 0 || topLogProbs > maxTopLogProbs {
+		v.add("top_logprobs", "%d is out of range, must be between 0 and %d", topLogProbs, maxTopLogProbs)
+	}
+	if topLogProbs > 0 && !logProbs {
+		v.add("top_logprobs", "requires logprobs to be true")
+	}
+	if (logProbs || topLogProbs > 0) && !v.caps.LogProbs {
+		v.unsupported("logprobs", "log probabilities")
+	}
+}
+
+// tools checks the tool definitions.
+func (v *requestValidator) tools(tools []Tool) {
+	if len(tools) > 0 && !v.caps.Tools {
+		v.unsupported("tools", "function calling")
+	}
+	for i, tool := range tools {
+		if tool.Function.Name == "" {
+			v.add(fmt.Sprintf("tools[%d].function.name", i), "is required")
+		}
+	}
+}
+
+// jsonMode checks the JSON output mode.
+func (v *requestValidator) jsonMode(enabled bool) {
+	if enabled && !v.caps.JSONMode {
+		v.unsupported("response_format", "JSON output")
+	}
+}
+
+// fim checks the FIM specific fields.
+func (v *requestValidator) fim(prompt string, maxTokens, logprobs int) {
+	if !v.caps.FIM {
+		v.unsupported("model", "FIM completion")
+	}
+	if prompt == "" {
+		v.add("prompt", "is required")
+	}
+	if maxTokens < 0 || maxTokens > fimMaxTokens {
+		v.add("max_tokens", "%d is out of range, must be between 0 and %d", maxTokens, fimMaxTokens)
+	}
+	if logprobs < 0 || logprobs > maxTopLogProbs {
+		v.add("logprobs", "%d is out of range, must be between 0 and %d", logprobs, maxTopLogProbs)
+	}
+}
+
+// messages checks roles, the prefix message and that every tool message answers an earlier tool call.
+func (v *requestValidator) messages(messages []ChatMessage) {
+	if len(messages) == 0 {
+		v.add("messages", "at least one message is required")
+		return
+	}
+	calls := make(map[string]bool)
+	images := false
+	for i, m := range messages {
+		field := fmt.Sprintf("messages[%d]", i)
+		switch m.Role {
+		case ChatMessageRoleSystem, ChatMessageRoleUser, ChatMessageRoleAssistant, ChatMessageRoleTool:
+		default:
+			v.add(field+".role", "unknown role %q", m.Role)
+		}
+
+		if m.Prefix {
+			if i != len(messages)-1 || m.Role != ChatMessageRoleAssistant {
+				v.add(field+".prefix", "only the last message may be a prefix, and it must be an assistant message")
+			} else if !v.caps.PrefixCompletion {
+				v.unsupported(field+".prefix", "Chat Prefix Completion")
+			}
+		}
+
+		for _, call := range m.ToolCalls {
+			calls[call.ID] = true
+		}
+		if m.Role == ChatMessageRoleTool {
+			switch {
+			case m.ToolCallID == "":
+				v.add(field+".tool_call_id", "is required for tool messages")
+			case !calls[m.ToolCallID]:
+				v.add(field+".tool_call_id", "%q does not match a tool call of an earlier assistant message", m.ToolCallID)
+			}
+		}
+		images = images || m.hasImage()
+	}
+	if images && !v.caps.Images {
+		v.unsupported("messages", "image content")
+	}
+}
+
+// isJSONFormat reports whether the response format requests a JSON object.
+func isJSONFormat(format *ResponseFormat) bool {
+	return format != nil && format.Type == "json_object"
+}
+
+// validateCall validates the request of a call if the client validates requests.
+func (c *Client) validateCall(call *Call) error {
+	if !c.ValidateRequests {
+		return nil
+	}
+	models := c.modelRegistry()
+	switch {
+	case call.ChatRequest != nil:
+		return call.ChatRequest.ValidateWith(models)
+	case call.StreamRequest != nil:
+		return call.StreamRequest.ValidateWith(models)
+	case call.FIMRequest != nil:
+		return call.FIMRequest.ValidateWith(models)
+	case call.FIMStreamRequest != nil:
+		return call.FIMStreamRequest.ValidateWith(models)
+	case call.ImageRequest != nil:
+		return call.ImageRequest.ValidateWith(models)
+	case call.ImageStreamRequest != nil:
+		return call.ImageStreamRequest.ValidateWith(models)
+	}
+	return nil
+}
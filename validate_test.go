@@ -0,0 +1,118 @@
+package deepseek_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/cohesion-org/deepseek-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fieldErrors returns the fields reported by a *ValidationError.
+func fieldErrors(t *testing.T, err error) []string {
+	t.Helper()
+	var validationErr *deepseek.ValidationError
+	require.ErrorAs(t, err, &validationErr)
+	require.ErrorIs(t, err, deepseek.ErrInvalidRequest)
+	fields := make([]string, len(validationErr.Errors))
+	for i, fe := range validationErr.Errors {
+		fields[i] = fe.Field
+	}
+	return fields
+}
+
+func TestChatCompletionRequestValidate(t *testing.T) {
+	valid := &deepseek.ChatCompletionRequest{
+		Model:       deepseek.DeepSeekChat,
+		Temperature: 1.5,
+		TopP:        0.9,
+		LogProbs:    true,
+		TopLogProbs: 20,
+		Messages: []deepseek.ChatCompletionMessage{
+			{Role: deepseek.ChatMessageRoleUser, Content: "weather?"},
+			{Role: deepseek.ChatMessageRoleAssistant, ToolCalls: []deepseek.ToolCall{cityCall("call_1", "Paris")}},
+			{Role: deepseek.ChatMessageRoleTool, ToolCallID: "call_1", Content: "sunny"},
+			{Role: deepseek.ChatMessageRoleAssistant, Content: "It is", Prefix: true},
+		},
+	}
+	require.NoError(t, valid.Validate())
+
+	invalid := &deepseek.ChatCompletionRequest{
+		Model:            deepseek.DeepSeekChat,
+		Temperature:      2.5,
+		TopP:             -0.1,
+		PresencePenalty:  3,
+		FrequencyPenalty: -2.5,
+		MaxTokens:        9_000,
+		TopLogProbs:      21,
+		Messages: []deepseek.ChatCompletionMessage{
+			{Role: deepseek.ChatMessageRoleAssistant, Content: "It is", Prefix: true},
+			{Role: deepseek.ChatMessageRoleTool, ToolCallID: "call_9", Content: "sunny"},
+		},
+	}
+	err := invalid.Validate()
+	assert.Equal(t, []string{"temperature", "top_p", "presence_penalty", "frequency_penalty", "max_tokens",
+		"top_logprobs", "top_logprobs", "messages[0].prefix", "messages[1].tool_call_id"}, fieldErrors(t, err))
+	assert.Contains(t, err.Error(), `"call_9" does not match a tool call`)
+}
+
+func TestValidateModelCapabilities(t *testing.T) {
+	reasoner := &deepseek.StreamChatCompletionRequest{
+		Model:       deepseek.DeepSeekReasoner,
+		LogProbs:    true,
+		TopLogProbs: 5,
+		Messages:    []deepseek.ChatCompletionMessage{{Role: deepseek.ChatMessageRoleUser, Content: "hi"}},
+	}
+	assert.Equal(t, []string{"logprobs"}, fieldErrors(t, reasoner.Validate()))
+
+	fim := &deepseek.FIMCompletionRequest{Model: deepseek.DeepSeekReasoner, Prompt: "func main() {", MaxTokens: 5000}
+	assert.Equal(t, []string{"model", "max_tokens"}, fieldErrors(t, fim.Validate()))
+
+	// Models missing from the registry are only checked against the API's ranges.
+	unknown := &deepseek.FIMStreamCompletionRequest{Model: "my-model", Prompt: "func main() {"}
+	require.NoError(t, unknown.Validate())
+
+	image := &deepseek.ChatCompletionRequestWithImage{
+		Model:    deepseek.DeepSeekChat,
+		Messages: []deepseek.ChatCompletionMessageWithImage{deepseek.NewImageMessage(deepseek.ChatMessageRoleUser, "what is this?", "https://example.com/a.png")},
+	}
+	assert.Equal(t, []string{"messages"}, fieldErrors(t, image.Validate()))
+
+	vision := deepseek.NewModelRegistry(deepseek.ModelCapabilities{ID: deepseek.DeepSeekChat, Images: true})
+	require.NoError(t, image.ValidateWith(vision))
+}
+
+func TestClientRequestValidation(t *testing.T) {
+	var requests int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.Write([]byte(retryChatResponse))
+	}))
+	defer ts.Close()
+
+	request := &deepseek.ChatCompletionRequest{
+		Model:       deepseek.DeepSeekChat,
+		Temperature: 3,
+		Messages:    []deepseek.ChatCompletionMessage{{Role: deepseek.ChatMessageRoleUser, Content: "hi"}},
+	}
+
+	client, err := deepseek.NewClientWithOptions("token", deepseek.WithBaseURL(ts.URL+"/"))
+	require.NoError(t, err)
+	_, err = client.CreateChatCompletion(context.Background(), request)
+	require.NoError(t, err, "requests are not validated by default")
+
+	client, err = deepseek.NewClientWithOptions("token", deepseek.WithBaseURL(ts.URL+"/"), deepseek.WithRequestValidation())
+	require.NoError(t, err)
+	_, err = client.CreateChatCompletion(context.Background(), request)
+	assert.Equal(t, []string{"temperature"}, fieldErrors(t, err))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&requests), "invalid requests are not sent")
+
+	_, err = client.CreateChatCompletionStreamWithImage(context.Background(), &deepseek.StreamChatCompletionRequestWithImage{
+		Model: deepseek.DeepSeekChat,
+	})
+	assert.Equal(t, []string{"messages"}, fieldErrors(t, err))
+}